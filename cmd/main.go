@@ -1,9 +1,27 @@
 package main
 
-import "mangathorg/server"
+import (
+	"flag"
+
+	"mangathorg/server"
+)
 
 // MangaThorg's entry point.
-// it simply runs the server.
+// it simply runs the server, unless a one-shot command is given.
 func main() {
-	server.Run()
+	migrateUsers := flag.String("migrate-users", "", "copies data/users.json into the given user store (sqlite) and exits")
+	makeAdmin := flag.String("make-admin", "", "gives the admin role to the given username and exits")
+	wipeCache := flag.Bool("wipe-cache", false, "empties the whole cache at startup")
+	flag.Parse()
+
+	if *migrateUsers != "" {
+		server.MigrateUsers(*migrateUsers)
+		return
+	}
+	if *makeAdmin != "" {
+		server.MakeAdmin(*makeAdmin)
+		return
+	}
+
+	server.Run(server.Options{WipeCache: *wipeCache})
 }
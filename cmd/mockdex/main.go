@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"mangathorg/internal/mangadex/mock"
+)
+
+// Runs the fake MangaDex serving the recorded fixtures.
+// Start the website with MANGADEX_API_URL and MANGADEX_UPLOADS_URL set to its URL.
+func main() {
+	addr := flag.String("addr", "localhost:9191", "address to listen on")
+	flag.Parse()
+
+	server, err := mock.New()
+	if err != nil {
+		log.Fatalln("Error while loading the fixtures:", err)
+	}
+
+	log.Printf("Fake MangaDex is listening on http://%s", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"mangathorg/internal/oidc/mock"
+)
+
+// Runs the fake OpenID Connect provider.
+// Start the website with OIDC_ISSUER set to its URL, and OIDC_CLIENT_ID and
+// OIDC_CLIENT_SECRET set to the client's credentials.
+func main() {
+	addr := flag.String("addr", "localhost:9292", "address to listen on")
+	clientID := flag.String("client-id", "mangathorg", "client ID of the website")
+	clientSecret := flag.String("client-secret", "mock-secret", "client secret of the website")
+	flag.Parse()
+
+	server, err := mock.New("http://"+*addr, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatalln("Error while generating the signing key:", err)
+	}
+
+	log.Printf("Fake identity provider is listening on http://%s", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, server))
+}
@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strconv"
+	
+	"mangathorg/internal/api"
+	api2 "mangathorg/internal/models/api"
+	"mangathorg/internal/models/server"
+	"mangathorg/internal/utils"
+)
+
+// JSON API (/api/v1): these handlers send the same data as the HTML pages, in
+// a server.JSONResponse envelope.
+
+// apiPage
+//
+//	@Description: retrieves the page number sent in the `pag` query param.
+//	@param r
+//	@return int: 1 if the param is missing or invalid.
+func apiPage(r *http.Request) int {
+	pag, err := strconv.Atoi(r.URL.Query().Get("pag"))
+	if err != nil || pag < 1 {
+		return 1
+	}
+	return pag
+}
+
+// apiOrder
+//
+//	@Description: retrieves the order sent in the `order` query param.
+//	@param r
+//	@return string: "desc" if the param is missing or invalid.
+func apiOrder(r *http.Request) string {
+	order := r.URL.Query().Get("order")
+	if order != "asc" && order != "desc" {
+		return "desc"
+	}
+	return order
+}
+
+// apiPagination
+//
+//	@Description: creates the server.JSONPagination of a `page` of `perPage`
+//	items among `total` items.
+//	@param page
+//	@param perPage
+//	@param total
+//	@return *server.JSONPagination
+func apiPagination(page, perPage, total int) *server.JSONPagination {
+	totalPages := total / perPage
+	if total%perPage > 0 {
+		totalPages++
+	}
+	return &server.JSONPagination{Page: page, TotalPages: totalPages, Total: total}
+}
+
+// apiSendMangas
+//
+//	@Description: sends a page of mangas, with the logged user's favorite info.
+//	@param w
+//	@param r
+//	@param page
+//	@param mangas
+func apiSendMangas(w http.ResponseWriter, r *http.Request, page int, mangas api2.MangasInBulk) {
+	_ = api.AddFavoriteInfo(r, &mangas.Mangas)
+	if mangas.Mangas == nil {
+		mangas.Mangas = []api2.MangaUsefullData{}
+	}
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{
+		Data:       mangas.Mangas,
+		Pagination: apiPagination(page, 18, mangas.NbMangas),
+	})
+}
+
+// apiNotFoundHandler
+//
+//	@Description: sends a JSON error for all unknown /api/ routes.
+func apiNotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	utils.SendJSONError(w, http.StatusNotFound, "unknown endpoint "+r.Method+" "+r.URL.Path)
+}
+
+// apiSearchHandlerGet
+//
+//	@Description: sends the mangas matching a search, built from the same query
+//	params as the search page (title, author, authorOrArtist, includedTags[],
+//	excludedTags[], status[], public[], order and pag).
+func apiSearchHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	pag := apiPage(r)
+	request := searchRequest(r.URL.Query(), (pag-1)*18)
+	apiSendMangas(w, r, pag, api.FetchManga(r.Context(), request))
+}
+
+// apiMangaHandlerGet
+//
+//	@Description: sends a manga according to the id put in the URL, with a page
+//	of 15 chapters (according to the `order` and `pag` query params).
+func apiMangaHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	mangaId := r.PathValue("id")
+	
+	pag := apiPage(r)
+	manga := api.FetchMangaById(r.Context(), mangaId, apiOrder(r), (pag-1)*15)
+	if reflect.DeepEqual(manga, api2.MangaUsefullData{}) {
+		utils.SendJSONError(w, http.StatusNotFound, "manga "+mangaId+" not found")
+		return
+	}
+	_ = api.AddSingleFavoriteInfo(r, &manga)
+	
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{
+		Data:       manga,
+		Pagination: apiPagination(pag, 15, manga.NbChapter),
+	})
+}
+
+// apiChapterHandlerGet
+//
+//	@Description: sends the URLs of a chapter's pages (through the scan image
+//	proxy) according to the chapterId put in the URL.
+func apiChapterHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	chapterId := r.PathValue("chapterId")
+	
+	scan := api.ScanRequest(r.Context(), chapterId)
+	if scan.Chapter.Hash == "" {
+		utils.SendJSONError(w, http.StatusNotFound, "chapter "+chapterId+" not found")
+		return
+	}
+	
+	var data = struct {
+		Id        string
+		Hash      string
+		Data      []string
+		DataSaver []string
+	}{
+		Id:   chapterId,
+		Hash: scan.Chapter.Hash,
+	}
+	for _, img := range scan.Chapter.Data {
+		data.Data = append(data.Data, utils.BaseURL+"/scan/"+chapterId+"/data/"+scan.Chapter.Hash+"/"+img)
+	}
+	for _, img := range scan.Chapter.DataSaver {
+		data.DataSaver = append(data.DataSaver, utils.BaseURL+"/scan/"+chapterId+"/data-saver/"+scan.Chapter.Hash+"/"+img)
+	}
+	
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: data})
+}
+
+// apiTagsHandlerGet
+//
+//	@Description: sends all the available tags sorted by type.
+func apiTagsHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: api.FetchSortedTags(r.Context())})
+}
+
+// apiCategoryHandlerGet
+//
+//	@Description: sends the mangas matching a specific Tag which id is sent in
+//	the URL (according to the `order` and `pag` query params).
+func apiCategoryHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	tagId := r.PathValue("tagId")
+	
+	if api.TagSelect(r.Context(), tagId).Id == "" {
+		utils.SendJSONError(w, http.StatusNotFound, "tag "+tagId+" not found")
+		return
+	}
+	
+	pag := apiPage(r)
+	var request = api2.MangaRequest{
+		OrderType:    "rating",
+		OrderValue:   apiOrder(r),
+		IncludedTags: []string{tagId},
+		Limit:        18,
+		Offset:       (pag - 1) * 18,
+	}
+	apiSendMangas(w, r, pag, api.FetchManga(r.Context(), request))
+}
+
+// apiCategoryNameHandlerGet
+//
+//	@Description: sends the mangas matching a category which group and name is
+//	sent in the URL (according to the `order` and `pag` query params).
+func apiCategoryNameHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	pag := apiPage(r)
+	request, _, ok := categoryNameRequest(r.PathValue("group"), r.PathValue("name"), apiOrder(r), (pag-1)*18)
+	if !ok || request.OrderType == "" {
+		utils.SendJSONError(w, http.StatusNotFound, "category "+r.PathValue("group")+"/"+r.PathValue("name")+" not found")
+		return
+	}
+	apiSendMangas(w, r, pag, api.FetchManga(r.Context(), request))
+}
+
+// apiFavoritesHandlerGet
+//
+//	@Description: sends the current user's favorites.
+func apiFavoritesHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		utils.SendJSONError(w, http.StatusUnauthorized, "restricted access: you need a valid user to proceed")
+		return
+	}
+	
+	favorites := api.FetchMangasById(r.Context(), user.Favorites, "desc", 0)
+	_ = api.AddFavoriteInfo(r, &favorites)
+	if favorites == nil {
+		favorites = []api2.MangaUsefullData{}
+	}
+	
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: favorites})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"mangathorg/internal/middlewares"
+	"mangathorg/internal/models/server"
 )
 
 // Generic Bundles (root and errors)
@@ -9,30 +10,67 @@ import (
 var RootHandlerGetBundle = middlewares.Join(rootHandlerGet, middlewares.Log, middlewares.UserCheck)
 var ErrorHandlerBundle = middlewares.Join(errorHandler, middlewares.Log, middlewares.UserCheck)
 
+// Email address change Bundles (accessed from the links sent by mail, logged or not)
+
+var ConfirmEmailHandlerGetBundle = middlewares.Join(confirmEmailHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF)
+var ConfirmEmailHandlerPostBundle = middlewares.Join(confirmEmailHandlerPost, middlewares.Log, middlewares.UserCheck, middlewares.CSRF)
+var CancelEmailHandlerGetBundle = middlewares.Join(cancelEmailHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF)
+var CancelEmailHandlerPostBundle = middlewares.Join(cancelEmailHandlerPost, middlewares.Log, middlewares.UserCheck, middlewares.CSRF)
+
 // Only visitors Bundles
 
-var LoginHandlerGetBundle = middlewares.Join(loginHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
-var LoginHandlerPostBundle = middlewares.Join(loginHandlerPost, middlewares.Log, middlewares.OnlyVisitors)
+var LoginHandlerGetBundle = middlewares.Join(loginHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginHandlerPostBundle = middlewares.Join(loginHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginTwoFactorHandlerGetBundle = middlewares.Join(loginTwoFactorHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginTwoFactorHandlerPostBundle = middlewares.Join(loginTwoFactorHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginEmailHandlerGetBundle = middlewares.Join(loginEmailHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginEmailHandlerPostBundle = middlewares.Join(loginEmailHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginEmailConfirmHandlerGetBundle = middlewares.Join(loginEmailConfirmHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginEmailConfirmHandlerPostBundle = middlewares.Join(loginEmailConfirmHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginPasskeyOptionsHandlerPostBundle = middlewares.Join(loginPasskeyOptionsHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginPasskeyHandlerPostBundle = middlewares.Join(loginPasskeyHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var LoginOIDCHandlerGetBundle = middlewares.Join(loginOIDCHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
+var LoginOIDCCallbackHandlerGetBundle = middlewares.Join(loginOIDCCallbackHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
 
-var RegisterHandlerGetBundle = middlewares.Join(registerHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
-var RegisterHandlerPostBundle = middlewares.Join(registerHandlerPost, middlewares.Log, middlewares.OnlyVisitors)
+var RegisterHandlerGetBundle = middlewares.Join(registerHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var RegisterHandlerPostBundle = middlewares.Join(registerHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
 
-var ForgotPasswordHandlerGetBundle = middlewares.Join(forgotPasswordHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
-var ForgotPasswordHandlerPostBundle = middlewares.Join(forgotPasswordHandlerPost, middlewares.Log, middlewares.OnlyVisitors)
+var ForgotPasswordHandlerGetBundle = middlewares.Join(forgotPasswordHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var ForgotPasswordHandlerPostBundle = middlewares.Join(forgotPasswordHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
 
-var UpdateCredentialsHandlerGetBundle = middlewares.Join(updateCredentialsHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
-var UpdateCredentialsHandlerPostBundle = middlewares.Join(updateCredentialsHandlerPost, middlewares.Log, middlewares.OnlyVisitors)
+var UpdateCredentialsHandlerGetBundle = middlewares.Join(updateCredentialsHandlerGet, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
+var UpdateCredentialsHandlerPostBundle = middlewares.Join(updateCredentialsHandlerPost, middlewares.Log, middlewares.OnlyVisitors, middlewares.CSRF)
 
 var ConfirmHandlerGetBundle = middlewares.Join(confirmHandlerGet, middlewares.Log, middlewares.OnlyVisitors)
 
 // Only users Bundles
 
-var ProfileHandlerGetBundle = middlewares.Join(profileHandlerGet, middlewares.Log, middlewares.Guard)
-var ProfileHandlerPostBundle = middlewares.Join(profileHandlerPost, middlewares.Log, middlewares.Guard)
+var ProfileHandlerGetBundle = middlewares.Join(profileHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var ProfileHandlerPostBundle = middlewares.Join(profileHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var TwoFactorHandlerGetBundle = middlewares.Join(twoFactorHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var TwoFactorHandlerPostBundle = middlewares.Join(twoFactorHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var SessionsHandlerGetBundle = middlewares.Join(sessionsHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var SessionsHandlerPostBundle = middlewares.Join(sessionsHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var PasskeysHandlerGetBundle = middlewares.Join(passkeysHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var PasskeysHandlerPostBundle = middlewares.Join(passkeysHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var PasskeyOptionsHandlerPostBundle = middlewares.Join(passkeyOptionsHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var PasskeyRegisterHandlerPostBundle = middlewares.Join(passkeyRegisterHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var AccountHandlerGetBundle = middlewares.Join(accountHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF)
+var AccountHandlerPostBundle = middlewares.Join(accountHandlerPost, middlewares.Log, middlewares.Guard, middlewares.CSRF)
 
 var LogoutHandlerGetBundle = middlewares.Join(logoutHandlerGet, middlewares.Log, middlewares.Guard)
 
-var HomeHandlerGetBundle = middlewares.Join(homeHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CheckApi) // API needed for this Bundle
+var HomeHandlerGetBundle = middlewares.Join(homeHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF, middlewares.CheckApi) // API needed for this Bundle
+var HistoryHandlerGetBundle = middlewares.Join(historyHandlerGet, middlewares.Log, middlewares.Guard, middlewares.CSRF, middlewares.CheckApi) // API needed for this Bundle
+
+// Admin console Bundles (Guard must come before RequireRole)
+
+var AdminHandlerGetBundle = middlewares.Join(adminHandlerGet, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleModerator))
+var AdminUserHandlerGetBundle = middlewares.Join(adminUserHandlerGet, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleModerator), middlewares.CSRF)
+var AdminUserHandlerPostBundle = middlewares.Join(adminUserHandlerPost, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleModerator), middlewares.CSRF)
+var AdminHealthHandlerGetBundle = middlewares.Join(adminHealthHandlerGet, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleAdmin))
+var AdminLogsHandlerGetBundle = middlewares.Join(adminLogsHandlerGet, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleAdmin))
+var AdminLogsStreamHandlerGetBundle = middlewares.Join(adminLogsStreamHandlerGet, middlewares.Log, middlewares.Guard, middlewares.RequireRole(server.RoleAdmin))
 
 // Image request Bundles
 
@@ -41,21 +79,35 @@ var ScanHandlerGetBundle = middlewares.Join(scanHandlerGet, middlewares.UserChec
 
 // User favorites' requests (accessed from javascript requests)
 
-var FavoriteHandlerPostBundle = middlewares.Join(favoriteHandlerPost, middlewares.SimpleGuard)
-var FavoriteHandlerDeleteBundle = middlewares.Join(favoriteHandlerDelete, middlewares.SimpleGuard)
+var FavoriteHandlerPostBundle = middlewares.Join(favoriteHandlerPost, middlewares.SimpleGuard, middlewares.CSRF)
+var FavoriteHandlerDeleteBundle = middlewares.Join(favoriteHandlerDelete, middlewares.SimpleGuard, middlewares.CSRF)
 
-var BannerHandlerPutBundle = middlewares.Join(bannerHandlerPut, middlewares.SimpleGuard)
+var BannerHandlerPutBundle = middlewares.Join(bannerHandlerPut, middlewares.SimpleGuard, middlewares.CSRF)
+
+// User history's requests (accessed from javascript requests)
+
+var HistoryHandlerPutBundle = middlewares.Join(historyHandlerPut, middlewares.SimpleGuard, middlewares.CSRF)
+var HistoryHandlerDeleteBundle = middlewares.Join(historyHandlerDelete, middlewares.SimpleGuard, middlewares.CSRF)
+var HistoryClearHandlerDeleteBundle = middlewares.Join(historyClearHandlerDelete, middlewares.SimpleGuard, middlewares.CSRF)
 
 // Bundles available for any clients: they all need MangaDex API to work
 
 var AboutHandlerGetBundle = middlewares.Join(aboutHandlerGet, middlewares.Log, middlewares.UserCheck)
-var PrincipalHandlerGetBundle = middlewares.Join(principalHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-var MangaRequestHandlerGet = middlewares.Join(mangaHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
+var PrincipalHandlerGetBundle = middlewares.Join(principalHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
+var MangaRequestHandlerGet = middlewares.Join(mangaHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
 var TagsHandlerGetBundle = middlewares.Join(tagsHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-var CategoryHandlerGetBundle = middlewares.Join(categoryHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-var CategoryNameHandlerGetBundle = middlewares.Join(categoryNameHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-var SearchHandlerGetBundle = middlewares.Join(searchHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-var ChapterHandlerGetBundle = middlewares.Join(chapterHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CheckApi)
-
-// LogHandlerGetBundle is a special Bundle that enables access to the logs: this is a testing and developping tool only (remove before deploying)
-var LogHandlerGetBundle = middlewares.Join(logHandlerGet, middlewares.Log, middlewares.UserCheck)
+var CategoryHandlerGetBundle = middlewares.Join(categoryHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
+var CategoryNameHandlerGetBundle = middlewares.Join(categoryNameHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
+var SearchHandlerGetBundle = middlewares.Join(searchHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
+var ChapterHandlerGetBundle = middlewares.Join(chapterHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.CSRF, middlewares.CheckApi)
+
+// JSON API Bundles (/api/v1): they send JSON errors instead of redirecting
+
+var ApiNotFoundHandlerBundle = middlewares.Join(apiNotFoundHandler, middlewares.Log)
+var ApiSearchHandlerGetBundle = middlewares.Join(apiSearchHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiMangaHandlerGetBundle = middlewares.Join(apiMangaHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiChapterHandlerGetBundle = middlewares.Join(apiChapterHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiTagsHandlerGetBundle = middlewares.Join(apiTagsHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiCategoryHandlerGetBundle = middlewares.Join(apiCategoryHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiCategoryNameHandlerGetBundle = middlewares.Join(apiCategoryNameHandlerGet, middlewares.Log, middlewares.UserCheck, middlewares.ApiCheckApi)
+var ApiFavoritesHandlerGetBundle = middlewares.Join(apiFavoritesHandlerGet, middlewares.Log, middlewares.ApiGuard, middlewares.ApiCheckApi)
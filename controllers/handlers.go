@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"html/template"
@@ -15,10 +16,12 @@ import (
 	"time"
 	
 	"mangathorg/internal/api"
+	"mangathorg/internal/mangadex"
 	"mangathorg/internal/middlewares"
 	api2 "mangathorg/internal/models/api"
 	"mangathorg/internal/models/server"
 	"mangathorg/internal/utils"
+	"mangathorg/internal/webauthn"
 )
 
 // rootHandlerGet
@@ -114,6 +117,22 @@ func loginHandlerGet(w http.ResponseWriter, r *http.Request) {
 			message = "<div class=\"message\">Wrong username or password!</div>"
 		case "restricted":
 			message = "<div class=\"message\">You need to login to access that area!</div>"
+		case "expired":
+			message = "<div class=\"message\">Your login has expired, please try again!</div>"
+		case "throttled":
+			message = template.HTML("<div class=\"message\">" + utils.RetryMessage(r) + "</div>")
+		case "disabled":
+			message = "<div class=\"message\">Your account has been disabled!</div>"
+		case "reset":
+			message = "<div class=\"message\">You need to set a new password, check your mails or use Forgot password!</div>"
+		case "sso":
+			message = template.HTML("<div class=\"message\">The login with " + template.HTMLEscapeString(utils.OIDCProviderName()) + " failed, please try again!</div>")
+		case "sso-email":
+			message = template.HTML("<div class=\"message\">Your " + template.HTMLEscapeString(utils.OIDCProviderName()) + " account has no verified email address!</div>")
+		case "magic-browser":
+			message = "<div class=\"message\">Please open the login link in the browser where you asked for it!</div>"
+		case "sso-pending":
+			message = "<div class=\"message\">A registration is pending with your email address, please confirm it first!</div>"
 		}
 	} else if r.URL.Query().Has("status") {
 		switch r.URL.Query().Get("status") {
@@ -121,14 +140,24 @@ func loginHandlerGet(w http.ResponseWriter, r *http.Request) {
 			message = `<div class="message">Your password has been updated!</div>`
 		case "signed-up":
 			message = `<div class="message">We've sent you a message to confirm your account!</div>`
+		case "deleted":
+			message = `<div class="message">Your account has been deleted!</div>`
+		case "email-updated":
+			message = `<div class="message">Your email address has been changed!</div>`
+		case "email-cancelled":
+			message = `<div class="message">The change of your email address has been cancelled!</div>`
 		}
 	}
 	var data = struct {
-		Message template.HTML
+		Message      template.HTML
+		CSRFToken    string
+		OIDCProvider string
 	}{
-		Message: message,
+		Message:      message,
+		CSRFToken:    utils.CSRFToken(r),
+		OIDCProvider: utils.OIDCProviderName(),
 	}
-	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/login.gohtml")
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/login.gohtml", utils.Path+"templates/passkeys.js.gohtml")
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -147,14 +176,385 @@ func loginHandlerPost(w http.ResponseWriter, r *http.Request) {
 		Username: r.FormValue("username"),
 		Password: r.FormValue("password"),
 	}
+	if wait, ok := utils.LoginAllowed(r, credentials.Username); !ok {
+		http.Redirect(w, r, "/login?err=throttled&retry="+utils.RetryAfter(wait), http.StatusSeeOther)
+		return
+	}
 	if utils.CheckPwd(credentials) {
-		utils.OpenSession(&w, credentials.Username, r)
+		user, _ := utils.SelectUser(credentials.Username)
+		if refusal := loginRefusal(user); refusal != "" {
+			http.Redirect(w, r, "/login?err="+refusal, http.StatusSeeOther)
+			return
+		}
+		if user.TwoFactor != nil {
+			// second login step: the failed logins are only cleared once the
+			// code is right
+			utils.StartLoginChallenge(&w, credentials.Username, r.FormValue("remember") == "on")
+			http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+			return
+		}
+		utils.LoginSucceeded(credentials.Username)
+		r = utils.OpenSession(&w, credentials.Username, r)
+		if r.FormValue("remember") == "on" {
+			utils.RememberUser(&w, r)
+		}
 		http.Redirect(w, r, "/home", http.StatusSeeOther)
 	} else {
+		utils.LoginFailed(r, credentials.Username)
 		http.Redirect(w, r, "/login?err=login", http.StatusSeeOther)
 	}
 }
 
+// loginRefusal
+//
+//	@Description: returns the `err` query key telling why the `user` isn't
+//	allowed to log in, or an empty string if it is.
+//	@param user
+//	@return string
+func loginRefusal(user server.User) string {
+	switch {
+	case user.Disabled:
+		return "disabled"
+	case user.ResetRequired:
+		return "reset"
+	}
+	return ""
+}
+
+// loginTwoFactorHandlerGet
+//
+//	@Description: displays the second login step's form (for the users with
+//	two-factor authentication) and possible messages according to the `err`
+//	query key.
+func loginTwoFactorHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if _, ok := utils.LoginChallengeUser(r); !ok {
+		http.Redirect(w, r, "/login?err=expired", http.StatusSeeOther)
+		return
+	}
+	
+	var message template.HTML
+	switch r.URL.Query().Get("err") {
+	case "code":
+		message = "<div class=\"message\">Wrong code!</div>"
+	case "throttled":
+		message = template.HTML("<div class=\"message\">" + utils.RetryMessage(r) + "</div>")
+	}
+	var data = struct {
+		Message   template.HTML
+		CSRFToken string
+	}{
+		Message:   message,
+		CSRFToken: utils.CSRFToken(r),
+	}
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/login-2fa.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// loginTwoFactorHandlerPost
+//
+//	@Description: second login step's treatment handler.
+func loginTwoFactorHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	challenged, ok := utils.LoginChallengeUser(r)
+	if !ok {
+		http.Redirect(w, r, "/login?err=expired", http.StatusSeeOther)
+		return
+	}
+	// the codes are guessed under the same throttles as the passwords
+	if wait, ok := utils.LoginAllowed(r, challenged); !ok {
+		http.Redirect(w, r, "/login/2fa?err=throttled&retry="+utils.RetryAfter(wait), http.StatusSeeOther)
+		return
+	}
+	username, remember, pending := utils.CompleteLoginChallenge(&w, r, r.FormValue("code"))
+	if username == "" {
+		utils.LoginFailed(r, challenged)
+		if pending {
+			http.Redirect(w, r, "/login/2fa?err=code", http.StatusSeeOther)
+		} else {
+			http.Redirect(w, r, "/login?err=expired", http.StatusSeeOther)
+		}
+		return
+	}
+	user, _ := utils.SelectUser(username)
+	if refusal := loginRefusal(user); refusal != "" {
+		http.Redirect(w, r, "/login?err="+refusal, http.StatusSeeOther)
+		return
+	}
+	utils.LoginSucceeded(username)
+	r = utils.OpenSession(&w, username, r)
+	if remember {
+		utils.RememberUser(&w, r)
+	}
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
+}
+
+// loginOIDCHandlerGet
+//
+//	@Description: starts the OpenID Connect login by redirecting the user to
+//	the provider.
+func loginOIDCHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if utils.OIDCProviderName() == "" {
+		errorHandler(w, r)
+		return
+	}
+	authURL, err := utils.StartOIDCLogin(&w, r)
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		http.Redirect(w, r, "/login?err=sso", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// loginOIDCCallbackHandlerGet
+//
+//	@Description: OpenID Connect login's treatment handler, to which the
+//	provider sends the user back.
+func loginOIDCCallbackHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if utils.OIDCProviderName() == "" {
+		errorHandler(w, r)
+		return
+	}
+	user, err := utils.CompleteOIDCLogin(&w, r)
+	if err != nil {
+		utils.Logger.Warn("OpenID Connect login failed", slog.Any("output", err), slog.String("client_ip", utils.GetIP(r)))
+		refusal := "sso"
+		switch {
+		case errors.Is(err, utils.ErrOIDCLogin):
+			refusal = "expired"
+		case errors.Is(err, utils.ErrOIDCEmail):
+			refusal = "sso-email"
+		case errors.Is(err, utils.ErrOIDCEmailTaken):
+			refusal = "sso-pending"
+		}
+		http.Redirect(w, r, "/login?err="+refusal, http.StatusSeeOther)
+		return
+	}
+	if refusal := loginRefusal(user); refusal != "" {
+		http.Redirect(w, r, "/login?err="+refusal, http.StatusSeeOther)
+		return
+	}
+	if user.TwoFactor != nil {
+		// second login step
+		utils.StartLoginChallenge(&w, user.Username, false)
+		sameSiteRedirect(w, "/login/2fa")
+		return
+	}
+	utils.OpenSession(&w, user.Username, r)
+	sameSiteRedirect(w, "/home")
+}
+
+// sameSiteRedirect
+//
+//	@Description: redirects the user to the `target` page from an HTML page
+//	rather than with a redirection status. The session_id and login_challenge
+//	cookies are Strict, so the browsers wouldn't send them on a redirection
+//	coming from another site (the OpenID Connect provider).
+//	@param w
+//	@param target
+func sameSiteRedirect(w http.ResponseWriter, target string) {
+	tmpl, err := template.ParseFiles(utils.Path + "templates/redirect.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "redirect", target)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// loginEmailHandlerGet
+//
+//	@Description: displays the form asking for a magic link and possible
+//	messages according to the `confirm` and `err` query keys.
+func loginEmailHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	var message template.HTML
+	if r.URL.Query().Has("confirm") {
+		message = "<div class=\"message\">If this address is yours, a login link has been sent to it!</div>"
+	} else if r.URL.Query().Get("err") == "throttled" {
+		message = template.HTML("<div class=\"message\">" + utils.RetryMessage(r) + "</div>")
+	}
+	var data = struct {
+		Message   template.HTML
+		CSRFToken string
+	}{
+		Message:   message,
+		CSRFToken: utils.CSRFToken(r),
+	}
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/login-email.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// loginEmailHandlerPost
+//
+//	@Description: magic link request's treatment handler.
+func loginEmailHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	email := strings.TrimSpace(strings.ToLower(r.FormValue("email")))
+	if wait, ok := utils.MagicLinkAllowed(r, email); !ok {
+		http.Redirect(w, r, "/login/email?err=throttled&retry="+utils.RetryAfter(wait), http.StatusSeeOther)
+		return
+	}
+	if exists, user := utils.EmailExists(email); exists && loginRefusal(user) == "" {
+		utils.RequestMagicLink(&w, r, user)
+	}
+	
+	http.Redirect(w, r, "/login/email?confirm=true", http.StatusSeeOther)
+}
+
+// loginEmailConfirmHandlerGet
+//
+//	@Description: displays the confirmation of the login with the magic link
+//	sent by mail (the link itself doesn't log in, so that it can't be used by
+//	the mail clients opening the links in advance).
+func loginEmailConfirmHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	id := r.URL.Query().Get("id")
+	username, err := utils.CheckMagicLink(r, id)
+	if errors.Is(err, utils.ErrMagicLinkBrowser) {
+		http.Redirect(w, r, "/login?err=magic-browser", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		invalidLinkHandler(w, "/login/email")
+		return
+	}
+	var data = struct {
+		Username  string
+		Id        string
+		CSRFToken string
+	}{
+		Username:  username,
+		Id:        id,
+		CSRFToken: utils.CSRFToken(r),
+	}
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/login-email-confirm.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// loginEmailConfirmHandlerPost
+//
+//	@Description: login with a magic link's treatment handler.
+func loginEmailConfirmHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	user, err := utils.CompleteMagicLink(r, r.FormValue("id"))
+	if errors.Is(err, utils.ErrMagicLinkBrowser) {
+		http.Redirect(w, r, "/login?err=magic-browser", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		invalidLinkHandler(w, "/login/email")
+		return
+	}
+	if refusal := loginRefusal(user); refusal != "" {
+		http.Redirect(w, r, "/login?err="+refusal, http.StatusSeeOther)
+		return
+	}
+	remember := r.FormValue("remember") == "on"
+	if user.TwoFactor != nil {
+		// second login step: the failed logins are only cleared once the
+		// code is right
+		utils.StartLoginChallenge(&w, user.Username, remember)
+		http.Redirect(w, r, "/login/2fa", http.StatusSeeOther)
+		return
+	}
+	utils.LoginSucceeded(user.Username)
+	r = utils.OpenSession(&w, user.Username, r)
+	if remember {
+		utils.RememberUser(&w, r)
+	}
+	http.Redirect(w, r, "/home", http.StatusSeeOther)
+}
+
+// passkeyBodySize bounds the size of the JSON bodies sent by the
+// authenticators.
+const passkeyBodySize = 64 << 10
+
+// loginPasskeyOptionsHandlerPost
+//
+//	@Description: starts a passkey login and sends the options of
+//	navigator.credentials.get in JSON.
+func loginPasskeyOptionsHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	options, err := utils.BeginPasskeyLogin(&w)
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		utils.SendJSONError(w, http.StatusInternalServerError, "An error has occured!")
+		return
+	}
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: options})
+}
+
+// loginPasskeyHandlerPost
+//
+//	@Description: verifies the passkey signed by the user's authenticator
+//	(JSON body holding the credential and `remember`) and logs the user in.
+//	The passkeys require the user verification, so the second factor isn't
+//	asked. The URL to go to is sent in JSON.
+func loginPasskeyHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if wait, ok := utils.PasskeyLoginAllowed(r); !ok {
+		utils.SendJSONError(w, http.StatusTooManyRequests, "Too many attempts, please try again in "+utils.DurationToString(wait)+"!")
+		return
+	}
+	var body struct {
+		Remember bool `json:"remember"`
+		webauthn.AssertionResponse
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, passkeyBodySize)).Decode(&body)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid passkey!")
+		return
+	}
+	user, err := utils.FinishPasskeyLogin(&w, r, body.AssertionResponse)
+	if errors.Is(err, utils.ErrPasskeyCeremony) {
+		utils.SendJSONError(w, http.StatusBadRequest, "The login has expired, please try again!")
+		return
+	}
+	if err != nil {
+		utils.Logger.Warn(utils.GetCurrentFuncName(), slog.Any("output", err), slog.String("client_ip", utils.GetIP(r)))
+		utils.PasskeyLoginFailed(r)
+		if errors.Is(err, utils.ErrPasskeyUnknown) {
+			utils.SendJSONError(w, http.StatusUnauthorized, "This passkey isn't registered on MangaThorg!")
+		} else {
+			utils.SendJSONError(w, http.StatusUnauthorized, "The login with this passkey failed, please try again!")
+		}
+		return
+	}
+	if refusal := loginRefusal(user); refusal != "" {
+		utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: "/login?err=" + refusal})
+		return
+	}
+	utils.LoginSucceeded(user.Username)
+	r = utils.OpenSession(&w, user.Username, r)
+	if body.Remember {
+		utils.RememberUser(&w, r)
+	}
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: "/home"})
+}
+
 // registerHandlerGet
 //
 //	@Description: displays the register form and possible messages according to the
@@ -174,12 +574,16 @@ func registerHandlerGet(w http.ResponseWriter, r *http.Request) {
 			message = "<div class=\"message\">Wrong email value!</div>"
 		case "password":
 			message = "<div class=\"message\">Password needs 8 characters min, 1 digit, 1 lowercase, 1 uppercase and 1 symbol.</div>"
+		case "throttled":
+			message = template.HTML("<div class=\"message\">" + utils.RetryMessage(r) + "</div>")
 		}
 	}
 	var data = struct {
-		Message template.HTML
+		Message   template.HTML
+		CSRFToken string
 	}{
-		Message: message,
+		Message:   message,
+		CSRFToken: utils.CSRFToken(r),
 	}
 	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/register.gohtml")
 	if err != nil {
@@ -207,6 +611,10 @@ func registerHandlerPost(w http.ResponseWriter, r *http.Request) {
 		password1: r.FormValue("password"),
 		password2: r.FormValue("confirm-password"),
 	}
+	if wait, ok := utils.RegisterAllowed(r); !ok {
+		http.Redirect(w, r, "register?err=throttled&retry="+utils.RetryAfter(wait), http.StatusSeeOther)
+		return
+	}
 	switch {
 	case len(formValues.username) < 3:
 		http.Redirect(w, r, "register?err=username", http.StatusSeeOther)
@@ -227,20 +635,18 @@ func registerHandlerPost(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "register?err=password", http.StatusSeeOther)
 		return
 	}
-	hash, salt := utils.NewPwd(formValues.password1)
 	newTempUser := server.TempUser{
 		ConfirmID:    "",
 		CreationTime: time.Now(),
 		User: server.User{
 			Id:        0,
 			Username:  formValues.username,
-			HashedPwd: hash,
-			Salt:      salt,
+			HashedPwd: utils.NewPwd(formValues.password1),
 			Email:     formValues.email,
 		},
 	}
 	utils.SendMail(&newTempUser, "creation")
-	utils.TempUsers = append(utils.TempUsers, newTempUser)
+	utils.AddTempUser(newTempUser, "creation")
 	http.Redirect(w, r, "/login?status=signed-up", http.StatusSeeOther)
 }
 
@@ -253,11 +659,15 @@ func forgotPasswordHandlerGet(w http.ResponseWriter, r *http.Request) {
 	var message template.HTML
 	if r.URL.Query().Has("confirm") {
 		message = "<div class=\"message\">A mail has been sent to set a new password!</div>"
+	} else if r.URL.Query().Get("err") == "throttled" {
+		message = template.HTML("<div class=\"message\">" + utils.RetryMessage(r) + "</div>")
 	}
 	var data = struct {
-		Message template.HTML
+		Message   template.HTML
+		CSRFToken string
 	}{
-		Message: message,
+		Message:   message,
+		CSRFToken: utils.CSRFToken(r),
 	}
 	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/forgot-passwd.gohtml")
 	if err != nil {
@@ -276,13 +686,17 @@ func forgotPasswordHandlerPost(w http.ResponseWriter, r *http.Request) {
 	log.Println(utils.GetCurrentFuncName())
 	
 	email := r.FormValue("email")
+	if wait, ok := utils.ResetAllowed(r, email); !ok {
+		http.Redirect(w, r, "/forgot-password?err=throttled&retry="+utils.RetryAfter(wait), http.StatusSeeOther)
+		return
+	}
 	if exists, user := utils.EmailExists(email); exists {
 		var temp = server.TempUser{
 			CreationTime: time.Now(),
 			User:         user,
 		}
 		utils.SendMail(&temp, "lost")
-		utils.LostUsers = append(utils.LostUsers, temp)
+		utils.AddTempUser(temp, "lost")
 	}
 	
 	http.Redirect(w, r, "/forgot-password?confirm=true", http.StatusSeeOther)
@@ -294,9 +708,10 @@ func forgotPasswordHandlerPost(w http.ResponseWriter, r *http.Request) {
 //	password and accessed it through the URL sent by mail).
 func updateCredentialsHandlerGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(utils.GetCurrentFuncName())
-	var id string
-	if r.URL.Query().Has("id") {
-		id = r.URL.Query().Get("id")
+	id := r.URL.Query().Get("id")
+	if !utils.CheckLostUser(id) {
+		invalidLinkHandler(w, "/forgot-password")
+		return
 	}
 	var message template.HTML
 	if r.URL.Query().Has("err") {
@@ -310,11 +725,13 @@ func updateCredentialsHandlerGet(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	var data = struct {
-		Message template.HTML
-		Id      string
+		Message   template.HTML
+		Id        string
+		CSRFToken string
 	}{
-		Message: message,
-		Id:      id,
+		Message:   message,
+		Id:        id,
+		CSRFToken: utils.CSRFToken(r),
 	}
 	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/update-credentials.gohtml")
 	if err != nil {
@@ -334,19 +751,14 @@ func updateCredentialsHandlerPost(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	password := r.FormValue("password")
 	confirmPassword := r.FormValue("confirm-password")
-	var lostUser server.TempUser
-	for _, user := range utils.LostUsers {
-		if user.ConfirmID == id {
-			lostUser = user
-			break
-		}
-	}
 	if password != confirmPassword || !utils.CheckPasswd(password) {
-		http.Redirect(w, r, "/update-credentials?id="+id, http.StatusSeeOther)
+		http.Redirect(w, r, "/update-credentials?id="+url.QueryEscape(id), http.StatusSeeOther)
+		return
+	}
+	if !utils.UpdateLostUser(id, utils.NewPwd(password)) {
+		invalidLinkHandler(w, "/forgot-password")
 		return
 	}
-	lostUser.User.HashedPwd, lostUser.User.Salt = utils.NewPwd(password)
-	utils.UpdateLostUser(lostUser)
 	http.Redirect(w, r, "/login?status=update-pwd", http.StatusSeeOther)
 }
 
@@ -366,6 +778,10 @@ func profileHandlerGet(w http.ResponseWriter, r *http.Request) {
 			message = "<div class=\"message\">Password needs 8 characters min, 1 digit, 1 lowercase, 1 uppercase and 1 symbol.</div>"
 		case "current-pwd":
 			message = "<div class=\"message\">Incorrect password!</div>"
+		case "email":
+			message = "<div class=\"message\">Wrong email value!</div>"
+		case "email-used":
+			message = "<div class=\"message\">This email address is already used!</div>"
 		default:
 			message = "<div class=\"message\">An error has occured!</div>"
 		}
@@ -374,6 +790,14 @@ func profileHandlerGet(w http.ResponseWriter, r *http.Request) {
 			message = "<div class=\"message\">Your information has been successfully updated!</div>"
 		} else if r.URL.Query().Get("status") == "nothing" {
 			message = "<div class=\"message\">Nothing has been changed!</div>"
+		} else if r.URL.Query().Get("status") == "2fa-disabled" {
+			message = "<div class=\"message\">Two-factor authentication has been turned off!</div>"
+		} else if r.URL.Query().Get("status") == "email-sent" {
+			message = "<div class=\"message\">A mail has been sent to your new email address: it will be changed once you confirm it!</div>"
+		} else if r.URL.Query().Get("status") == "email-updated" {
+			message = "<div class=\"message\">Your email address has been changed!</div>"
+		} else if r.URL.Query().Get("status") == "email-cancelled" {
+			message = "<div class=\"message\">The change of your email address has been cancelled!</div>"
 		}
 	}
 	
@@ -386,14 +810,20 @@ func profileHandlerGet(w http.ResponseWriter, r *http.Request) {
 	var data = struct {
 		IsConnected bool
 		Username    string
+		Email       string
 		Message     template.HTML
 		AvatarImg   string
 		Avatars     []string
+		IsModerator bool
+		CSRFToken   string
 	}{
 		IsConnected: true,
 		Username:    user.Username,
+		Email:       user.Email,
 		Message:     message,
 		AvatarImg:   user.Avatar,
+		IsModerator: utils.HasRole(user, server.RoleModerator),
+		CSRFToken:   utils.CSRFToken(r),
 	}
 	
 	for i := range 86 {
@@ -426,38 +856,1057 @@ func profileHandlerPost(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
 	newPassword := r.FormValue("new-password")
 	confirmPassword := r.FormValue("confirm-password")
+	email := strings.TrimSpace(strings.ToLower(r.FormValue("email")))
+	
+	session, _ := utils.GetSession(r)
+	
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/profile?err=internal-error", http.StatusSeeOther)
+		return
+	}
+	
+	changeEmail := email != "" && email != user.Email
+	if changeEmail {
+		switch {
+		case !utils.CheckEmail(email):
+			http.Redirect(w, r, "/profile?err=email", http.StatusSeeOther)
+			return
+		case !utils.EmailAvailable(email):
+			http.Redirect(w, r, "/profile?err=email-used", http.StatusSeeOther)
+			return
+		case !utils.CheckPwd(server.Credentials{Username: session.Username, Password: password}):
+			http.Redirect(w, r, "/profile?err=current-pwd", http.StatusSeeOther)
+			return
+		}
+		// selected again after CheckPwd, which may rehash the password
+		user, _ = utils.SelectUser(session.Username)
+	}
+	
+	if password != "" && newPassword != "" && confirmPassword != "" {
+		if !utils.CheckPwd(server.Credentials{Username: session.Username, Password: password}) {
+			http.Redirect(w, r, "/profile?err=current-pwd", http.StatusSeeOther)
+			return
+		}
+		if newPassword != confirmPassword {
+			http.Redirect(w, r, "/profile?err=match", http.StatusSeeOther)
+			return
+		} else if !utils.CheckPasswd(newPassword) {
+			http.Redirect(w, r, "/profile?err=password", http.StatusSeeOther)
+			return
+		}
+		user.HashedPwd, user.Salt = utils.NewPwd(newPassword), ""
+	} else if user.Avatar == avatar && !changeEmail {
+		http.Redirect(w, r, "/profile?status=nothing", http.StatusSeeOther)
+		return
+	}
+	
+	user.Avatar = avatar
+	utils.UpdateUser(user)
+	
+	if newPassword != "" {
+		r = utils.RotateSession(&w, r)
+	}
+	
+	if changeEmail {
+		utils.RequestEmailChange(user, email)
+		http.Redirect(w, r, "/profile?status=email-sent", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile?status=updated", http.StatusSeeOther)
+}
+
+// twoFactorHandlerGet
+//
+//	@Description: displays the two-factor authentication settings: the QR code
+//	to turn it on, or the form to turn it off; and possible messages according
+//	to the `err` and `status` query keys.
+func twoFactorHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	var message template.HTML
+	switch r.URL.Query().Get("err") {
+	case "code":
+		message = "<div class=\"message\">Wrong code, please scan the new QR code!</div>"
+	case "current-pwd":
+		message = "<div class=\"message\">Incorrect password!</div>"
+	case "":
+	default:
+		message = "<div class=\"message\">An error has occured!</div>"
+	}
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/login?err=restricted", http.StatusSeeOther)
+		return
+	}
+	
+	var data = twoFactorData{
+		IsConnected: true,
+		Username:    user.Username,
+		AvatarImg:   user.Avatar,
+		Message:     message,
+		Enabled:     user.TwoFactor != nil,
+		CSRFToken:   utils.CSRFToken(r),
+	}
+	
+	if user.TwoFactor != nil {
+		data.RemainingCodes = len(user.TwoFactor.RecoveryCodes)
+	} else {
+		secret, err := utils.NewTOTPSecret()
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		}
+		qrCode, err := utils.TOTPQRCode(user.Username, secret)
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		}
+		data.Secret, data.QRCode = secret, template.URL(qrCode)
+	}
+	
+	executeTwoFactorTemplate(w, data)
+}
+
+// twoFactorHandlerPost
+//
+//	@Description: two-factor authentication settings' treatment handler: turns
+//	it on (`action` "enable", with the `secret` and `code` form values), turns
+//	it off (`action` "disable") or renews the recovery codes (`action`
+//	"recovery-codes"), both after checking the `password` form value. The
+//	recovery codes are displayed once.
+func twoFactorHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
 	
 	session, _ := utils.GetSession(r)
+	action := r.FormValue("action")
+	if action == "disable" || action == "recovery-codes" {
+		if !utils.CheckPwd(server.Credentials{Username: session.Username, Password: r.FormValue("password")}) {
+			http.Redirect(w, r, "/profile/2fa?err=current-pwd", http.StatusSeeOther)
+			return
+		}
+	}
+	
+	// selected after CheckPwd, which may rehash the password
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/profile/2fa?err=internal-error", http.StatusSeeOther)
+		return
+	}
+	
+	var codes []string
+	switch action {
+	case "enable":
+		if user.TwoFactor != nil {
+			http.Redirect(w, r, "/profile/2fa", http.StatusSeeOther)
+			return
+		}
+		codes, ok = utils.EnableTwoFactor(user, r.FormValue("secret"), r.FormValue("code"))
+		if !ok {
+			http.Redirect(w, r, "/profile/2fa?err=code", http.StatusSeeOther)
+			return
+		}
+	case "recovery-codes":
+		codes, ok = utils.RenewRecoveryCodes(user)
+		if !ok {
+			http.Redirect(w, r, "/profile/2fa?err=internal-error", http.StatusSeeOther)
+			return
+		}
+	case "disable":
+		utils.DisableTwoFactor(user)
+		http.Redirect(w, r, "/profile?status=2fa-disabled", http.StatusSeeOther)
+		return
+	default:
+		http.Redirect(w, r, "/profile/2fa?err=action", http.StatusSeeOther)
+		return
+	}
+	
+	var data = twoFactorData{
+		IsConnected:   true,
+		Username:      user.Username,
+		AvatarImg:     user.Avatar,
+		Enabled:       true,
+		RecoveryCodes: codes,
+	}
+	executeTwoFactorTemplate(w, data)
+}
+
+// twoFactorData is the data of the two-factor authentication settings page.
+type twoFactorData struct {
+	IsConnected    bool
+	Username       string
+	AvatarImg      string
+	Message        template.HTML
+	Enabled        bool
+	RemainingCodes int
+	Secret         string
+	QRCode         template.URL
+	RecoveryCodes  []string
+	CSRFToken      string
+}
+
+// executeTwoFactorTemplate
+//
+//	@Description: displays the two-factor authentication settings page.
+//	@param w
+//	@param data
+func executeTwoFactorTemplate(w http.ResponseWriter, data twoFactorData) {
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/two-factor.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// sessionsHandlerGet
+//
+//	@Description: displays the user's active sessions and possible messages
+//	according to the `err` and `status` query keys.
+func sessionsHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	var message template.HTML
+	if r.URL.Query().Has("err") {
+		message = "<div class=\"message\">This session doesn't exist anymore!</div>"
+	} else if r.URL.Query().Has("status") {
+		switch r.URL.Query().Get("status") {
+		case "revoked":
+			message = "<div class=\"message\">The session has been logged out!</div>"
+		case "revoked-others":
+			message = "<div class=\"message\">All your other sessions have been logged out!</div>"
+		}
+	}
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/login?err=restricted", http.StatusSeeOther)
+		return
+	}
+	
+	type sessionItem struct {
+		ConnectionID int
+		IpAddress    string
+		UserAgent    string
+		CreatedAt    string
+		LastSeen     string
+		Remembered   bool
+		Current      bool
+	}
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     template.HTML
+		Sessions    []sessionItem
+		HasOthers   bool
+		CSRFToken   string
+	}{
+		IsConnected: true,
+		Username:    user.Username,
+		AvatarImg:   user.Avatar,
+		Message:     message,
+		CSRFToken:   utils.CSRFToken(r),
+	}
+	
+	for _, userSession := range utils.UserSessions(user.Id) {
+		item := sessionItem{
+			ConnectionID: userSession.ConnectionID,
+			IpAddress:    userSession.IpAddress,
+			UserAgent:    userSession.UserAgent,
+			CreatedAt:    userSession.CreatedAt.Format("02/01/2006 15:04"),
+			LastSeen:     userSession.LastSeen.Format("02/01/2006 15:04"),
+			Remembered:   userSession.RememberSelector != "",
+			Current:      userSession.ConnectionID == session.ConnectionID,
+		}
+		data.HasOthers = data.HasOthers || !item.Current
+		data.Sessions = append(data.Sessions, item)
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/sessions.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// sessionsHandlerPost
+//
+//	@Description: logs out one of the user's sessions (`connection` form value),
+//	or all of them but the current one (`all-others` form value).
+func sessionsHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	if r.FormValue("all-others") != "" {
+		utils.RevokeOtherSessions(r)
+		http.Redirect(w, r, "/profile/sessions?status=revoked-others", http.StatusSeeOther)
+		return
+	}
+	
+	session, _ := utils.GetSession(r)
+	connectionID, err := strconv.Atoi(r.FormValue("connection"))
+	if err != nil || connectionID == session.ConnectionID || !utils.RevokeSession(session.UserID, connectionID) {
+		http.Redirect(w, r, "/profile/sessions?err=not-found", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/sessions?status=revoked", http.StatusSeeOther)
+}
+
+// passkeysHandlerGet
+//
+//	@Description: displays the user's passkeys, the form to add one and
+//	possible messages according to the `err` and `status` query keys.
+func passkeysHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	var message template.HTML
+	if r.URL.Query().Has("err") {
+		message = "<div class=\"message\">This passkey doesn't exist anymore!</div>"
+	} else if r.URL.Query().Has("status") {
+		switch r.URL.Query().Get("status") {
+		case "added":
+			message = "<div class=\"message\">Your passkey has been added! You can now use it to log in.</div>"
+		case "removed":
+			message = "<div class=\"message\">The passkey has been removed!</div>"
+		}
+	}
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/login?err=restricted", http.StatusSeeOther)
+		return
+	}
+	
+	type passkeyItem struct {
+		ID        string
+		Name      string
+		CreatedAt string
+		LastUsed  string
+	}
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     template.HTML
+		Passkeys    []passkeyItem
+		CSRFToken   string
+	}{
+		IsConnected: true,
+		Username:    user.Username,
+		AvatarImg:   user.Avatar,
+		Message:     message,
+		CSRFToken:   utils.CSRFToken(r),
+	}
+	
+	for _, passkey := range user.Passkeys {
+		item := passkeyItem{
+			ID:        base64.RawURLEncoding.EncodeToString(passkey.ID),
+			Name:      passkey.Name,
+			CreatedAt: passkey.CreatedAt.Format("02/01/2006 15:04"),
+		}
+		if !passkey.LastUsed.IsZero() {
+			item.LastUsed = passkey.LastUsed.Format("02/01/2006 15:04")
+		}
+		data.Passkeys = append(data.Passkeys, item)
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/passkeys.gohtml", utils.Path+"templates/passkeys.js.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// passkeysHandlerPost
+//
+//	@Description: removes one of the user's passkeys (`passkey` form value).
+func passkeysHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	id, err := base64.RawURLEncoding.DecodeString(r.FormValue("passkey"))
+	if !ok || err != nil || !utils.RemovePasskey(user, id) {
+		http.Redirect(w, r, "/profile/passkeys?err=not-found", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/profile/passkeys?status=removed", http.StatusSeeOther)
+}
+
+// passkeyOptionsHandlerPost
+//
+//	@Description: once the user's password is confirmed (JSON body holding the
+//	`password`), starts the registration of a new passkey and sends the
+//	options of navigator.credentials.create in JSON.
+func passkeyOptionsHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	var body struct {
+		Password string `json:"password"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, passkeyBodySize)).Decode(&body)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid request!")
+		return
+	}
+	// a stolen session mustn't be enough to add a passkey
+	if !utils.CheckPwd(server.Credentials{Username: session.Username, Password: body.Password}) {
+		utils.SendJSONError(w, http.StatusForbidden, "Incorrect password!")
+		return
+	}
+	// selected after CheckPwd, which may rehash the password
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.SendJSONError(w, http.StatusUnauthorized, "not logged in")
+		return
+	}
+	options, err := utils.BeginPasskeyRegistration(user)
+	if errors.Is(err, utils.ErrPasskeyLimit) {
+		utils.SendJSONError(w, http.StatusConflict, "You can't add more passkeys, remove one first!")
+		return
+	}
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		utils.SendJSONError(w, http.StatusInternalServerError, "An error has occured!")
+		return
+	}
+	utils.SendJSON(w, http.StatusOK, server.JSONResponse{Data: options})
+}
+
+// passkeyRegisterHandlerPost
+//
+//	@Description: verifies the passkey created by the user's authenticator
+//	(JSON body holding the credential and its `name`) and adds it to their
+//	account.
+func passkeyRegisterHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.SendJSONError(w, http.StatusUnauthorized, "not logged in")
+		return
+	}
+	var body struct {
+		Name string `json:"name"`
+		webauthn.RegistrationResponse
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, passkeyBodySize)).Decode(&body)
+	if err != nil {
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid passkey!")
+		return
+	}
+	err = utils.FinishPasskeyRegistration(user, body.Name, body.RegistrationResponse)
+	switch {
+	case errors.Is(err, utils.ErrPasskeyCeremony):
+		utils.SendJSONError(w, http.StatusBadRequest, "The registration has expired, please try again!")
+	case errors.Is(err, utils.ErrPasskeyLimit):
+		utils.SendJSONError(w, http.StatusConflict, "You can't add more passkeys, remove one first!")
+	case errors.Is(err, utils.ErrPasskeyTaken):
+		utils.SendJSONError(w, http.StatusConflict, "This passkey is already registered!")
+	case err != nil:
+		utils.Logger.Warn(utils.GetCurrentFuncName(), slog.Any("output", err))
+		utils.SendJSONError(w, http.StatusBadRequest, "Invalid passkey!")
+	default:
+		utils.SendJSON(w, http.StatusCreated, server.JSONResponse{Data: "/profile/passkeys?status=added"})
+	}
+}
+
+// accountHandlerGet
+//
+//	@Description: displays the forms to export the user's data or to delete
+//	their account, and possible messages according to the `err` query key.
+func accountHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	var message template.HTML
+	switch r.URL.Query().Get("err") {
+	case "current-pwd":
+		message = "<div class=\"message\">Incorrect password!</div>"
+	case "confirm":
+		message = "<div class=\"message\">Please check the box to confirm the deletion of your account!</div>"
+	case "":
+	default:
+		message = "<div class=\"message\">An error has occured!</div>"
+	}
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+	}
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     template.HTML
+		CSRFToken   string
+	}{
+		IsConnected: true,
+		Username:    user.Username,
+		AvatarImg:   user.Avatar,
+		Message:     message,
+		CSRFToken:   utils.CSRFToken(r),
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/account.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// accountHandlerPost
+//
+//	@Description: account form's treatment handler: once the user's password is
+//	confirmed, sends their data as a JSON file ("export" action) or deletes
+//	their account ("delete" action).
+func accountHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	if !utils.CheckPwd(server.Credentials{Username: session.Username, Password: r.FormValue("password")}) {
+		http.Redirect(w, r, "/profile/account?err=current-pwd", http.StatusSeeOther)
+		return
+	}
+	
+	// selected after CheckPwd, which may rehash the password
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/profile/account?err=internal-error", http.StatusSeeOther)
+		return
+	}
+	
+	switch r.FormValue("action") {
+	case "export":
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"mangathorg-export-"+time.Now().Format("2006-01-02")+".json\"")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(utils.ExportUser(user))
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		}
+	case "delete":
+		if r.FormValue("confirm") != "on" {
+			http.Redirect(w, r, "/profile/account?err=confirm", http.StatusSeeOther)
+			return
+		}
+		utils.DeleteAccount(&w, r, user)
+		http.Redirect(w, r, "/login?status=deleted", http.StatusSeeOther)
+	default:
+		http.Redirect(w, r, "/profile/account?err=action", http.StatusSeeOther)
+	}
+}
+
+// adminHandlerGet
+//
+//	@Description: displays the admin console's list of users, filtered by the
+//	`q` query key (username or email), and possible messages according to the
+//	`err` and `status` query keys.
+func adminHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	var message template.HTML
+	if r.URL.Query().Get("err") == "not-found" {
+		message = "<div class=\"message\">This user doesn't exist anymore!</div>"
+	} else if r.URL.Query().Get("status") == "deleted" {
+		message = "<div class=\"message\">The account has been deleted!</div>"
+	}
+	
+	session, _ := utils.GetSession(r)
+	admin, _ := utils.SelectUser(session.Username)
+	
+	type userItem struct {
+		Id             int
+		Username       string
+		Email          string
+		Role           string
+		Disabled       bool
+		ResetRequired  bool
+		CreationTime   string
+		LastConnection string
+	}
+	
+	query := r.URL.Query().Get("q")
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     template.HTML
+		Query       string
+		Users       []userItem
+		IsAdmin     bool
+	}{
+		IsConnected: true,
+		Username:    admin.Username,
+		AvatarImg:   admin.Avatar,
+		Message:     message,
+		Query:       query,
+		IsAdmin:     utils.HasRole(admin, server.RoleAdmin),
+	}
+	
+	for _, user := range utils.SearchUsers(query) {
+		data.Users = append(data.Users, userItem{
+			Id:             user.Id,
+			Username:       user.Username,
+			Email:          user.Email,
+			Role:           utils.UserRole(user),
+			Disabled:       user.Disabled,
+			ResetRequired:  user.ResetRequired,
+			CreationTime:   user.CreationTime.Format("02/01/2006"),
+			LastConnection: user.LastConnection.Format("02/01/2006 15:04"),
+		})
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/admin.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// adminUserHandlerGet
+//
+//	@Description: displays the details of a user (`id` path value) in the admin
+//	console, with its active sessions, the actions allowed on its account, and
+//	possible messages according to the `err` and `status` query keys.
+func adminUserHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	id, err := strconv.Atoi(r.PathValue("id"))
+	user, ok := utils.SelectUserById(id)
+	if err != nil || !ok {
+		http.Redirect(w, r, "/admin?err=not-found", http.StatusSeeOther)
+		return
+	}
+	
+	var message template.HTML
+	if r.URL.Query().Has("err") {
+		switch r.URL.Query().Get("err") {
+		case "forbidden":
+			message = "<div class=\"message\">You aren't allowed to manage this account!</div>"
+		case "session":
+			message = "<div class=\"message\">This session doesn't exist anymore!</div>"
+		case "role":
+			message = "<div class=\"message\">Unknown role!</div>"
+		case "confirm":
+			message = "<div class=\"message\">Please check the box to confirm the deletion of the account!</div>"
+		default:
+			message = "<div class=\"message\">An error has occured!</div>"
+		}
+	} else if r.URL.Query().Has("status") {
+		switch r.URL.Query().Get("status") {
+		case "disabled":
+			message = "<div class=\"message\">The account has been disabled and logged out!</div>"
+		case "enabled":
+			message = "<div class=\"message\">The account has been enabled!</div>"
+		case "reset":
+			message = "<div class=\"message\">A mail has been sent to set a new password!</div>"
+		case "revoked":
+			message = "<div class=\"message\">The session has been logged out!</div>"
+		case "revoked-all":
+			message = "<div class=\"message\">All the sessions have been logged out!</div>"
+		case "role":
+			message = "<div class=\"message\">The role has been changed!</div>"
+		}
+	}
+	
+	session, _ := utils.GetSession(r)
+	admin, _ := utils.SelectUser(session.Username)
+	
+	type sessionItem struct {
+		ConnectionID int
+		IpAddress    string
+		UserAgent    string
+		CreatedAt    string
+		LastSeen     string
+		Remembered   bool
+	}
+	
+	var data = struct {
+		IsConnected      bool
+		Username         string
+		AvatarImg        string
+		Message          template.HTML
+		User             server.User
+		Role             string
+		TwoFactorEnabled bool
+		CreationTime     string
+		LastConnection   string
+		Sessions         []sessionItem
+		CanManage        bool
+		IsAdmin          bool
+		Roles            []string
+		CSRFToken        string
+	}{
+		IsConnected:      true,
+		Username:         admin.Username,
+		AvatarImg:        admin.Avatar,
+		Message:          message,
+		User:             user,
+		Role:             utils.UserRole(user),
+		TwoFactorEnabled: user.TwoFactor != nil,
+		CreationTime:     user.CreationTime.Format("02/01/2006 15:04"),
+		LastConnection:   user.LastConnection.Format("02/01/2006 15:04"),
+		CanManage:        utils.CanManage(admin, user),
+		IsAdmin:          utils.HasRole(admin, server.RoleAdmin),
+		Roles:            []string{server.RoleUser, server.RoleModerator, server.RoleAdmin},
+		CSRFToken:        utils.CSRFToken(r),
+	}
+	
+	for _, userSession := range utils.UserSessions(user.Id) {
+		data.Sessions = append(data.Sessions, sessionItem{
+			ConnectionID: userSession.ConnectionID,
+			IpAddress:    userSession.IpAddress,
+			UserAgent:    userSession.UserAgent,
+			CreatedAt:    userSession.CreatedAt.Format("02/01/2006 15:04"),
+			LastSeen:     userSession.LastSeen.Format("02/01/2006 15:04"),
+			Remembered:   userSession.RememberSelector != "",
+		})
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/admin-user.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// adminUserHandlerPost
+//
+//	@Description: admin console's treatment handler of a user (`id` path
+//	value): the moderators can disable or enable its account, force it to set a
+//	new password, and log out its sessions; the admins can also change its role
+//	and delete its account.
+func adminUserHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	id, err := strconv.Atoi(r.PathValue("id"))
+	user, ok := utils.SelectUserById(id)
+	if err != nil || !ok {
+		http.Redirect(w, r, "/admin?err=not-found", http.StatusSeeOther)
+		return
+	}
+	
+	session, _ := utils.GetSession(r)
+	admin, _ := utils.SelectUser(session.Username)
+	back := "/admin/users/" + strconv.Itoa(user.Id)
+	if !utils.CanManage(admin, user) {
+		utils.Logger.Warn("Admin action refused", slog.Any("user", session), slog.Int("target_id", user.Id), slog.String("action", r.FormValue("action")))
+		http.Redirect(w, r, back+"?err=forbidden", http.StatusSeeOther)
+		return
+	}
+	utils.Logger.Info("Admin action", slog.Any("user", session), slog.Int("target_id", user.Id), slog.String("action", r.FormValue("action")))
+	
+	switch r.FormValue("action") {
+	case "disable":
+		utils.SetUserDisabled(user, true)
+		http.Redirect(w, r, back+"?status=disabled", http.StatusSeeOther)
+	case "enable":
+		utils.SetUserDisabled(user, false)
+		http.Redirect(w, r, back+"?status=enabled", http.StatusSeeOther)
+	case "reset":
+		utils.ForcePasswordReset(user)
+		http.Redirect(w, r, back+"?status=reset", http.StatusSeeOther)
+	case "revoke-session":
+		connectionID, err := strconv.Atoi(r.FormValue("connection"))
+		if err != nil || !utils.RevokeSession(user.Id, connectionID) {
+			http.Redirect(w, r, back+"?err=session", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, back+"?status=revoked", http.StatusSeeOther)
+	case "revoke-all":
+		utils.RevokeUserSessions(user.Id)
+		http.Redirect(w, r, back+"?status=revoked-all", http.StatusSeeOther)
+	case "role":
+		if !utils.HasRole(admin, server.RoleAdmin) {
+			http.Redirect(w, r, back+"?err=forbidden", http.StatusSeeOther)
+			return
+		}
+		if !utils.SetUserRole(user, r.FormValue("role")) {
+			http.Redirect(w, r, back+"?err=role", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, back+"?status=role", http.StatusSeeOther)
+	case "delete":
+		if !utils.HasRole(admin, server.RoleAdmin) {
+			http.Redirect(w, r, back+"?err=forbidden", http.StatusSeeOther)
+			return
+		}
+		if r.FormValue("confirm") != "on" {
+			http.Redirect(w, r, back+"?err=confirm", http.StatusSeeOther)
+			return
+		}
+		utils.DeleteUser(user)
+		http.Redirect(w, r, "/admin?status=deleted", http.StatusSeeOther)
+	default:
+		http.Redirect(w, r, back+"?err=action", http.StatusSeeOther)
+	}
+}
+
+// adminHealthHandlerGet
+//
+//	@Description: displays the health of MangaDex API and the content of the
+//	cache in the admin console.
+func adminHealthHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, _ := utils.GetSession(r)
+	admin, _ := utils.SelectUser(session.Username)
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Upstream    api2.UpstreamHealth
+		CheckTime   string
+		UploadsURL  string
+		Cache       []api2.CacheStats
+		CacheTotal  int
+	}{
+		IsConnected: true,
+		Username:    admin.Username,
+		AvatarImg:   admin.Avatar,
+		Upstream:    api.CheckUpstream(r.Context()),
+		UploadsURL:  mangadex.UploadsURL,
+		Cache:       api.GetCacheStats(),
+	}
+	data.CheckTime = data.Upstream.CheckTime.Format("02/01/2006 15:04:05")
+	for _, stats := range data.Cache {
+		data.CacheTotal += stats.Fresh + stats.Stale + stats.Expired
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/admin-health.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// logItem is a Log as displayed by the log explorer.
+type logItem struct {
+	Time       string `json:"time"`
+	Level      string `json:"level"`
+	Message    string `json:"msg"`
+	ReqId      int    `json:"req_id,omitempty"`
+	Username   string `json:"username,omitempty"`
+	ClientIP   string `json:"client_ip,omitempty"`
+	ReqMethod  string `json:"req_method,omitempty"`
+	ReqURL     string `json:"req_url,omitempty"`
+	HttpStatus int    `json:"http_status,omitempty"`
+	ErrOutput  string `json:"output,omitempty"`
+}
+
+// newLogItem
+//
+//	@Description: formats the `entry` for the log explorer.
+//	@param entry
+//	@return logItem
+func newLogItem(entry utils.Log) logItem {
+	return logItem{
+		Time:       entry.Time.Local().Format("02/01/2006 15:04:05"),
+		Level:      entry.Level,
+		Message:    entry.Message,
+		ReqId:      entry.ReqId,
+		Username:   entry.User.Username,
+		ClientIP:   entry.ClientIP,
+		ReqMethod:  entry.ReqMethod,
+		ReqURL:     entry.ReqURL,
+		HttpStatus: entry.HttpStatus,
+		ErrOutput:  entry.ErrOutput,
+	}
+}
+
+// adminLogsHandlerGet
+//
+//	@Description: displays a page of the log explorer, filtered according to
+//	the `from`, `to`, `level`, `user`, `ip`, `url` and `req_id` query keys and
+//	paginated with the `page` query key. The page is sent as JSON with the
+//	`format=json` query key.
+func adminLogsHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	query := r.URL.Query()
+	var message template.HTML
+	var result = utils.LogPage{Page: 1}
+	filter, err := utils.ParseLogFilter(query)
+	if err != nil {
+		if query.Get("format") == "json" {
+			utils.SendJSONError(w, http.StatusBadRequest, "invalid filter")
+			return
+		}
+		message = "<div class=\"message\">Invalid filter!</div>"
+	} else {
+		page, _ := strconv.Atoi(query.Get("page"))
+		result, err = utils.SearchLogs(filter, page)
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+			message = "<div class=\"message\">An error has occured!</div>"
+		}
+	}
+	
+	var items = []logItem{}
+	for _, entry := range result.Logs {
+		items = append(items, newLogItem(entry))
+	}
+	
+	if query.Get("format") == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(struct {
+			Page    int       `json:"page"`
+			HasNext bool      `json:"has_next"`
+			Logs    []logItem `json:"logs"`
+		}{result.Page, result.HasNext, items})
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		}
+		return
+	}
+	
+	// the links to the other pages keep the filter
+	query.Del("page")
+	query.Del("format")
+	var previous, next string
+	if result.Page > 1 {
+		query.Set("page", strconv.Itoa(result.Page-1))
+		previous = "/admin/logs?" + query.Encode()
+	}
+	if result.HasNext {
+		query.Set("page", strconv.Itoa(result.Page+1))
+		next = "/admin/logs?" + query.Encode()
+	}
+	query.Del("page")
+	
+	session, _ := utils.GetSession(r)
+	admin, _ := utils.SelectUser(session.Username)
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     template.HTML
+		Filter      url.Values
+		Levels      []string
+		Logs        []logItem
+		Page        int
+		Previous    string
+		Next        string
+		StreamURL   string
+	}{
+		IsConnected: true,
+		Username:    admin.Username,
+		AvatarImg:   admin.Avatar,
+		Message:     message,
+		Filter:      query,
+		Levels:      []string{"DEBUG", "INFO", "WARN", "ERROR"},
+		Logs:        items,
+		Page:        result.Page,
+		Previous:    previous,
+		Next:        next,
+		StreamURL:   "/admin/logs/stream?" + query.Encode(),
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/admin-logs.gohtml", utils.Path+"templates/admin-logs.js.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// logStreamHeartbeat is the time between two comments sent to keep the live
+// tail's connection open. The client's session is checked at the same time.
+const logStreamHeartbeat = time.Second * 20
+
+// adminLogsStreamHandlerGet
+//
+//	@Description: sends the new Log matching the filter of the query keys (see
+//	adminLogsHandlerGet) as Server-Sent Events, until the client disconnects or
+//	its session ends.
+func adminLogsStreamHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
 	
-	user, ok := utils.SelectUser(session.Username)
+	filter, err := utils.ParseLogFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, "Invalid filter", http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
 	if !ok {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
-		http.Redirect(w, r, "/profile?err=internal-error", http.StatusSeeOther)
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
 		return
 	}
 	
-	if password != "" && newPassword != "" && confirmPassword != "" {
-		if !utils.CheckPwd(server.Credentials{Username: session.Username, Password: password}) {
-			http.Redirect(w, r, "/profile?err=current-pwd", http.StatusSeeOther)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	
+	entries, unsubscribe := utils.SubscribeLogs()
+	defer unsubscribe()
+	heartbeat := time.NewTicker(logStreamHeartbeat)
+	defer heartbeat.Stop()
+	
+	for {
+		select {
+		case <-r.Context().Done():
 			return
+		case <-heartbeat.C:
+			if !utils.CheckSession(r) {
+				return
+			}
+			_, err = w.Write([]byte(": heartbeat\n\n"))
+		case entry := <-entries:
+			if !filter.Match(entry) {
+				continue
+			}
+			var data []byte
+			data, err = json.Marshal(newLogItem(entry))
+			if err == nil {
+				_, err = w.Write([]byte("data: " + string(data) + "\n\n"))
+			}
 		}
-		if newPassword != confirmPassword {
-			http.Redirect(w, r, "/profile?err=match", http.StatusSeeOther)
-			return
-		} else if !utils.CheckPasswd(newPassword) {
-			http.Redirect(w, r, "/profile?err=password", http.StatusSeeOther)
+		if err != nil {
 			return
 		}
-		user.HashedPwd, user.Salt = utils.NewPwd(newPassword)
-	} else if user.Avatar == avatar {
-		http.Redirect(w, r, "/profile?status=nothing", http.StatusSeeOther)
-		return
+		flusher.Flush()
 	}
-	
-	user.Avatar = avatar
-	utils.UpdateUser(user)
-	
-	http.Redirect(w, r, "/profile?status=updated", http.StatusSeeOther)
 }
 
 // homeHandlerGet
@@ -475,6 +1924,12 @@ func homeHandlerGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
+	// only the latest read mangas are displayed in the "Continue reading" list
+	readings := user.Readings
+	if len(readings) > 6 {
+		readings = readings[:6]
+	}
+	
 	var data = struct {
 		Order        string
 		Path         string
@@ -484,9 +1939,12 @@ func homeHandlerGet(w http.ResponseWriter, r *http.Request) {
 		AvatarImg    string
 		HasBanner    bool
 		Banner       api2.MangaUsefullData
+		HasReadings  bool
+		Readings     []api2.MangaUsefullData
 		HasFavorites bool
 		Favorites    []api2.MangaUsefullData
 		BaseURL      string
+		CSRFToken    string
 	}{
 		Order:        "desc",
 		Path:         "static",
@@ -494,11 +1952,17 @@ func homeHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Username:     user.Username,
 		Email:        user.Email,
 		AvatarImg:    user.Avatar,
-		Banner:       api.FetchMangaById(user.MangaBanner.Id, "desc", 0),
-		Favorites:    api.FetchMangasById(user.Favorites, "desc", 0),
+		Banner:       api.FetchMangaById(r.Context(), user.MangaBanner.Id, "desc", 0),
+		Readings:     api.FetchMangasById(r.Context(), readings, "desc", 0),
+		Favorites:    api.FetchMangasById(r.Context(), user.Favorites, "desc", 0),
 		BaseURL:      utils.BaseURL,
+		CSRFToken:    utils.CSRFToken(r),
 	}
 	
+	_ = api.AddFavoriteInfo(r, &data.Readings)
+	_ = api.AddFavoriteInfo(r, &data.Favorites)
+	
+	data.HasReadings = data.Readings != nil && len(data.Readings) > 0
 	data.HasFavorites = data.Favorites != nil && len(data.Favorites) > 0
 	data.HasBanner = !reflect.DeepEqual(data.Banner, api2.MangaUsefullData{})
 	
@@ -512,34 +1976,199 @@ func homeHandlerGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// TESTING only.
-//
-// logHandlerGet
+// historyHandlerGet
 //
-//	@Description: fetches the logs according to the query sent.
-func logHandlerGet(w http.ResponseWriter, r *http.Request) {
+//	@Description: displays the user's reading history (paginated).
+func historyHandlerGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(utils.GetCurrentFuncName())
-	w.Header().Set("Content-Type", "application/json")
-	if r.URL.Query().Has("level") {
-		err := json.NewEncoder(w).Encode(utils.FetchAttrLogs("level", r.URL.Query().Get("level")))
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-			return
-		}
+	
+	session, _ := utils.GetSession(r)
+	user, ok := utils.SelectUser(session.Username)
+	if !ok {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Redirect(w, r, "/login?err=restricted", http.StatusSeeOther)
 		return
-	} else if r.URL.Query().Has("user") {
-		err := json.NewEncoder(w).Encode(utils.FetchAttrLogs("user", r.URL.Query().Get("user")))
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-			return
+	}
+	
+	var pagination string
+	if r.URL.Query().Has("pag") {
+		pagination = r.URL.Query().Get("pag")
+	} else {
+		pagination = "1"
+	}
+	pag, errAtoi := strconv.Atoi(pagination)
+	if errAtoi != nil || pag < 1 {
+		pag = 1
+	}
+	
+	totalPages := len(user.History) / 30
+	if len(user.History)%30 > 0 {
+		totalPages++
+	}
+	if totalPages > 0 && pag > totalPages {
+		pag = totalPages
+	}
+	
+	start := (pag - 1) * 30
+	end := start + 30
+	if end > len(user.History) {
+		end = len(user.History)
+	}
+	entries := user.History[start:end]
+	
+	// fetching every manga only once
+	var mangaUsers []server.MangaUser
+	for _, entry := range entries {
+		if !slices.ContainsFunc(mangaUsers, func(manga server.MangaUser) bool { return manga.Id == entry.MangaId }) {
+			mangaUsers = append(mangaUsers, server.MangaUser{Id: entry.MangaId})
 		}
-		return
 	}
-	err := json.NewEncoder(w).Encode(utils.RetrieveLogs())
+	mangas := api.FetchMangasById(r.Context(), mangaUsers, "desc", 0)
+	
+	type historyItem struct {
+		Entry  server.HistoryEntry
+		Manga  api2.MangaUsefullData
+		Day    string
+		NewDay bool
+		Hour   string
+	}
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		HasHistory  bool
+		History     []historyItem
+		CurrentPage int
+		TotalPages  int
+		Previous    int
+		Next        int
+		BaseURL     string
+		CSRFToken   string
+	}{
+		IsConnected: true,
+		Username:    user.Username,
+		AvatarImg:   user.Avatar,
+		HasHistory:  len(entries) > 0,
+		CurrentPage: pag,
+		TotalPages:  totalPages,
+		Previous:    pag - 1,
+		Next:        pag + 1,
+		BaseURL:     utils.BaseURL,
+		CSRFToken:   utils.CSRFToken(r),
+	}
+	
+	var previousDay string
+	for _, entry := range entries {
+		item := historyItem{
+			Entry: entry,
+			Day:   entry.Time.Format("Monday 02 January 2006"),
+			Hour:  entry.Time.Format("15:04"),
+		}
+		item.NewDay = item.Day != previousDay
+		previousDay = item.Day
+		for _, manga := range mangas {
+			if manga.Id == entry.MangaId {
+				item.Manga = manga
+				break
+			}
+		}
+		data.History = append(data.History, item)
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/history.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/base.gohtml", utils.Path+"templates/history.js.gohtml")
 	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// historyHandlerPut
+//
+//	@Description: updates the page reached in a history entry according to the
+//	entryId sent in the URL and the `page` form value.
+func historyHandlerPut(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	entryId := r.PathValue("entryId")
+	
+	session, sessionId := utils.GetSession(r)
+	
+	if sessionId == "" {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("sessionId not found")))
+		http.Error(w, "restricted access: you need a valid session to proceed", http.StatusUnauthorized)
+		return
+	}
+	
+	page, err := strconv.Atoi(r.FormValue("page"))
+	if entryId == "" || err != nil || page < 1 {
+		http.Error(w, "you need to provide an entryId and a valid page", http.StatusBadRequest)
+		return
+	}
+	
+	if !utils.UpdateHistoryPage(session.Username, entryId, page) {
+		http.Error(w, "the entry was not found in the history", http.StatusNotFound)
+		return
+	}
+	
+	w.Header().Set("result", "History updated successfully")
+	w.WriteHeader(http.StatusOK)
+}
+
+// historyHandlerDelete
+//
+//	@Description: removes a single entry from the user's history according to the
+//	entryId sent in the URL.
+func historyHandlerDelete(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	entryId := r.PathValue("entryId")
+	
+	session, sessionId := utils.GetSession(r)
+	
+	if sessionId == "" {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("sessionId not found")))
+		http.Error(w, "restricted access: you need a valid session to proceed", http.StatusUnauthorized)
+		return
+	}
+	
+	if entryId == "" {
+		http.Error(w, "you need to provide an entryId", http.StatusNotFound)
+		return
+	}
+	
+	if !utils.DeleteHistoryEntry(session.Username, entryId) {
+		http.Error(w, "the entry was not found in the history", http.StatusNotFound)
+		return
+	}
+	
+	w.Header().Set("result", "History entry deleted successfully")
+	w.WriteHeader(http.StatusOK)
+}
+
+// historyClearHandlerDelete
+//
+//	@Description: clears the user's whole reading history.
+func historyClearHandlerDelete(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	
+	session, sessionId := utils.GetSession(r)
+	
+	if sessionId == "" {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("sessionId not found")))
+		http.Error(w, "restricted access: you need a valid session to proceed", http.StatusUnauthorized)
 		return
 	}
+	
+	if !utils.ClearHistory(session.Username) {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		http.Error(w, "restricted access: you need a valid user to proceed", http.StatusUnauthorized)
+		return
+	}
+	
+	w.Header().Set("result", "History cleared successfully")
+	w.WriteHeader(http.StatusOK)
 }
 
 // confirmHandlerGet
@@ -549,7 +2178,10 @@ func confirmHandlerGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(utils.GetCurrentFuncName())
 	if r.URL.Query().Has("id") {
 		id := r.URL.Query().Get("id")
-		utils.PushTempUser(id)
+		if !utils.PushTempUser(id) {
+			invalidLinkHandler(w, "/register")
+			return
+		}
 		
 		tmpl, err := template.ParseFiles(utils.Path+"templates/confirm.gohtml", utils.Path+"templates/base.gohtml")
 		if err != nil {
@@ -562,6 +2194,137 @@ func confirmHandlerGet(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// confirmEmailHandlerGet
+//
+//	@Description: displays the confirmation of the email address change which
+//	link has been sent to the new address (the link itself doesn't change
+//	anything, so that it can't be used by the mail clients opening the links
+//	in advance).
+func confirmEmailHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	id := r.URL.Query().Get("id")
+	change, ok := utils.CheckEmailChange(id)
+	if !ok {
+		invalidLinkHandler(w, "/profile")
+		return
+	}
+	emailLinkPage(w, r, "/confirm-email", id, "Use "+change.User.Email+" as the email address of "+change.User.Username+"?", "Confirm")
+}
+
+// confirmEmailHandlerPost
+//
+//	@Description: applies the email address change which confirmation link has
+//	been sent to the new address.
+func confirmEmailHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if !utils.ConfirmEmailChange(r.FormValue("id")) {
+		invalidLinkHandler(w, "/profile")
+		return
+	}
+	emailChangeRedirect(w, r, "email-updated")
+}
+
+// cancelEmailHandlerGet
+//
+//	@Description: displays the confirmation of the cancellation of the email
+//	address change which notice has been sent to the former address.
+func cancelEmailHandlerGet(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	id := r.URL.Query().Get("id")
+	notice, ok := utils.CheckEmailCancel(id)
+	if !ok {
+		invalidLinkHandler(w, "/profile")
+		return
+	}
+	emailLinkPage(w, r, "/cancel-email", id, "Keep "+notice.User.Email+" as the email address of "+notice.User.Username+"? All the account's sessions will be logged out.", "Cancel the change")
+}
+
+// cancelEmailHandlerPost
+//
+//	@Description: cancels the email address change which notice has been sent
+//	to the former address.
+func cancelEmailHandlerPost(w http.ResponseWriter, r *http.Request) {
+	log.Println(utils.GetCurrentFuncName())
+	if !utils.CancelEmailChange(r.FormValue("id")) {
+		invalidLinkHandler(w, "/profile")
+		return
+	}
+	emailChangeRedirect(w, r, "email-cancelled")
+}
+
+// emailLinkPage
+//
+//	@Description: displays the form applying the email address change link
+//	which ConfirmID is the `id` param.
+//	@param w
+//	@param r
+//	@param action: the route the form is sent to.
+//	@param id
+//	@param question
+//	@param button: the submit button's text.
+func emailLinkPage(w http.ResponseWriter, r *http.Request, action string, id string, question string, button string) {
+	var data = struct {
+		Action    string
+		Id        string
+		Question  string
+		Button    string
+		CSRFToken string
+	}{
+		Action:    action,
+		Id:        id,
+		Question:  question,
+		Button:    button,
+		CSRFToken: utils.CSRFToken(r),
+	}
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/email-link-confirm.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// emailChangeRedirect
+//
+//	@Description: redirects to the profile page if the client is logged, or to
+//	the login page if not, with the `status` query key.
+//	@param w
+//	@param r
+//	@param status
+func emailChangeRedirect(w http.ResponseWriter, r *http.Request, status string) {
+	if utils.CheckSession(r) {
+		http.Redirect(w, r, "/profile?status="+status, http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/login?status="+status, http.StatusSeeOther)
+}
+
+// invalidLinkHandler
+//
+//	@Description: displays the page of an expired or invalid link sent by mail.
+//	@param w
+//	@param retry: the URL of the page to ask for a new link.
+func invalidLinkHandler(w http.ResponseWriter, retry string) {
+	w.WriteHeader(http.StatusNotFound)
+	utils.Logger.Warn("invalidLinkHandler", slog.Int("req_id", middlewares.LogId), slog.Int("http_status", http.StatusNotFound))
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/invalid-link.gohtml", utils.Path+"templates/base.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	var data = struct {
+		Retry string
+	}{
+		Retry: retry,
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
 // logoutHandlerGet
 //
 //	@Description: logs the user out.
@@ -588,11 +2351,13 @@ func principalHandlerGet(w http.ResponseWriter, r *http.Request) {
 		LatestUploaded []api2.MangaUsefullData
 		Popular        []api2.MangaUsefullData
 		BaseURL        string
+		CSRFToken      string
 	}{
-		Banner:         api.FetchMangaById("cb676e05-8e6e-4ec4-8ba0-d3cb4f033cfa", "asc", 1),
-		LatestUploaded: api.FetchManga(api.TopLatestUploadedRequest).Mangas,
-		Popular:        api.FetchManga(api.TopPopularRequest).Mangas,
+		Banner:         api.FetchMangaById(r.Context(), "cb676e05-8e6e-4ec4-8ba0-d3cb4f033cfa", "asc", 1),
+		LatestUploaded: api.FetchManga(r.Context(), api.TopLatestUploadedRequest).Mangas,
+		Popular:        api.FetchManga(r.Context(), api.TopPopularRequest).Mangas,
 		BaseURL:        utils.BaseURL,
+		CSRFToken:      utils.CSRFToken(r),
 	}
 	
 	session, _ := utils.GetSession(r)
@@ -649,7 +2414,7 @@ func mangaHandlerGet(w http.ResponseWriter, r *http.Request) {
 		log.Fatalln(err)
 	}
 	offset := (pag - 1) * 15
-	manga := api.FetchMangaById(mangaId, order, offset)
+	manga := api.FetchMangaById(r.Context(), mangaId, order, offset)
 	var pages []int
 	pageMax := manga.NbChapter / 15
 	if manga.NbChapter%15 > 0 {
@@ -667,12 +2432,14 @@ func mangaHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Pages       []int
 		Order       string
 		BaseURL     string
+		CSRFToken   string
 	}{
 		Manga:       manga,
 		CurrentPage: pag,
 		Pages:       pages,
 		Order:       order,
 		BaseURL:     utils.BaseURL,
+		CSRFToken:   utils.CSRFToken(r),
 	}
 	
 	//  check if the manga was found, and if not, show the error404 page.
@@ -710,7 +2477,7 @@ func coverHandlerGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "image/jpeg")
-	_, err := w.Write(api.ImageProxy(mangaId, img))
+	_, err := w.Write(api.ImageProxy(r.Context(), mangaId, img))
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		http.Error(w, "cover image not found", http.StatusNotFound)
@@ -733,7 +2500,7 @@ func scanHandlerGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	w.Header().Set("Content-Type", "image/jpeg")
-	i, err := w.Write(api.ScanProxy(chapterId, quality, hash, img))
+	i, err := w.Write(api.ScanProxy(r.Context(), chapterId, quality, hash, img))
 	if err != nil || i == 0 {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		http.Error(w, "scan image not found", http.StatusNotFound)
@@ -762,23 +2529,27 @@ func favoriteHandlerPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, ok := utils.SelectUser(session.Username)
+	var present bool
+	_, ok := utils.ModifyUser(session.Username, func(user *server.User) bool {
+		for _, favorite := range user.Favorites {
+			if mangaId == favorite.Id {
+				present = true
+				return false
+			}
+		}
+		user.Favorites = append(user.Favorites, server.MangaUser{Id: mangaId})
+		return true
+	})
 	if !ok {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
 		http.Error(w, "restricted access: you need a valid user to proceed", http.StatusUnauthorized)
 		return
 	}
-	
-	for _, favorite := range user.Favorites {
-		if mangaId == favorite.Id {
-			http.Error(w, "the manga is already present in the favorites", http.StatusConflict)
-			return
-		}
+	if present {
+		http.Error(w, "the manga is already present in the favorites", http.StatusConflict)
+		return
 	}
 	
-	user.Favorites = append(user.Favorites, server.MangaUser{Id: mangaId})
-	utils.UpdateUser(user)
-	
 	w.Header().Set("result", "Manga added successfully")
 	w.WriteHeader(http.StatusOK)
 }
@@ -805,21 +2576,26 @@ func favoriteHandlerDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, ok := utils.SelectUser(session.Username)
+	var found bool
+	_, ok := utils.ModifyUser(session.Username, func(user *server.User) bool {
+		for i, favorite := range user.Favorites {
+			if mangaId == favorite.Id {
+				user.Favorites = append(user.Favorites[:i], user.Favorites[i+1:]...)
+				found = true
+				return true
+			}
+		}
+		return false
+	})
 	if !ok {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
 		http.Error(w, "restricted access: you need a valid user to proceed", http.StatusUnauthorized)
 		return
 	}
-	
-	for i, favorite := range user.Favorites {
-		if mangaId == favorite.Id {
-			user.Favorites = append(user.Favorites[:i], user.Favorites[i+1:]...)
-			utils.UpdateUser(user)
-			w.Header().Set("result", "Manga deleted successfully")
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	if found {
+		w.Header().Set("result", "Manga deleted successfully")
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 	
 	w.WriteHeader(http.StatusNotFound)
@@ -848,24 +2624,29 @@ func bannerHandlerPut(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	user, ok := utils.SelectUser(session.Username)
+	var found bool
+	_, ok := utils.ModifyUser(session.Username, func(user *server.User) bool {
+		for _, favorite := range user.Favorites {
+			if mangaId == favorite.Id {
+				user.MangaBanner = server.MangaUser{
+					Id:              mangaId,
+					LastChapterRead: favorite.LastChapterRead,
+				}
+				found = true
+				return true
+			}
+		}
+		return false
+	})
 	if !ok {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
 		http.Error(w, "restricted access: you need a valid user to proceed", http.StatusUnauthorized)
 		return
 	}
-	
-	for _, favorite := range user.Favorites {
-		if mangaId == favorite.Id {
-			user.MangaBanner = server.MangaUser{
-				Id:              mangaId,
-				LastChapterRead: favorite.LastChapterRead,
-			}
-			utils.UpdateUser(user)
-			w.Header().Set("result", "Banner updated successfully")
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	if found {
+		w.Header().Set("result", "Banner updated successfully")
+		w.WriteHeader(http.StatusOK)
+		return
 	}
 	
 	w.WriteHeader(http.StatusNotFound)
@@ -889,12 +2670,12 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	tmpl, err := template.ParseFiles(utils.Path+"templates/chapter.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/base.gohtml")
+	tmpl, err := template.ParseFiles(utils.Path+"templates/chapter.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/base.gohtml", utils.Path+"templates/reading.js.gohtml")
 	if err != nil {
 		log.Fatalln(err)
 	}
 	
-	scan := api.ScanRequest(chapterId)
+	scan := api.ScanRequest(r.Context(), chapterId)
 	
 	var apiMangaFeed api2.ApiMangaFeed
 	offset, errConv := strconv.Atoi(offsetString)
@@ -916,7 +2697,7 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 	query.Add("limit", strconv.Itoa(limit))
 	query.Add("offset", strconv.Itoa(reqOffset))
 	
-	err = apiMangaFeed.SendRequest(api.BaseApiURL, "manga/"+mangaId+"/feed", query)
+	err = apiMangaFeed.SendRequest(r.Context(), api.BaseApiURL, "manga/"+mangaId+"/feed", query)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
@@ -976,6 +2757,9 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Id              string
 		Quality         string
 		Alt             string
+		HistoryId       string
+		BaseURL         string
+		CSRFToken       string
 		Scan            struct {
 			Hash      string
 			Data      []string
@@ -983,7 +2767,7 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 		}
 	}{
 		MangaId:         mangaId,
-		Manga:           api.FetchMangaById(mangaId, "desc", 0).Title,
+		Manga:           api.FetchMangaById(r.Context(), mangaId, "desc", 0).Title,
 		ChapterNb:       chapterNb,
 		IsPrevious:      isPrevious,
 		IsNext:          isNext,
@@ -993,6 +2777,8 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Id:              chapterId,
 		Quality:         "data",
 		Alt:             "",
+		BaseURL:         utils.BaseURL,
+		CSRFToken:       utils.CSRFToken(r),
 		Scan: struct {
 			Hash      string
 			Data      []string
@@ -1008,6 +2794,24 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 	if sessionId != "" {
 		data.IsConnected = true
 		data.Username = session.Username
+		
+		// recording the user's reading progress
+		utils.UpdateReadingProgress(session.Username, server.MangaUser{
+			Id:                mangaId,
+			LastChapterRead:   chapterId,
+			LastChapterNb:     chapterNb,
+			LastChapterOffset: offset,
+			LastReadTime:      time.Now(),
+		})
+		
+		// adding the chapter to the user's reading history
+		data.HistoryId = utils.AddHistoryEntry(session.Username, server.HistoryEntry{
+			MangaId:   mangaId,
+			ChapterId: chapterId,
+			ChapterNb: chapterNb,
+			Offset:    offset,
+			Time:      time.Now(),
+		})
 	}
 	
 	user, ok := utils.SelectUser(session.Username)
@@ -1039,7 +2843,7 @@ func chapterHandlerGet(w http.ResponseWriter, r *http.Request) {
 func tagsHandlerGet(w http.ResponseWriter, r *http.Request) {
 	log.Println(utils.GetCurrentFuncName())
 	
-	sortedTags := api.FetchSortedTags()
+	sortedTags := api.FetchSortedTags(r.Context())
 	
 	var data = struct {
 		IsConnected bool
@@ -1137,16 +2941,18 @@ func categoryHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Previous    int
 		Next        int
 		BaseURL     string
+		CSRFToken   string
 	}{
 		AvatarImg:   "avatar.jpg",
 		Path:        "../static",
-		Name:        api.TagSelect(tagId).Attributes.Name.En,
-		Response:    api.FetchManga(request),
+		Name:        api.TagSelect(r.Context(), tagId).Attributes.Name.En,
+		Response:    api.FetchManga(r.Context(), request),
 		CurrentPage: pag,
 		Order:       order,
 		Previous:    pag - 1,
 		Next:        pag + 1,
 		BaseURL:     utils.BaseURL,
+		CSRFToken:   utils.CSRFToken(r),
 	}
 	
 	session, _ := utils.GetSession(r)
@@ -1209,42 +3015,8 @@ func categoryNameHandlerGet(w http.ResponseWriter, r *http.Request) {
 	}
 	offset := (pag - 1) * 18
 	
-	var request api2.MangaRequest
-	
-	if group == "public" && slices.Contains(api2.MangaPublic, name) {
-		request = api2.MangaRequest{
-			OrderType:  "rating",
-			OrderValue: order,
-			Public:     []string{name},
-			Limit:      18,
-			Offset:     offset,
-		}
-	} else if group == "status" && slices.Contains(api2.MangaStatus, name) {
-		request = api2.MangaRequest{
-			OrderType:  "rating",
-			OrderValue: order,
-			Status:     []string{name},
-			Limit:      18,
-			Offset:     offset,
-		}
-	} else if group == "special" {
-		if name == "latest-updates" {
-			request = api2.MangaRequest{
-				OrderType:  "latestUploadedChapter",
-				OrderValue: order,
-				Limit:      18,
-				Offset:     offset,
-			}
-			name = "latest uploaded"
-		} else if name == "popular" {
-			request = api2.MangaRequest{
-				OrderType:  "rating",
-				OrderValue: order,
-				Limit:      18,
-				Offset:     offset,
-			}
-		}
-	} else {
+	request, name, ok := categoryNameRequest(group, name, order, offset)
+	if !ok {
 		http.Redirect(w, r, "/error404", http.StatusNotFound)
 		return
 	}
@@ -1262,16 +3034,18 @@ func categoryNameHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Previous    int
 		Next        int
 		BaseURL     string
+		CSRFToken   string
 	}{
 		AvatarImg:   "avatar.jpg",
 		Path:        "../../static",
 		Name:        strings.ToTitle(group) + ": " + name,
-		Response:    api.FetchManga(request),
+		Response:    api.FetchManga(r.Context(), request),
 		CurrentPage: pag,
 		Order:       order,
 		Previous:    pag - 1,
 		Next:        pag + 1,
 		BaseURL:     utils.BaseURL,
+		CSRFToken:   utils.CSRFToken(r),
 	}
 	
 	session, _ := utils.GetSession(r)
@@ -1322,6 +3096,7 @@ func searchHandlerGet(w http.ResponseWriter, r *http.Request) {
 		Next            int
 		Req             string
 		BaseURL         string
+		CSRFToken       string
 	}
 	
 	if r.URL.Query().Has("q") {
@@ -1341,27 +3116,9 @@ func searchHandlerGet(w http.ResponseWriter, r *http.Request) {
 		}
 		offset := (pag - 1) * 18
 		
-		var request api2.MangaRequest
-		
-		request = api2.MangaRequest{
-			OrderType:      "rating",
-			OrderValue:     r.URL.Query().Get("order"),
-			IncludedTags:   r.URL.Query()["includedTags[]"],
-			ExcludedTags:   r.URL.Query()["excludedTags[]"],
-			Title:          r.URL.Query().Get("title"),
-			Author:         r.URL.Query().Get("author"),
-			AuthorOrArtist: r.URL.Query().Get("authorOrArtist"),
-			Status:         r.URL.Query()["status[]"],
-			Public:         r.URL.Query()["public[]"],
-			Limit:          18,
-			Offset:         offset,
-		}
+		request := searchRequest(r.URL.Query(), offset)
 		log.Printf("request: %#v\n", request)
 		
-		if request.OrderValue != "asc" && request.OrderValue != "desc" {
-			request.OrderValue = "desc"
-		}
-		
 		var query string
 		query += "?q=Search"
 		query += "&title=" + request.Title
@@ -1397,20 +3154,22 @@ func searchHandlerGet(w http.ResponseWriter, r *http.Request) {
 			Next            int
 			Req             string
 			BaseURL         string
+			CSRFToken       string
 		}{
 			ExpandedFilters: r.URL.Query().Has("option"),
 			IsConnected:     data.IsConnected,
 			Username:        data.Username,
 			AvatarImg:       "avatar.jpg",
-			Tags:            api.FetchSortedTags(),
+			Tags:            api.FetchSortedTags(r.Context()),
 			Path:            "../static",
-			Response:        api.FetchManga(request),
+			Response:        api.FetchManga(r.Context(), request),
 			CurrentPage:     pag,
 			Order:           request.OrderValue,
 			Previous:        pag - 1,
 			Next:            pag + 1,
 			Req:             query,
 			BaseURL:         utils.BaseURL,
+			CSRFToken:       utils.CSRFToken(r),
 		}
 		
 		session, _ := utils.GetSession(r)
@@ -1445,12 +3204,13 @@ func searchHandlerGet(w http.ResponseWriter, r *http.Request) {
 			Next            int
 			Req             string
 			BaseURL         string
+			CSRFToken       string
 		}{
 			ExpandedFilters: r.URL.Query().Has("option"),
 			IsConnected:     data.IsConnected,
 			Username:        data.Username,
 			AvatarImg:       "avatar.jpg",
-			Tags:            api.FetchSortedTags(),
+			Tags:            api.FetchSortedTags(r.Context()),
 			Path:            "../static",
 			IsResponse:      false,
 			Response:        api2.MangasInBulk{},
@@ -1461,6 +3221,7 @@ func searchHandlerGet(w http.ResponseWriter, r *http.Request) {
 			Next:            1,
 			Req:             "",
 			BaseURL:         utils.BaseURL,
+			CSRFToken:       utils.CSRFToken(r),
 		}
 		
 		session, _ := utils.GetSession(r)
@@ -0,0 +1,448 @@
+package controllers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/models/server"
+	"mangathorg/internal/utils"
+	"mangathorg/internal/webauthn"
+	"mangathorg/internal/webauthn/soft"
+	"mangathorg/router"
+)
+
+// testServer serves the router of the website.
+var testServer *httptest.Server
+
+// TestMain runs the tests in a temporary directory, holding the JSON user
+// store, the database of the pending users and a link to the templates.
+func TestMain(m *testing.M) {
+	templates, err := filepath.Abs("../templates")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	dir, err := os.MkdirTemp("", "mangathorg-controllers")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = os.Symlink(templates, filepath.Join(dir, "templates"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	os.Setenv("SESSION_STORE", "memory")
+	utils.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	utils.InitUsers()
+	utils.InitSessions()
+	utils.InitPendingUsers()
+	router.Init()
+	testServer = httptest.NewServer(router.Mux)
+	utils.BaseURL = testServer.URL
+	utils.InitPasskeys()
+	
+	code := m.Run()
+	testServer.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// csrfRegexp finds the CSRF token in the forms and the scripts of a page.
+var csrfRegexp = regexp.MustCompile(`(?:name="csrf_token" value="|"X-CSRF-Token": ")([^"]+)"`)
+
+// newClient returns a browser keeping its cookies and not following the
+// redirections.
+func newClient(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+// csrfToken
+//
+//	@Description: opens the page of the `path` and returns its CSRF token.
+//	@param t
+//	@param c
+//	@param path
+//	@return string
+func csrfToken(t *testing.T, c *http.Client, path string) string {
+	res, err := c.Get(testServer.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := csrfRegexp.FindSubmatch(body)
+	if match == nil {
+		t.Fatalf("no CSRF token on %s (status %d)", path, res.StatusCode)
+	}
+	return string(match[1])
+}
+
+// postJSON
+//
+//	@Description: sends the `body` in JSON to the `path`, as the scripts of
+//	the website do, and decodes the JSON response's data in the `data` param.
+//	@param t
+//	@param c
+//	@param path
+//	@param token: the CSRF token.
+//	@param body
+//	@param data: nil to ignore the response's data.
+//	@return int: the status code.
+func postJSON(t *testing.T, c *http.Client, path string, token string, body any, data any) int {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, testServer.URL+path, bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-CSRF-Token", token)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	
+	if data == nil || res.StatusCode >= http.StatusBadRequest {
+		return res.StatusCode
+	}
+	err = json.NewDecoder(res.Body).Decode(&server.JSONResponse{Data: data})
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	return res.StatusCode
+}
+
+// newUser creates a user whose password is the `password` param.
+func newUser(username string, password string) server.User {
+	user := server.User{
+		Id:           utils.GetIdNewUser(),
+		Username:     username,
+		Email:        username + "@example.com",
+		HashedPwd:    utils.NewPwd(password),
+		CreationTime: time.Now(),
+	}
+	utils.CreateUser(user)
+	return user
+}
+
+// logIn
+//
+//	@Description: logs the `username` in with the password form and returns
+//	their browser.
+//	@param t
+//	@param username
+//	@param password
+//	@return *http.Client
+func logIn(t *testing.T, username string, password string) *http.Client {
+	c := newClient(t)
+	token := csrfToken(t, c, "/login")
+	res, err := c.PostForm(testServer.URL+"/login", url.Values{"csrf_token": {token}, "username": {username}, "password": {password}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.Header.Get("Location") != "/home" {
+		t.Fatalf("login redirected to %q", res.Header.Get("Location"))
+	}
+	return c
+}
+
+// passkeyRegistration is the body sent to /profile/passkeys/register.
+type passkeyRegistration struct {
+	Name string `json:"name"`
+	webauthn.RegistrationResponse
+}
+
+// passkeyAssertion is the body sent to /login/passkey.
+type passkeyAssertion struct {
+	Remember bool `json:"remember"`
+	webauthn.AssertionResponse
+}
+
+// addPasskey
+//
+//	@Description: registers a passkey of the authenticator `a` from the
+//	passkeys page of the logged in browser `c`.
+//	@param t
+//	@param c
+//	@param a
+//	@param password: the user's password.
+//	@return int: the status code of the registration.
+func addPasskey(t *testing.T, c *http.Client, a *soft.Authenticator, password string) int {
+	token := csrfToken(t, c, "/profile/passkeys")
+	var options webauthn.CreationOptions
+	status := postJSON(t, c, "/profile/passkeys/options", token, map[string]string{"password": password}, &options)
+	if status != http.StatusOK {
+		t.Fatalf("options: status %d", status)
+	}
+	res, err := a.Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return postJSON(t, c, "/profile/passkeys/register", token, passkeyRegistration{"Phone", res}, nil)
+}
+
+// passkeyLogin
+//
+//	@Description: starts a passkey login on a new browser and returns it with
+//	the authenticator's response, which `tamper` may change.
+//	@param t
+//	@param a
+//	@param tamper: nil to send the response as is.
+//	@return *http.Client
+//	@return string: the CSRF token.
+//	@return passkeyAssertion
+func passkeyLogin(t *testing.T, a *soft.Authenticator, tamper func(res *webauthn.AssertionResponse)) (*http.Client, string, passkeyAssertion) {
+	c := newClient(t)
+	token := csrfToken(t, c, "/login")
+	var options webauthn.RequestOptions
+	status := postJSON(t, c, "/login/passkey/options", token, nil, &options)
+	if status != http.StatusOK {
+		t.Fatalf("options: status %d", status)
+	}
+	res, err := a.Login(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tamper != nil {
+		tamper(&res)
+	}
+	return c, token, passkeyAssertion{AssertionResponse: res}
+}
+
+func TestPasskeyRegistrationRequiresPassword(t *testing.T) {
+	newUser("passkey-pwd", "Passw0rd!")
+	c := logIn(t, "passkey-pwd", "Passw0rd!")
+	token := csrfToken(t, c, "/profile/passkeys")
+	
+	for _, password := range []string{"", "wrong-Passw0rd!"} {
+		status := postJSON(t, c, "/profile/passkeys/options", token, map[string]string{"password": password}, nil)
+		if status != http.StatusForbidden {
+			t.Fatalf("options sent for the password %q: status %d", password, status)
+		}
+	}
+	// no ceremony was started by the refused requests
+	res, err := soft.New(testServer.URL).Register(webauthn.CreationOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := postJSON(t, c, "/profile/passkeys/register", token, passkeyRegistration{"Phone", res}, nil)
+	if status != http.StatusBadRequest {
+		t.Fatalf("registration without options: status %d", status)
+	}
+	
+	// the visitors can't start a registration
+	visitor := newClient(t)
+	status = postJSON(t, visitor, "/profile/passkeys/options", csrfToken(t, visitor, "/login"), map[string]string{"password": "Passw0rd!"}, nil)
+	if status == http.StatusOK {
+		t.Fatal("options sent to a visitor")
+	}
+}
+
+func TestPasskeyRegistration(t *testing.T) {
+	user := newUser("passkey-owner", "Passw0rd!")
+	c := logIn(t, "passkey-owner", "Passw0rd!")
+	a := soft.New(testServer.URL)
+	
+	status := addPasskey(t, c, a, "Passw0rd!")
+	if status != http.StatusCreated {
+		t.Fatalf("registration: status %d", status)
+	}
+	stored, _ := utils.SelectUserById(user.Id)
+	if len(stored.Passkeys) != 1 || stored.Passkeys[0].Name != "Phone" {
+		t.Fatalf("passkey not stored: %+v", stored.Passkeys)
+	}
+	
+	// the registration can't be completed twice
+	token := csrfToken(t, c, "/profile/passkeys")
+	var options webauthn.CreationOptions
+	postJSON(t, c, "/profile/passkeys/options", token, map[string]string{"password": "Passw0rd!"}, &options)
+	res, err := soft.New(testServer.URL).Register(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status = postJSON(t, c, "/profile/passkeys/register", token, passkeyRegistration{"Laptop", res}, nil); status != http.StatusCreated {
+		t.Fatalf("second passkey: status %d", status)
+	}
+	if status = postJSON(t, c, "/profile/passkeys/register", token, passkeyRegistration{"Laptop", res}, nil); status != http.StatusBadRequest {
+		t.Fatalf("registration replayed: status %d", status)
+	}
+	
+	// a response from another origin
+	status = addPasskey(t, c, soft.New("https://evil.example.com"), "Passw0rd!")
+	if status != http.StatusBadRequest {
+		t.Fatalf("passkey of another origin: status %d", status)
+	}
+	stored, _ = utils.SelectUserById(user.Id)
+	if len(stored.Passkeys) != 2 {
+		t.Fatalf("%d passkeys stored instead of 2", len(stored.Passkeys))
+	}
+}
+
+func TestPasskeyLogin(t *testing.T) {
+	user := newUser("passkey-login", "Passw0rd!")
+	a := soft.New(testServer.URL)
+	if status := addPasskey(t, logIn(t, "passkey-login", "Passw0rd!"), a, "Passw0rd!"); status != http.StatusCreated {
+		t.Fatalf("registration: status %d", status)
+	}
+	
+	c, token, body := passkeyLogin(t, a, nil)
+	var location string
+	status := postJSON(t, c, "/login/passkey", token, body, &location)
+	if status != http.StatusOK || location != "/home" {
+		t.Fatalf("login: status %d, redirection to %q", status, location)
+	}
+	res, err := c.Get(testServer.URL + "/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("profile: status %d", res.StatusCode)
+	}
+	stored, _ := utils.SelectUserById(user.Id)
+	if stored.Passkeys[0].SignCount != 2 || stored.Passkeys[0].LastUsed.IsZero() {
+		t.Fatalf("passkey not updated: %+v", stored.Passkeys[0])
+	}
+	
+	// the response can't be replayed in another login
+	replay, token, _ := passkeyLogin(t, a, nil)
+	if status = postJSON(t, replay, "/login/passkey", token, body, nil); status != http.StatusUnauthorized {
+		t.Fatalf("login replayed in another ceremony: status %d", status)
+	}
+}
+
+func TestPasskeyLoginRefused(t *testing.T) {
+	newUser("passkey-refused", "Passw0rd!")
+	a := soft.New(testServer.URL)
+	if status := addPasskey(t, logIn(t, "passkey-refused", "Passw0rd!"), a, "Passw0rd!"); status != http.StatusCreated {
+		t.Fatalf("registration: status %d", status)
+	}
+	
+	tests := map[string]func(res *webauthn.AssertionResponse){
+		"bad signature": func(res *webauthn.AssertionResponse) {
+			res.Response.Signature[len(res.Response.Signature)-1] ^= 0xff
+		},
+		"user not verified": func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData[32] &^= 0x04
+		},
+		"unknown user": func(res *webauthn.AssertionResponse) {
+			res.Response.UserHandle = []byte("0")
+		},
+	}
+	for name, tamper := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, token, body := passkeyLogin(t, a, tamper)
+			if status := postJSON(t, c, "/login/passkey", token, body, nil); status != http.StatusUnauthorized {
+				t.Fatalf("login: status %d", status)
+			}
+			// the ceremony is over after a failure
+			if status := postJSON(t, c, "/login/passkey", token, body, nil); status != http.StatusBadRequest {
+				t.Fatalf("second attempt: status %d", status)
+			}
+		})
+	}
+	
+	// a passkey of another origin
+	c, token, body := passkeyLogin(t, a, nil)
+	body.Response.ClientDataJSON = bytes.Replace(body.Response.ClientDataJSON, []byte(testServer.URL), []byte("https://evil.example.com"), 1)
+	if status := postJSON(t, c, "/login/passkey", token, body, nil); status != http.StatusUnauthorized {
+		t.Fatalf("login from another origin: status %d", status)
+	}
+}
+
+// getStatus returns the status code of the page of the `path`.
+func getStatus(t *testing.T, c *http.Client, path string) int {
+	res, err := c.Get(testServer.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestEmailChangeLinks(t *testing.T) {
+	user := newUser("email-owner", "Passw0rd!")
+	session := logIn(t, "email-owner", "Passw0rd!")
+	now := time.Now()
+	utils.AddTempUser(server.TempUser{ConfirmID: "change-link", CreationTime: now, User: server.User{Id: user.Id, Username: user.Username, Email: "new-owner@example.com"}}, "email")
+	utils.AddTempUser(server.TempUser{ConfirmID: "cancel-link", CreationTime: now, User: user}, "email-cancel")
+	
+	// the mail clients opening the links don't change anything
+	c := newClient(t)
+	for _, path := range []string{"/confirm-email?id=change-link", "/cancel-email?id=cancel-link"} {
+		for i := 0; i < 2; i++ {
+			if status := getStatus(t, c, path); status != http.StatusOK {
+				t.Fatalf("%s: status %d", path, status)
+			}
+		}
+	}
+	if stored, _ := utils.SelectUserById(user.Id); stored.Email != user.Email {
+		t.Fatalf("email changed to %s by opening the link", stored.Email)
+	}
+	if status := getStatus(t, session, "/profile"); status != http.StatusOK {
+		t.Fatalf("logged out by opening the link: status %d", status)
+	}
+	
+	// the forms are protected against CSRF
+	res, err := c.PostForm(testServer.URL+"/confirm-email", url.Values{"id": {"change-link"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusForbidden {
+		t.Fatalf("confirmation without CSRF token: status %d", res.StatusCode)
+	}
+	
+	token := csrfToken(t, c, "/confirm-email?id=change-link")
+	res, err = c.PostForm(testServer.URL+"/confirm-email", url.Values{"csrf_token": {token}, "id": {"change-link"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if stored, _ := utils.SelectUserById(user.Id); res.StatusCode != http.StatusSeeOther || stored.Email != "new-owner@example.com" {
+		t.Fatalf("change not confirmed: status %d, email %s", res.StatusCode, stored.Email)
+	}
+	
+	token = csrfToken(t, c, "/cancel-email?id=cancel-link")
+	res, err = c.PostForm(testServer.URL+"/cancel-email", url.Values{"csrf_token": {token}, "id": {"cancel-link"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if stored, _ := utils.SelectUserById(user.Id); res.StatusCode != http.StatusSeeOther || stored.Email != user.Email {
+		t.Fatalf("change not cancelled: status %d, email %s", res.StatusCode, stored.Email)
+	}
+	if status := getStatus(t, session, "/profile"); status == http.StatusOK {
+		t.Fatal("sessions not logged out by the cancellation")
+	}
+	if status := getStatus(t, c, "/cancel-email?id=cancel-link"); status != http.StatusNotFound {
+		t.Fatalf("cancel link used twice: status %d", status)
+	}
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/url"
+	"slices"
+	
+	api2 "mangathorg/internal/models/api"
+)
+
+// searchRequest
+//
+//	@Description: builds the api2.MangaRequest of a search from the `query`
+//	sent by the search form.
+//	@param query
+//	@param offset
+//	@return api2.MangaRequest
+func searchRequest(query url.Values, offset int) api2.MangaRequest {
+	request := api2.MangaRequest{
+		OrderType:      "rating",
+		OrderValue:     query.Get("order"),
+		IncludedTags:   query["includedTags[]"],
+		ExcludedTags:   query["excludedTags[]"],
+		Title:          query.Get("title"),
+		Author:         query.Get("author"),
+		AuthorOrArtist: query.Get("authorOrArtist"),
+		Status:         query["status[]"],
+		Public:         query["public[]"],
+		Limit:          18,
+		Offset:         offset,
+	}
+	if request.OrderValue != "asc" && request.OrderValue != "desc" {
+		request.OrderValue = "desc"
+	}
+	return request
+}
+
+// categoryNameRequest
+//
+//	@Description: builds the api2.MangaRequest of a category designated by its
+//	`group` and `name` (public, status or special request).
+//	@param group
+//	@param name
+//	@param order
+//	@param offset
+//	@return api2.MangaRequest
+//	@return string: the category's displayed name.
+//	@return bool: false if the category doesn't exist.
+func categoryNameRequest(group, name, order string, offset int) (api2.MangaRequest, string, bool) {
+	var request api2.MangaRequest
+	
+	if group == "public" && slices.Contains(api2.MangaPublic, name) {
+		request = api2.MangaRequest{
+			OrderType:  "rating",
+			OrderValue: order,
+			Public:     []string{name},
+			Limit:      18,
+			Offset:     offset,
+		}
+	} else if group == "status" && slices.Contains(api2.MangaStatus, name) {
+		request = api2.MangaRequest{
+			OrderType:  "rating",
+			OrderValue: order,
+			Status:     []string{name},
+			Limit:      18,
+			Offset:     offset,
+		}
+	} else if group == "special" {
+		if name == "latest-updates" {
+			request = api2.MangaRequest{
+				OrderType:  "latestUploadedChapter",
+				OrderValue: order,
+				Limit:      18,
+				Offset:     offset,
+			}
+			name = "latest uploaded"
+		} else if name == "popular" {
+			request = api2.MangaRequest{
+				OrderType:  "rating",
+				OrderValue: order,
+				Limit:      18,
+				Offset:     offset,
+			}
+		}
+	} else {
+		return api2.MangaRequest{}, name, false
+	}
+	return request, name, true
+}
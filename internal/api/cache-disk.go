@@ -0,0 +1,245 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"mangathorg/internal/models/api"
+	"mangathorg/internal/utils"
+)
+
+// diskRecord is the content of a diskCache file.
+type diskRecord struct {
+	Id          string          `json:"id"`
+	UpdatedTime time.Time       `json:"updated_time"`
+	Order       string          `json:"order"`
+	Offset      int             `json:"offset"`
+	Data        json.RawMessage `json:"data"`
+}
+
+// versionFile is the name of the file stamping a kind's directory with the
+// version of the records it holds.
+const versionFile = "VERSION"
+
+// diskCache is the persistent api.Cache: every record is stored in its own
+// file `<directory>/<kind>/<hash of the key>.json`. An index of the stored keys
+// is kept in memory so that Range never reads the files.
+type diskCache struct {
+	directory string
+	versions  map[string]string
+	mutex     sync.RWMutex
+	index     map[api.CacheKey]time.Time
+}
+
+// newDiskCache
+//
+//	@Description: opens the cache stored in `directory` and indexes the records
+//	it holds. A kind's directory whose version doesn't match the one in
+//	`versions` is emptied. Unreadable records and records for which `expired`
+//	returns true are removed.
+//	@param directory
+//	@param versions: the current version of every kind's records.
+//	@param expired
+//	@return *diskCache
+//	@return error
+func newDiskCache(directory string, versions map[string]string, expired func(key api.CacheKey, updatedTime time.Time) bool) (*diskCache, error) {
+	c := &diskCache{
+		directory: directory,
+		versions:  versions,
+		index:     make(map[api.CacheKey]time.Time),
+	}
+	for _, kind := range api.CacheKinds {
+		dir := filepath.Join(directory, kind)
+		version, err := os.ReadFile(filepath.Join(dir, versionFile))
+		if err != nil || string(version) != versions[kind] {
+			if err == nil {
+				log.Printf("cache version of %s changed, emptying it", kind)
+			}
+			err = c.reset(kind)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			record, err := readDiskRecord(file)
+			if err != nil {
+				log.Printf("removing unreadable cache file %s: %s", file, err)
+				os.Remove(file)
+				continue
+			}
+			key := api.CacheKey{Kind: kind, Id: record.Id, Order: record.Order, Offset: record.Offset}
+			if expired(key, record.UpdatedTime) || c.filename(key) != file {
+				os.Remove(file)
+				continue
+			}
+			c.index[key] = record.UpdatedTime
+		}
+	}
+	return c, nil
+}
+
+// reset
+//
+//	@Description: removes all records of a `kind` and stamps its directory
+//	with the current version.
+//	@receiver c
+//	@param kind
+//	@return error
+func (c *diskCache) reset(kind string) error {
+	dir := filepath.Join(c.directory, kind)
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, versionFile), []byte(c.versions[kind]), 0666)
+}
+
+// readDiskRecord
+//
+//	@Description: reads and decodes a diskCache file.
+//	@param file
+//	@return diskRecord
+//	@return error
+func readDiskRecord(file string) (diskRecord, error) {
+	var record diskRecord
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(data, &record)
+	if err != nil {
+		return record, err
+	}
+	if len(record.Data) == 0 || string(record.Data) == "null" {
+		return record, errors.New("empty cache record")
+	}
+	return record, nil
+}
+
+// filename
+//
+//	@Description: returns the file storing the record of the `key`. The key's
+//	fields are hashed since they may come from the clients.
+//	@receiver c
+//	@param key
+//	@return string
+func (c *diskCache) filename(key api.CacheKey) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{key.Id, key.Order, strconv.Itoa(key.Offset)}, "\x00")))
+	return filepath.Join(c.directory, key.Kind, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *diskCache) Get(key api.CacheKey) (api.SingleCacheData, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	
+	if _, ok := c.index[key]; !ok {
+		return api.SingleCacheData{}, false
+	}
+	record, err := readDiskRecord(c.filename(key))
+	if err != nil {
+		return api.SingleCacheData{}, false
+	}
+	return api.SingleCacheData{
+		Id:          record.Id,
+		UpdatedTime: record.UpdatedTime,
+		Order:       record.Order,
+		Offset:      record.Offset,
+		Data:        record.Data,
+	}, true
+}
+
+func (c *diskCache) Set(key api.CacheKey, datum api.SingleCacheData) error {
+	data, ok := datum.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		data, err = json.Marshal(datum.Data)
+		if err != nil {
+			return err
+		}
+	}
+	content, err := json.Marshal(diskRecord{
+		Id:          key.Id,
+		UpdatedTime: datum.UpdatedTime,
+		Order:       key.Order,
+		Offset:      key.Offset,
+		Data:        data,
+	})
+	if err != nil {
+		return err
+	}
+	
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	// writing in a temporary file first so that a record is never half-written
+	filename := c.filename(key)
+	err = os.WriteFile(filename+".tmp", content, 0666)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(filename+".tmp", filename)
+	if err != nil {
+		return err
+	}
+	c.index[key] = datum.UpdatedTime
+	return nil
+}
+
+func (c *diskCache) Delete(key api.CacheKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	delete(c.index, key)
+	err := os.Remove(c.filename(key))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
+func (c *diskCache) Range(f func(key api.CacheKey, updatedTime time.Time) bool) {
+	c.mutex.RLock()
+	index := make(map[api.CacheKey]time.Time, len(c.index))
+	for key, updatedTime := range c.index {
+		index[key] = updatedTime
+	}
+	c.mutex.RUnlock()
+	
+	for key, updatedTime := range index {
+		if !f(key, updatedTime) {
+			return
+		}
+	}
+}
+
+func (c *diskCache) Clear() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	for _, kind := range api.CacheKinds {
+		err := c.reset(kind)
+		if err != nil {
+			return err
+		}
+	}
+	c.index = make(map[api.CacheKey]time.Time)
+	return nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+	
+	"mangathorg/internal/models/api"
+)
+
+// lruEntry is an element of lruCache's list.
+type lruEntry struct {
+	key   api.CacheKey
+	datum api.SingleCacheData
+}
+
+// lruCache is a bounded in-memory api.Cache: once `capacity` records are
+// stored, the least recently used one is evicted.
+type lruCache struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[api.CacheKey]*list.Element
+}
+
+// newLRUCache
+//
+//	@Description: creates an empty lruCache holding at most `capacity` records.
+//	@param capacity
+//	@return *lruCache
+func newLRUCache(capacity int) *lruCache {
+	return &lruCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[api.CacheKey]*list.Element),
+	}
+}
+
+func (c *lruCache) Get(key api.CacheKey) (api.SingleCacheData, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	elem, ok := c.items[key]
+	if !ok {
+		return api.SingleCacheData{}, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruEntry).datum, true
+}
+
+func (c *lruCache) Set(key api.CacheKey, datum api.SingleCacheData) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*lruEntry).datum = datum
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(&lruEntry{key: key, datum: datum})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+func (c *lruCache) Delete(key api.CacheKey) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+func (c *lruCache) Range(f func(key api.CacheKey, updatedTime time.Time) bool) {
+	c.mutex.Lock()
+	entries := make([]lruEntry, 0, len(c.items))
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, *elem.Value.(*lruEntry))
+	}
+	c.mutex.Unlock()
+	
+	for _, entry := range entries {
+		if !f(entry.key, entry.datum.UpdatedTime) {
+			return
+		}
+	}
+}
+
+func (c *lruCache) Clear() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.order.Init()
+	c.items = make(map[api.CacheKey]*list.Element)
+	return nil
+}
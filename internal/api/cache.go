@@ -1,346 +1,335 @@
 package api
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"log"
 	"log/slog"
-	"os"
 	"reflect"
-	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 	
 	"mangathorg/internal/models/api"
 	"mangathorg/internal/utils"
 )
 
-// retrieveCacheData
-//
-//	@Description: retrieves a specific cache's file content and returns it as a models.CacheData.
-//	@param info: name of the cache file.
-//	@return models.CacheData
-func retrieveCacheData(info string) api.CacheData {
-	filename := utils.DataPath + info + ".json"
-	
-	api.RLock(filename)
-	defer api.RUnlock(filename)
-	
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+// memoryCacheSize is the maximum number of records kept in memory.
+const memoryCacheSize = 512
+
+// cacheMonitorInterval is the time between two sweeps of the expired records.
+const cacheMonitorInterval = time.Minute * 10
+
+// cachePolicy sets the lifetime of a kind of cached records.
+type cachePolicy struct {
+	// TTL is the time during which a record is fresh.
+	TTL time.Duration
+	// MaxStale is the time after TTL during which a stale record is still
+	// served while being refreshed in the background. Past it, the record
+	// is expired.
+	MaxStale time.Duration
+}
+
+// cachePolicies are the cachePolicy of every kind of cached records.
+var cachePolicies = map[string]cachePolicy{
+	api.Status.LastUploaded: {TTL: time.Minute * 10, MaxStale: time.Hour * 6},
+	api.Status.Popular:      {TTL: time.Hour * 6, MaxStale: time.Hour * 24},
+	api.Status.Tags:         {TTL: time.Hour * 24 * 7, MaxStale: time.Hour * 24 * 30},
+	api.Status.Categories:   {TTL: time.Hour * 6, MaxStale: time.Hour * 24},
+	api.Status.Mangas:       {TTL: time.Hour * 24, MaxStale: time.Hour * 24 * 7},
+	api.Status.MangaFeeds:   {TTL: time.Minute * 30, MaxStale: time.Hour * 24},
+	api.Status.MangaStats:   {TTL: time.Hour * 6, MaxStale: time.Hour * 24},
+	// the at-home server URLs given by MangaDex only last a few minutes,
+	// so stale scans are never served
+	api.Status.ChaptersScan: {TTL: time.Minute * 10, MaxStale: 0},
+}
+
+// cacheFormat is the version of the cache records' format. It must be
+// incremented whenever the meaning of the stored data changes without
+// changing its shape.
+const cacheFormat = 1
+
+// cacheTypes are the types of the data stored for every kind of records.
+// Their shape is part of the records' version, so that the records stored by
+// an older version of the server are discarded.
+var cacheTypes = map[string]reflect.Type{
+	api.Status.LastUploaded: reflect.TypeOf(api.ApiManga{}),
+	api.Status.Popular:      reflect.TypeOf(api.ApiManga{}),
+	api.Status.Tags:         reflect.TypeOf(api.ApiTags{}),
+	api.Status.Categories:   reflect.TypeOf(api.ApiManga{}),
+	api.Status.Mangas:       reflect.TypeOf(api.Manga{}),
+	api.Status.MangaFeeds:   reflect.TypeOf(api.ApiMangaFeed{}),
+	api.Status.ChaptersScan: reflect.TypeOf(api.ApiChapterScan{}),
+	api.Status.MangaStats:   reflect.TypeOf(api.ApiMangaStats{}),
+}
+
+// refreshTimeout is the deadline of a background refresh.
+const refreshTimeout = time.Minute
+
+// refreshing holds the api.CacheKey of the records being refreshed in the background.
+var refreshing sync.Map
+
+// cache is the api.Cache used for all MangaDex API requests (set by InitCache).
+var cache api.Cache
+
+// tieredCache is an api.Cache keeping the most recently used records in a
+// `memory` api.Cache in front of a persistent `disk` api.Cache.
+type tieredCache struct {
+	memory api.Cache
+	disk   api.Cache
+}
+
+func (c *tieredCache) Get(key api.CacheKey) (api.SingleCacheData, bool) {
+	if datum, ok := c.memory.Get(key); ok {
+		return datum, true
 	}
-	
-	if len(data) == 0 {
-		return nil
+	datum, ok := c.disk.Get(key)
+	if ok {
+		c.memory.Set(key, datum)
 	}
-	
-	var cacheData api.CacheData
-	err = json.Unmarshal(data, &cacheData)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+	return datum, ok
+}
+
+func (c *tieredCache) Set(key api.CacheKey, datum api.SingleCacheData) error {
+	// marshalling only once for both tiers
+	if _, ok := datum.Data.(json.RawMessage); !ok {
+		data, err := json.Marshal(datum.Data)
+		if err != nil {
+			return err
+		}
+		datum.Data = json.RawMessage(data)
 	}
-	return cacheData
+	datum.Id, datum.Order, datum.Offset = key.Id, key.Order, key.Offset
+	c.memory.Set(key, datum)
+	return c.disk.Set(key, datum)
+}
+
+func (c *tieredCache) Delete(key api.CacheKey) {
+	c.memory.Delete(key)
+	c.disk.Delete(key)
+}
+
+// Range only goes through the disk tier, since it holds every record.
+func (c *tieredCache) Range(f func(key api.CacheKey, updatedTime time.Time) bool) {
+	c.disk.Range(f)
+}
+
+func (c *tieredCache) Clear() error {
+	c.memory.Clear()
+	return c.disk.Clear()
 }
 
-// retrieveSingleCacheData
+// typeSignature
 //
-//	@Description: retrieves a specific entry in a specific cache file and returns it as a models.SingleCacheData.
-//	@param info: name of the cache file.
-//	@param id: id of the item searched.
-//	@param order
-//	@param offset
-//	@return models.SingleCacheData
-func retrieveSingleCacheData(info string, id string, order string, offset int) api.SingleCacheData {
-	cacheData := retrieveCacheData(info)
-	
-	if id == "" && cacheData != nil {
-		return cacheData[0]
-	} else if cacheData == nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("cache fetching error: CacheData not found")))
-		return api.SingleCacheData{Data: nil}
-	}
-	
-	for _, datum := range cacheData {
-		if datum.Id == id && datum.Order == order && datum.Offset == offset {
-			return datum
+//	@Description: describes the JSON shape of a type (fields' names, tags and
+//	kinds, recursively).
+//	@param t
+//	@param sb
+func typeSignature(t reflect.Type, sb *strings.Builder) {
+	switch t.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		sb.WriteString(t.Kind().String() + "(")
+		typeSignature(t.Elem(), sb)
+		sb.WriteString(")")
+	case reflect.Map:
+		sb.WriteString("map(")
+		typeSignature(t.Key(), sb)
+		sb.WriteString(",")
+		typeSignature(t.Elem(), sb)
+		sb.WriteString(")")
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			sb.WriteString("time")
+			return
 		}
+		sb.WriteString("struct{")
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			sb.WriteString(field.Name + " " + field.Tag.Get("json") + " ")
+			typeSignature(field.Type, sb)
+			sb.WriteString(";")
+		}
+		sb.WriteString("}")
+	default:
+		sb.WriteString(t.Kind().String())
 	}
-	utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("cache fetching error: SingleCacheData not found")))
-	return api.SingleCacheData{Data: nil}
 }
 
-// checkStatus
+// cacheVersions
 //
-//	@Description: checks if the item's id is present in the `info` category in status.json.
-//	@param info: kind of item.
-//	@param id: the item's id.
-//	@return bool
-func checkStatus(info string, id string) bool {
-	filename := utils.DataPath + "status.json"
-	
-	api.RLock(filename)
-	defer api.RUnlock(filename)
-	
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+//	@Description: returns the current version of every kind of records.
+//	@return map[string]string
+func cacheVersions() map[string]string {
+	versions := make(map[string]string, len(cacheTypes))
+	for kind, t := range cacheTypes {
+		var sb strings.Builder
+		sb.WriteString(strconv.Itoa(cacheFormat) + ":")
+		typeSignature(t, &sb)
+		sum := sha256.Sum256([]byte(sb.String()))
+		versions[kind] = hex.EncodeToString(sum[:])
 	}
-	
-	if len(data) == 0 {
-		return false
-	}
-	
-	var status api.StatusCache
-	err = json.Unmarshal(data, &status)
+	return versions
+}
+
+// InitCache
+//
+//	@Description: opens the cache stored in utils.DataPath, keeping the records
+//	stored by previous runs that are still valid.
+func InitCache() {
+	disk, err := newDiskCache(utils.DataPath, cacheVersions(), isOldCache)
 	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	
-	switch info {
-	case api.Status.LastUploaded:
-		return status.LastUploaded
-	case api.Status.Popular:
-		return status.Popular
-	case api.Status.Categories:
-		return status.Categories != nil && slices.Contains(status.Categories, id)
-	case api.Status.ChaptersScan:
-		return status.ChaptersScan != nil && slices.Contains(status.ChaptersScan, id)
-	case api.Status.MangaFeeds:
-		return status.MangaFeeds != nil && slices.Contains(status.MangaFeeds, id)
-	case api.Status.MangaStats:
-		return status.MangaStats != nil && slices.Contains(status.MangaStats, id)
-	case api.Status.Mangas:
-		return status.Mangas != nil && slices.Contains(status.Mangas, id)
-	case api.Status.Tags:
-		return status.Tags
-	default:
-		return false
+		log.Printf("An error occurred: %s", err.Error())
+		log.Fatalln("Error while opening the cache!")
 	}
+	cache = &tieredCache{memory: newLRUCache(memoryCacheSize), disk: disk}
 }
 
-// isCache
+// cacheable is implemented by every data fetched from MangaDex API that can be cached.
+type cacheable interface {
+	SingleCacheData(id string, order string, offset int) api.SingleCacheData
+}
+
+// storeCache
 //
-//	@Description: checks if the request has already been cached.
-//	@param r
-//	@return bool
-//	@return string
-//	@return string
-func isCache(r api.MangaRequest) (bool, string, string) {
-	switch {
-	case reflect.DeepEqual(r, TopPopularRequest):
-		return checkStatus(api.Status.Popular, ""), api.Status.Popular, ""
-	case reflect.DeepEqual(r, TopLatestUploadedRequest):
-		return checkStatus(api.Status.LastUploaded, ""), api.Status.LastUploaded, ""
-	default:
-		return false, "", ""
+//	@Description: stores the `data` fetched from MangaDex API under the `key`.
+//	@param key
+//	@param data
+func storeCache(key api.CacheKey, data cacheable) {
+	err := cache.Set(key, data.SingleCacheData(key.Id, key.Order, key.Offset))
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
 }
 
-// cacheRequest
+// getCache
 //
-//	@Description: retrieves a cached request.
-//	@param r
+//	@Description: retrieves the record of the `key` from the cache. A stale
+//	record is returned while `refresh` fetches it again in the background. An
+//	expired record is not returned.
+//	@param key
+//	@param refresh: fetches and caches the record again.
 //	@return models.SingleCacheData
-func cacheRequest(r api.MangaRequest) api.SingleCacheData {
+//	@return bool
+func getCache(key api.CacheKey, refresh func(ctx context.Context) error) (api.SingleCacheData, bool) {
+	datum, ok := cache.Get(key)
+	if !ok {
+		return api.SingleCacheData{}, false
+	}
+	policy := cachePolicies[key.Kind]
+	age := time.Since(datum.UpdatedTime)
 	switch {
-	case reflect.DeepEqual(r, TopPopularRequest):
-		return retrieveSingleCacheData(api.Status.Popular, "", "", 0)
-	case reflect.DeepEqual(r, TopLatestUploadedRequest):
-		return retrieveSingleCacheData(api.Status.LastUploaded, "", "", 0)
+	case age <= policy.TTL:
+		return datum, true
+	case age <= policy.TTL+policy.MaxStale:
+		refreshCache(key, refresh)
+		return datum, true
 	default:
-		return api.SingleCacheData{}
+		return api.SingleCacheData{}, false
 	}
 }
 
-// updateCacheStatus
+// refreshCache
 //
-//	@Description: updates an item's cache status.
-//	@param info: type of item.
-//	@param id: the item's id.
-func updateCacheStatus(info string, id string) {
-	filename := utils.DataPath + "status.json"
-	
-	api.RWLock(filename)
-	defer api.RWUnlock(filename)
-	
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+//	@Description: runs `refresh` in the background, unless the record of the
+//	`key` is already being refreshed. The refresh isn't bound to the client's
+//	request that triggered it, so it has its own deadline.
+//	@param key
+//	@param refresh
+func refreshCache(key api.CacheKey, refresh func(ctx context.Context) error) {
+	if _, loaded := refreshing.LoadOrStore(key, struct{}{}); loaded {
+		return
 	}
-	
-	var status api.StatusCache
-	if len(data) != 0 {
-		err = json.Unmarshal(data, &status)
+	go func() {
+		defer refreshing.Delete(key)
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+		err := refresh(ctx)
 		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		}
-	}
-	
-	switch info {
-	case api.Status.LastUploaded:
-		status.LastUploaded = true
-	case api.Status.Popular:
-		status.Popular = true
-	case api.Status.Categories:
-		if slices.Contains(status.Categories, id) {
-			return
-		}
-		status.Categories = append(status.Categories, id)
-	case api.Status.ChaptersScan:
-		if slices.Contains(status.ChaptersScan, id) {
-			return
-		}
-		status.ChaptersScan = append(status.ChaptersScan, id)
-	case api.Status.MangaFeeds:
-		if slices.Contains(status.MangaFeeds, id) {
-			return
-		}
-		status.MangaFeeds = append(status.MangaFeeds, id)
-	case api.Status.MangaStats:
-		if slices.Contains(status.MangaStats, id) {
-			return
-		}
-		status.MangaStats = append(status.MangaStats, id)
-	case api.Status.Mangas:
-		if slices.Contains(status.Mangas, id) {
-			return
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err), slog.Any("key", key))
 		}
-		status.Mangas = append(status.Mangas, id)
-	case api.Status.Tags:
-		status.Tags = true
-	}
-	
-	data, errJSON := json.MarshalIndent(status, "", "\t")
-	if errJSON != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errJSON))
-	}
-	errWrite := os.WriteFile(filename, data, 0666)
-	if errWrite != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errWrite))
-	}
+	}()
 }
 
-// isOldCache
+// isCache
 //
-//	@Description: checks if a cached item is outdated or not.
-//	@param data
+//	@Description: returns the cache key of the request if it is one of the
+//	requests that are cached.
+//	@param r
+//	@return models.CacheKey
 //	@return bool
-func isOldCache(data api.SingleCacheData) bool {
-	// possible evolution: to customize time limit, add a time parameter, or add the info parameter
-	// to set a different time according to the data type (info).
-	if time.Since(data.UpdatedTime) > time.Hour*24 {
-		return true
+func isCache(r api.MangaRequest) (api.CacheKey, bool) {
+	switch {
+	case reflect.DeepEqual(r, TopPopularRequest):
+		return api.CacheKey{Kind: api.Status.Popular}, true
+	case reflect.DeepEqual(r, TopLatestUploadedRequest):
+		return api.CacheKey{Kind: api.Status.LastUploaded}, true
+	default:
+		return api.CacheKey{}, false
 	}
-	return false
 }
 
-// deleteCacheStatus
+// isOldCache
 //
-//	@Description: removes the item's id in status.json.
-//	@param info: item's type.
-//	@param id: item's id.
-func deleteCacheStatus(info string, id string) {
-	filename := utils.DataPath + "status.json"
-	
-	api.RWLock(filename)
-	defer api.RWUnlock(filename)
-	
-	data, err := os.ReadFile(filename)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	
-	var status api.StatusCache
-	if len(data) != 0 {
-		err = json.Unmarshal(data, &status)
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		}
-	}
-	
-	switch info {
-	case api.Status.LastUploaded:
-		status.LastUploaded = false
-	case api.Status.Popular:
-		status.Popular = false
-	case api.Status.Categories:
-		index := slices.Index(status.Categories, id)
-		if index != -1 {
-			status.Categories = append(status.Categories[:index], status.Categories[index+1:]...)
-		}
-	case api.Status.ChaptersScan:
-		index := slices.Index(status.ChaptersScan, id)
-		if index != -1 {
-			status.ChaptersScan = append(status.ChaptersScan[:index], status.ChaptersScan[index+1:]...)
-		}
-	case api.Status.MangaFeeds:
-		index := slices.Index(status.MangaFeeds, id)
-		if index != -1 {
-			status.MangaFeeds = append(status.MangaFeeds[:index], status.MangaFeeds[index+1:]...)
-		}
-	case api.Status.MangaStats:
-		index := slices.Index(status.MangaStats, id)
-		if index != -1 {
-			status.MangaStats = append(status.MangaStats[:index], status.MangaStats[index+1:]...)
-		}
-	case api.Status.Mangas:
-		index := slices.Index(status.Mangas, id)
-		if index != -1 {
-			status.Mangas = append(status.Mangas[:index], status.Mangas[index+1:]...)
-		}
-	case api.Status.Tags:
-		status.Tags = false
-	}
-	
-	data, errJSON := json.MarshalIndent(status, "", "\t")
-	if errJSON != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errJSON))
-	}
-	errWrite := os.WriteFile(filename, data, 0666)
-	if errWrite != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errWrite))
-	}
+//	@Description: checks if a cached item is expired according to its kind's
+//	cachePolicy.
+//	@param key
+//	@param updatedTime
+//	@return bool
+func isOldCache(key api.CacheKey, updatedTime time.Time) bool {
+	policy := cachePolicies[key.Kind]
+	return time.Since(updatedTime) > policy.TTL+policy.MaxStale
 }
 
-// deleteCacheData
+// GetCacheStats
 //
-//	@Description: removes a single item cached.
-//	@param info: item's type.
-//	@param data: item.
-func deleteCacheData(info string, data api.SingleCacheData) {
-	filename := utils.DataPath + info + ".json"
-	
-	api.RWLock(filename)
-	defer api.RWUnlock(filename)
-	
-	switch info {
-	case api.Status.LastUploaded, api.Status.Popular, api.Status.Tags:
-		err := os.WriteFile(filename, []byte("[]"), 0666)
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+//	@Description: counts the cached records of every kind, according to their
+//	cachePolicy.
+//	@return []api.CacheStats: in the order of api.CacheKinds.
+func GetCacheStats() []api.CacheStats {
+	counts := make(map[string]*api.CacheStats, len(api.CacheKinds))
+	for _, kind := range api.CacheKinds {
+		counts[kind] = &api.CacheStats{Kind: kind}
+	}
+	cache.Range(func(key api.CacheKey, updatedTime time.Time) bool {
+		stats, ok := counts[key.Kind]
+		if !ok {
+			return true
 		}
-	default:
-		cacheData := retrieveCacheData(info)
-		var err error
-		cacheData, err = cacheData.Delete(data.Id, data.Order, data.Offset)
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		age := time.Since(updatedTime)
+		switch policy := cachePolicies[key.Kind]; {
+		case age <= policy.TTL:
+			stats.Fresh++
+		case age <= policy.TTL+policy.MaxStale:
+			stats.Stale++
+		default:
+			stats.Expired++
 		}
+		return true
+	})
+	
+	list := make([]api.CacheStats, 0, len(api.CacheKinds))
+	for _, kind := range api.CacheKinds {
+		list = append(list, *counts[kind])
 	}
-	deleteCacheStatus(info, data.Id)
+	return list
 }
 
 // clearCache
 //
-//	@Description: clears all outdated caches of a kind.
-//	@param info: kind of cache.
-func clearCache(info string) {
-	cache := retrieveCacheData(info)
-	for _, data := range cache {
-		if isOldCache(data) {
-			deleteCacheData(info, data)
+//	@Description: clears all expired cached items.
+func clearCache() {
+	cache.Range(func(key api.CacheKey, updatedTime time.Time) bool {
+		if isOldCache(key, updatedTime) {
+			cache.Delete(key)
 		}
-	}
+		return true
+	})
 }
 
 // CacheMonitor
@@ -349,69 +338,20 @@ func clearCache(info string) {
 //	validity (meant to be a goroutine).
 func CacheMonitor() {
 	time.Sleep(time.Second * 10)
-	hour := 1
-	var duration time.Duration
-	var infos = []string{api.Status.Popular, api.Status.LastUploaded, api.Status.Tags, api.Status.Mangas, api.Status.MangaFeeds, api.Status.MangaStats, api.Status.ChaptersScan, api.Status.Categories}
 	for {
 		utils.Logger.Info(utils.GetCurrentFuncName(), slog.String("goroutine", "CacheMonitor"))
-		for _, status := range infos {
-			info := status
-			go clearCache(info)
-		}
-		if time.Now().Hour() != hour || time.Now().Minute() > 15 {
-			duration = utils.SetDailyTimer(hour)
-		} else {
-			duration = time.Hour * 24
-		}
-		time.Sleep(duration)
-	}
-}
-
-// emptyFile
-//
-//	@Description: empties a cache file.
-//	@param status: cache type.
-func emptyFile(status string) {
-	filename := utils.DataPath + status + ".json"
-	
-	api.RWLock(filename)
-	defer api.RWUnlock(filename)
-	
-	err := os.WriteFile(filename, []byte("[]"), 0666)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		return
-	}
-}
-
-// emptyCacheStatus
-//
-//	@Description: empties status.json.
-func emptyCacheStatus() {
-	filename := utils.DataPath + "status.json"
-	
-	api.RWLock(filename)
-	defer api.RWUnlock(filename)
-	
-	data, err := json.MarshalIndent(api.StatusCache{}, "", "\t")
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		return
-	}
-	err = os.WriteFile(filename, data, 0666)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		clearCache()
+		time.Sleep(cacheMonitorInterval)
 	}
 }
 
 // EmptyCache
 //
-//	@Description: empties the whole cache (all specific files and status.json).
+//	@Description: empties the whole cache.
 func EmptyCache() {
 	log.Println("Emptying cache...")
-	var infos = []string{api.Status.Popular, api.Status.LastUploaded, api.Status.Tags, api.Status.Mangas, api.Status.MangaFeeds, api.Status.MangaStats, api.Status.ChaptersScan, api.Status.Categories}
-	for _, status := range infos {
-		emptyFile(status)
+	err := cache.Clear()
+	if err != nil {
+		log.Printf("An error occurred: %s", err.Error())
 	}
-	emptyCacheStatus()
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"log"
 	"log/slog"
@@ -9,14 +10,17 @@ import (
 	"reflect"
 	"strconv"
 	"sync"
+	"time"
 	
+	"mangathorg/internal/mangadex"
 	"mangathorg/internal/models/api"
 	"mangathorg/internal/models/server"
 	"mangathorg/internal/utils"
 )
 
-// BaseApiURL is the common URL used for all MangaDex API requests.
-var BaseApiURL string = "https://api.mangadex.org/"
+// BaseApiURL is the common URL used for all MangaDex API requests (see
+// mangadex.APIURL).
+var BaseApiURL string = mangadex.APIURL + "/"
 
 // TopPopularRequest is the exact request done to retrieve the six most popular
 // mangas for the principal page.
@@ -40,24 +44,50 @@ var TopLatestUploadedRequest = api.MangaRequest{
 	Offset:       0,
 }
 
+// upstreamCheckTimeout is the deadline of a health check of MangaDex API.
+const upstreamCheckTimeout = time.Second * 10
+
+// CheckUpstream
+//
+//	@Description: checks if MangaDex API answers, and measures its latency.
+//	@param ctx
+//	@return api.UpstreamHealth
+func CheckUpstream(ctx context.Context) api.UpstreamHealth {
+	ctx, cancel := context.WithTimeout(ctx, upstreamCheckTimeout)
+	defer cancel()
+	
+	health := api.UpstreamHealth{
+		URL:       mangadex.APIURL,
+		CheckTime: time.Now(),
+	}
+	err := mangadex.Default.Head(ctx, mangadex.APIURL+"/manga/tag")
+	health.Latency = time.Since(health.CheckTime).Round(time.Millisecond)
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+		health.Error = err.Error()
+	}
+	return health
+}
+
 // FetchMangaById
 //
 //	@Description: fetches a specific manga according to its id,
 //	with a set of chapters according to the `order` and `offset`.
+//	@param ctx
 //	@param id
 //	@param order
 //	@param offset
 //	@return models.MangaUsefullData
-func FetchMangaById(id string, order string, offset int) api.MangaUsefullData {
+func FetchMangaById(ctx context.Context, id string, order string, offset int) api.MangaUsefullData {
 	if id == "" {
 		return api.MangaUsefullData{}
 	}
 	var manga api.MangaUsefullData
-	apiManga := MangaRequestById(id)
+	apiManga := MangaRequestById(ctx, id)
 	
-	manga = apiManga.Data.Format()
-	feed := FeedRequest(id, order, offset)
-	manga.Fill(StatRequest(id), feed)
+	manga = apiManga.Data.Format(ctx)
+	feed := FeedRequest(ctx, id, order, offset)
+	manga.Fill(StatRequest(ctx, id), feed)
 	if order == "asc" {
 		for i := range manga.Chapters {
 			manga.Chapters[i].Offset = offset + i
@@ -71,47 +101,35 @@ func FetchMangaById(id string, order string, offset int) api.MangaUsefullData {
 	return manga
 }
 
-// fillMangaListById
-//
-//	@Description: fetches a specific manga according to its id and add it to
-//	`mangaList` (meant to be called as a goroutine to optimize timing).
-//	@param id
-//	@param order
-//	@param offset
-//	@param mangaList
-//	@param wg
-func fillMangaListById(id, order string, offset int, mangaList *[]api.MangaUsefullData, wg *sync.WaitGroup) {
-	defer wg.Done()
-	*mangaList = append(*mangaList, FetchMangaById(id, order, offset))
-}
-
 // FetchMangasById
 //
 //	@Description: fetches a list of mangas according to their ids.
+//	@param ctx
 //	@param favorites
 //	@param order
 //	@param offset
 //	@return []models.MangaUsefullData
-func FetchMangasById(favorites []server.MangaUser, order string, offset int) []api.MangaUsefullData {
+func FetchMangasById(ctx context.Context, favorites []server.MangaUser, order string, offset int) []api.MangaUsefullData {
 	if favorites == nil {
 		return nil
 	}
-	var mangas []api.MangaUsefullData
+	mangas := make([]api.MangaUsefullData, len(favorites))
 	var wg sync.WaitGroup
 	
-	for _, favorite := range favorites {
+	for i, favorite := range favorites {
 		wg.Add(1)
-		go fillMangaListById(favorite.Id, order, offset, &mangas, &wg)
+		go func(i int, id string) {
+			defer wg.Done()
+			mangas[i] = FetchMangaById(ctx, id, order, offset)
+		}(i, favorite.Id)
 	}
 	wg.Wait()
 	
+	// removing the mangas that couldn't be fetched
 	var sortedMangas []api.MangaUsefullData
-	
-	for _, favorite := range favorites {
-		for _, manga := range mangas {
-			if favorite.Id == manga.Id {
-				sortedMangas = append(sortedMangas, manga)
-			}
+	for i, favorite := range favorites {
+		if mangas[i].Id == favorite.Id {
+			sortedMangas = append(sortedMangas, mangas[i])
 		}
 	}
 	
@@ -121,11 +139,12 @@ func FetchMangasById(favorites []server.MangaUser, order string, offset int) []a
 // MangaRequestById
 //
 //	@Description: requests a single manga according to its id.
+//	@param ctx
 //	@param id
 //	@return models.ApiSingleManga
-func MangaRequestById(id string) api.ApiSingleManga {
-	if checkStatus(api.Status.Mangas, id) {
-		mangaCache := retrieveSingleCacheData(api.Status.Mangas, id, "", 0)
+func MangaRequestById(ctx context.Context, id string) api.ApiSingleManga {
+	key := api.CacheKey{Kind: api.Status.Mangas, Id: id}
+	if mangaCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchMangaById(ctx, key); return err }); ok {
 		manga, err := mangaCache.Manga()
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
@@ -135,23 +154,16 @@ func MangaRequestById(id string) api.ApiSingleManga {
 		// handling missing id in the manga cache data
 		if manga.Id == "" {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("manga retrieved from cache has no Id")))
-			deleteCacheData(api.Status.Mangas, mangaCache)
+			cache.Delete(key)
 		} else {
 			return api.ApiSingleManga{Data: manga}
 		}
 	}
-	var apiSingleManga api.ApiSingleManga
-	err := apiSingleManga.SendRequest(BaseApiURL, "manga/"+id, nil)
+	apiSingleManga, err := fetchMangaById(ctx, key)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
 	
-	err = apiSingleManga.Data.SingleCacheData(id, "desc", 0).Write(utils.DataPath+api.Status.Mangas+".json", true)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	updateCacheStatus(api.Status.Mangas, id)
-	
 	if reflect.DeepEqual(apiSingleManga, api.ApiSingleManga{}) {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("empty apiSingleManga")))
 	}
@@ -159,56 +171,86 @@ func MangaRequestById(id string) api.ApiSingleManga {
 	return apiSingleManga
 }
 
+// fetchMangaById
+//
+//	@Description: requests a single manga from MangaDex API and caches it.
+//	@param ctx
+//	@param key: the cache key, holding the manga's id.
+//	@return models.ApiSingleManga
+//	@return error
+func fetchMangaById(ctx context.Context, key api.CacheKey) (api.ApiSingleManga, error) {
+	var apiSingleManga api.ApiSingleManga
+	err := apiSingleManga.SendRequest(ctx, BaseApiURL, "manga/"+key.Id, nil)
+	if err == nil {
+		storeCache(key, &apiSingleManga.Data)
+	}
+	return apiSingleManga, err
+}
+
 // FetchManga
 //
 //	@Description: fetches mangas according to a request.
+//	@param ctx
 //	@param request
 //	@return models.MangasInBulk
-func FetchManga(request api.MangaRequest) api.MangasInBulk {
-	apiManga := MangaRequest(request)
+func FetchManga(ctx context.Context, request api.MangaRequest) api.MangasInBulk {
+	apiManga := MangaRequest(ctx, request)
 	
-	return apiManga.Format()
+	return apiManga.Format(ctx)
 }
 
 // MangaRequest
 //
 //	@Description: requests a list of mangas.
+//	@param ctx
 //	@param request
 //	@return models.ApiManga
-func MangaRequest(request api.MangaRequest) api.ApiManga {
-	var exists bool
-	var info, id string
-	if exists, info, id = isCache(request); exists {
-		apiManga, err := cacheRequest(request).ApiManga()
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+func MangaRequest(ctx context.Context, request api.MangaRequest) api.ApiManga {
+	key, cached := isCache(request)
+	if cached {
+		if mangaCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchMangas(ctx, request, key, true); return err }); ok {
+			apiManga, err := mangaCache.ApiManga()
+			if err != nil {
+				utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+			}
+			return apiManga
 		}
-		return apiManga
 	}
 	
-	var apiManga api.ApiManga
-	err := apiManga.SendRequest(BaseApiURL, "manga", request.ToQuery())
+	apiManga, err := fetchMangas(ctx, request, key, cached)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
-	
-	if info != "" {
-		err = apiManga.SingleCacheData("", request.OrderValue, request.Offset).Write(utils.DataPath+info+".json", id != "")
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		}
-		updateCacheStatus(info, id)
-	}
 	return apiManga
 }
 
+// fetchMangas
+//
+//	@Description: requests a list of mangas from MangaDex API and caches it if
+//	`cached` is true.
+//	@param ctx
+//	@param request
+//	@param key
+//	@param cached
+//	@return models.ApiManga
+//	@return error
+func fetchMangas(ctx context.Context, request api.MangaRequest, key api.CacheKey, cached bool) (api.ApiManga, error) {
+	var apiManga api.ApiManga
+	err := apiManga.SendRequest(ctx, BaseApiURL, "manga", request.ToQuery())
+	if err == nil && cached {
+		storeCache(key, &apiManga)
+	}
+	return apiManga, err
+}
+
 // TagsRequest
 //
 //	@Description: requests all tags from MangaDex API.
+//	@param ctx
 //	@return models.ApiTags
-func TagsRequest() api.ApiTags {
-	if checkStatus(api.Status.Tags, "") {
-		tagCache := retrieveSingleCacheData(api.Status.Tags, "", "", 0)
+func TagsRequest(ctx context.Context) api.ApiTags {
+	key := api.CacheKey{Kind: api.Status.Tags}
+	if tagCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchTags(ctx, key); return err }); ok {
 		apiTags, err := tagCache.ApiTags()
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
@@ -217,28 +259,38 @@ func TagsRequest() api.ApiTags {
 		return apiTags
 	}
 	
-	var apiTags api.ApiTags
-	err := apiTags.SendRequest(BaseApiURL, "manga/tag", nil)
+	apiTags, err := fetchTags(ctx, key)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
 	
-	err = apiTags.SingleCacheData("", "", 0).Write(utils.DataPath+api.Status.Tags+".json", false)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	updateCacheStatus(api.Status.Tags, "")
-	
 	return apiTags
 }
 
+// fetchTags
+//
+//	@Description: requests all tags from MangaDex API and caches them.
+//	@param ctx
+//	@param key
+//	@return models.ApiTags
+//	@return error
+func fetchTags(ctx context.Context, key api.CacheKey) (api.ApiTags, error) {
+	var apiTags api.ApiTags
+	err := apiTags.SendRequest(ctx, BaseApiURL, "manga/tag", nil)
+	if err == nil {
+		storeCache(key, &apiTags)
+	}
+	return apiTags, err
+}
+
 // TagSelect
 //
 //	@Description: selects a tag according to its id.
+//	@param ctx
 //	@param id
 //	@return models.ApiTag
-func TagSelect(id string) api.ApiTag {
-	tags := TagsRequest()
+func TagSelect(ctx context.Context, id string) api.ApiTag {
+	tags := TagsRequest(ctx)
 	for _, tag := range tags.Data {
 		if tag.Id == id {
 			return tag
@@ -252,9 +304,10 @@ func TagSelect(id string) api.ApiTag {
 //
 //	@Description: fetches all tags (public and status included) and sort them by
 //	type.
+//	@param ctx
 //	@return models.OrderedTags
-func FetchSortedTags() api.OrderedTags {
-	allTags := TagsRequest().Data
+func FetchSortedTags(ctx context.Context) api.OrderedTags {
+	allTags := TagsRequest(ctx).Data
 	var orderedTags api.OrderedTags
 	for _, tag := range allTags {
 		switch tag.Attributes.Group {
@@ -275,31 +328,25 @@ func FetchSortedTags() api.OrderedTags {
 //
 //	@Description: requests a specific list of chapters according to the manga's
 //	`id`, the `order` and the `offset`.
+//	@param ctx
 //	@param id
 //	@param order
 //	@param offset
 //	@return models.ApiMangaFeed
-func FeedRequest(id, order string, offset int) api.ApiMangaFeed {
+func FeedRequest(ctx context.Context, id, order string, offset int) api.ApiMangaFeed {
 	
 	// retrieving the total number of chapters
 	var total int
-	if checkStatus(api.Status.MangaFeeds, id) {
-		feedCache := retrieveSingleCacheData(api.Status.MangaFeeds, id, "desc", 0)
+	firstKey := api.CacheKey{Kind: api.Status.MangaFeeds, Id: id, Order: "desc"}
+	if feedCache, ok := getCache(firstKey, func(ctx context.Context) error { _, err := fetchFeed(ctx, firstKey); return err }); ok {
 		apiMangaFeed, err := feedCache.ApiMangaFeed()
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		}
 		total = apiMangaFeed.Total
 	} else {
-		var apiMangaFeed api.ApiMangaFeed
-		
-		var query = make(url.Values)
-		query.Add("order[chapter]", order)
-		query.Add("translatedLanguage[]", "en")
-		query.Add("contentRating[]", "safe")
-		query.Add("includes[]", "scanlation_group")
-		
-		err := apiMangaFeed.SendRequest(BaseApiURL, "manga/"+id+"/feed", query)
+		// the first page is cached, so that the total isn't requested again
+		apiMangaFeed, err := fetchFeed(ctx, firstKey)
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		}
@@ -311,50 +358,58 @@ func FeedRequest(id, order string, offset int) api.ApiMangaFeed {
 		offset = (total / 15) - 1
 	}
 	
-	if checkStatus(api.Status.MangaFeeds, id) {
-		feedCache := retrieveSingleCacheData(api.Status.MangaFeeds, id, order, offset)
-		if feedCache.Data != nil {
-			apiMangaFeed, err := feedCache.ApiMangaFeed()
-			if err != nil {
-				utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-			}
-			log.Println("retrieving feed from cache") // testing
-			return apiMangaFeed
+	key := api.CacheKey{Kind: api.Status.MangaFeeds, Id: id, Order: order, Offset: offset}
+	if feedCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchFeed(ctx, key); return err }); ok {
+		apiMangaFeed, err := feedCache.ApiMangaFeed()
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		}
+		log.Println("retrieving feed from cache") // testing
+		return apiMangaFeed
 	}
 	
+	apiMangaFeed, err := fetchFeed(ctx, key)
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+	}
+	
+	return apiMangaFeed
+}
+
+// fetchFeed
+//
+//	@Description: requests a list of 15 chapters from MangaDex API and caches it.
+//	@param ctx
+//	@param key: the cache key, holding the manga's id, the order and the offset.
+//	@return models.ApiMangaFeed
+//	@return error
+func fetchFeed(ctx context.Context, key api.CacheKey) (api.ApiMangaFeed, error) {
 	var apiMangaFeed api.ApiMangaFeed
 	
 	var query = make(url.Values)
-	query.Add("order[chapter]", order)
+	query.Add("order[chapter]", key.Order)
 	query.Add("translatedLanguage[]", "en")
 	query.Add("contentRating[]", "safe")
 	query.Add("includes[]", "scanlation_group")
 	query.Add("limit", "15")
-	query.Add("offset", strconv.Itoa(offset))
+	query.Add("offset", strconv.Itoa(key.Offset))
 	
-	err := apiMangaFeed.SendRequest(BaseApiURL, "manga/"+id+"/feed", query)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	
-	err = apiMangaFeed.SingleCacheData(id, order, offset).Write(utils.DataPath+api.Status.MangaFeeds+".json", true)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
+	err := apiMangaFeed.SendRequest(ctx, BaseApiURL, "manga/"+key.Id+"/feed", query)
+	if err == nil {
+		storeCache(key, &apiMangaFeed)
 	}
-	updateCacheStatus(api.Status.MangaFeeds, id)
-	
-	return apiMangaFeed
+	return apiMangaFeed, err
 }
 
 // ScanRequest
 //
 //	@Description: requests a chapter's scans according to its `id`.
+//	@param ctx
 //	@param id
 //	@return models.ApiChapterScan
-func ScanRequest(id string) api.ApiChapterScan {
-	if checkStatus(api.Status.ChaptersScan, id) {
-		scanCache := retrieveSingleCacheData(api.Status.ChaptersScan, id, "", 0)
+func ScanRequest(ctx context.Context, id string) api.ApiChapterScan {
+	key := api.CacheKey{Kind: api.Status.ChaptersScan, Id: id}
+	if scanCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchScan(ctx, key); return err }); ok {
 		apiChapterScan, err := scanCache.ApiChapterScan()
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
@@ -362,29 +417,39 @@ func ScanRequest(id string) api.ApiChapterScan {
 		log.Println("retrieving chapterScan from cache") // testing
 		return apiChapterScan
 	}
-	var apiChapterScan api.ApiChapterScan
-	err := apiChapterScan.SendRequest(BaseApiURL, "at-home/server/"+id, nil)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	
-	err = apiChapterScan.SingleCacheData(id, "", 0).Write(utils.DataPath+api.Status.ChaptersScan+".json", true)
+	apiChapterScan, err := fetchScan(ctx, key)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
-	updateCacheStatus(api.Status.ChaptersScan, id)
 	
 	return apiChapterScan
 }
 
+// fetchScan
+//
+//	@Description: requests a chapter's scans from MangaDex API and caches them.
+//	@param ctx
+//	@param key: the cache key, holding the chapter's id.
+//	@return models.ApiChapterScan
+//	@return error
+func fetchScan(ctx context.Context, key api.CacheKey) (api.ApiChapterScan, error) {
+	var apiChapterScan api.ApiChapterScan
+	err := apiChapterScan.SendRequest(ctx, BaseApiURL, "at-home/server/"+key.Id, nil)
+	if err == nil {
+		storeCache(key, &apiChapterScan)
+	}
+	return apiChapterScan, err
+}
+
 // StatRequest
 //
 //	@Description: requests a manga's statistics according to its `id`.
+//	@param ctx
 //	@param id
 //	@return models.Statistics
-func StatRequest(id string) api.Statistics {
-	if checkStatus(api.Status.MangaStats, id) {
-		statCache := retrieveSingleCacheData(api.Status.MangaStats, id, "", 0)
+func StatRequest(ctx context.Context, id string) api.Statistics {
+	key := api.CacheKey{Kind: api.Status.MangaStats, Id: id}
+	if statCache, ok := getCache(key, func(ctx context.Context) error { _, err := fetchStats(ctx, key); return err }); ok {
 		apiMangaStats, err := statCache.ApiMangaStats()
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
@@ -397,16 +462,10 @@ func StatRequest(id string) api.Statistics {
 		}
 		return mangaStats
 	}
-	var apiMangaStats api.ApiMangaStats
-	err := apiMangaStats.SendRequest(BaseApiURL, "statistics/manga/"+id, nil)
+	apiMangaStats, err := fetchStats(ctx, key)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
-	err = apiMangaStats.SingleCacheData(id, "", 0).Write(utils.DataPath+api.Status.MangaStats+".json", true)
-	if err != nil {
-		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-	}
-	updateCacheStatus(api.Status.MangaStats, id)
 	
 	mangaStats := apiMangaStats.Stats(id)
 	if reflect.DeepEqual(mangaStats, api.Statistics{}) {
@@ -415,15 +474,32 @@ func StatRequest(id string) api.Statistics {
 	return mangaStats
 }
 
+// fetchStats
+//
+//	@Description: requests a manga's statistics from MangaDex API and caches them.
+//	@param ctx
+//	@param key: the cache key, holding the manga's id.
+//	@return models.ApiMangaStats
+//	@return error
+func fetchStats(ctx context.Context, key api.CacheKey) (api.ApiMangaStats, error) {
+	var apiMangaStats api.ApiMangaStats
+	err := apiMangaStats.SendRequest(ctx, BaseApiURL, "statistics/manga/"+key.Id, nil)
+	if err == nil {
+		storeCache(key, &apiMangaStats)
+	}
+	return apiMangaStats, err
+}
+
 // ImageProxy
 //
 //	@Description: requests a single cover image.
+//	@param ctx
 //	@param mangaId
 //	@param pictureName
 //	@return []byte
-func ImageProxy(mangaId, pictureName string) []byte {
-	reqUrl := "https://uploads.mangadex.org/covers/" + mangaId + "/" + pictureName
-	data, err := api.Request(reqUrl, nil)
+func ImageProxy(ctx context.Context, mangaId, pictureName string) []byte {
+	reqUrl := mangadex.UploadsURL + "/covers/" + mangaId + "/" + pictureName
+	data, err := api.Request(ctx, reqUrl, nil)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 	}
@@ -434,18 +510,19 @@ func ImageProxy(mangaId, pictureName string) []byte {
 // ScanProxy
 //
 //	@Description: requests a single scan image.
+//	@param ctx
 //	@param chapterId
 //	@param quality
 //	@param hash
 //	@param img
 //	@return []byte
-func ScanProxy(chapterId, quality, hash, img string) []byte {
-	chapter := ScanRequest(chapterId)
+func ScanProxy(ctx context.Context, chapterId, quality, hash, img string) []byte {
+	chapter := ScanRequest(ctx, chapterId)
 	if chapter.Chapter.Hash != hash && chapter.Chapter.Hash != "" {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("invalid hash (chapterScan)")))
 	}
 	reqUrl := chapter.BaseUrl + "/" + quality + "/" + hash + "/" + img
-	data, err := api.Request(reqUrl, nil)
+	data, err := api.Request(ctx, reqUrl, nil)
 	if err != nil {
 		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		for i, datum := range chapter.Chapter.Data {
@@ -454,7 +531,7 @@ func ScanProxy(chapterId, quality, hash, img string) []byte {
 			}
 		}
 		reqUrl = chapter.BaseUrl + "/dataSaver/" + hash + "/" + img
-		data, err = api.Request(reqUrl, nil)
+		data, err = api.Request(ctx, reqUrl, nil)
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
 		}
@@ -482,9 +559,9 @@ func AddFavoriteInfo(r *http.Request, mangas *[]api.MangaUsefullData) bool {
 		for _, favorite := range user.Favorites {
 			if favorite.Id == manga.Id {
 				(*mangas)[i].IsFavorite = true
-				(*mangas)[i].LastChapterRead = favorite.LastChapterRead
 			}
 		}
+		addReadingInfo(user, &(*mangas)[i])
 	}
 	return true
 }
@@ -507,8 +584,21 @@ func AddSingleFavoriteInfo(r *http.Request, manga *api.MangaUsefullData) bool {
 	for _, favorite := range user.Favorites {
 		if favorite.Id == manga.Id {
 			manga.IsFavorite = true
-			manga.LastChapterRead = favorite.LastChapterRead
 		}
 	}
+	addReadingInfo(user, manga)
 	return true
 }
+
+// addReadingInfo
+//
+//	@Description: adds the `user`'s reading progress to a `manga`.
+//	@param user
+//	@param manga
+func addReadingInfo(user server.User, manga *api.MangaUsefullData) {
+	if progress, ok := utils.ReadingProgress(user, manga.Id); ok {
+		manga.LastChapterRead = progress.LastChapterRead
+		manga.LastChapterReadNb = progress.LastChapterNb
+		manga.LastChapterReadOffset = progress.LastChapterOffset
+	}
+}
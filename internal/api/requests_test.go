@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/mangadex"
+	"mangathorg/internal/mangadex/mock"
+	"mangathorg/internal/models/api"
+	"mangathorg/internal/utils"
+)
+
+// feedID is a manga of the mock with 12 chapters.
+const feedID = "1bac27a7-b386-47a4-8991-603f28c13091"
+
+// useMock
+//
+//	@Description: points the MangaDex client to the mock and opens an empty
+//	cache in a temporary directory, for the duration of the test.
+//	@param t
+//	@return *atomic.Int32: the number of requests received by the mock.
+func useMock(t *testing.T) *atomic.Int32 {
+	fake, err := mock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		fake.ServeHTTP(w, r)
+	}))
+	
+	previousClient, previousURL, previousCache, previousLogger := mangadex.Default, BaseApiURL, cache, utils.Logger
+	t.Cleanup(func() {
+		server.Close()
+		mangadex.Default, BaseApiURL, cache, utils.Logger = previousClient, previousURL, previousCache, previousLogger
+	})
+	
+	mangadex.Default = mangadex.NewClient(strings.TrimPrefix(server.URL, "http://"))
+	BaseApiURL = server.URL + "/"
+	utils.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	disk, err := newDiskCache(t.TempDir()+"/", cacheVersions(), isOldCache)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache = &tieredCache{memory: newLRUCache(memoryCacheSize), disk: disk}
+	return &calls
+}
+
+func TestFeedRequestFromMock(t *testing.T) {
+	useMock(t)
+	
+	feed := FeedRequest(context.Background(), feedID, "asc", 0)
+	if feed.Total != 12 || len(feed.Data) != 12 {
+		t.Fatalf("got %d chapters of %d, want 12 of 12", len(feed.Data), feed.Total)
+	}
+	if first, last := feed.Data[0].Attributes.Chapter, feed.Data[11].Attributes.Chapter; first > last {
+		t.Fatalf("chapters not in ascending order: %s before %s", first, last)
+	}
+	
+	desc := FeedRequest(context.Background(), feedID, "desc", 0)
+	if len(desc.Data) != 12 || desc.Data[0].Id != feed.Data[11].Id {
+		t.Fatal("descending feed doesn't start with the last chapter")
+	}
+}
+
+func TestFeedRequestIsCached(t *testing.T) {
+	calls := useMock(t)
+	
+	FeedRequest(context.Background(), feedID, "asc", 0)
+	sent := calls.Load()
+	if sent == 0 {
+		t.Fatal("no request sent to the mock")
+	}
+	FeedRequest(context.Background(), feedID, "asc", 0)
+	if calls.Load() != sent {
+		t.Fatalf("%d requests sent for a cached feed", calls.Load()-sent)
+	}
+	
+	// an expired record is fetched again
+	key := api.CacheKey{Kind: api.Status.MangaFeeds, Id: feedID, Order: "asc"}
+	datum, ok := cache.Get(key)
+	if !ok {
+		t.Fatal("feed not cached")
+	}
+	policy := cachePolicies[api.Status.MangaFeeds]
+	datum.UpdatedTime = time.Now().Add(-policy.TTL - policy.MaxStale - time.Minute)
+	err := cache.Set(key, datum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := FeedRequest(context.Background(), feedID, "asc", 0)
+	if calls.Load() == sent || len(feed.Data) != 12 {
+		t.Fatal("expired feed not fetched again")
+	}
+	if datum, _ = cache.Get(key); time.Since(datum.UpdatedTime) > time.Minute {
+		t.Fatal("expired feed not replaced in the cache")
+	}
+}
+
+func TestFeedRequestServesStaleRecord(t *testing.T) {
+	calls := useMock(t)
+	
+	FeedRequest(context.Background(), feedID, "asc", 0)
+	key := api.CacheKey{Kind: api.Status.MangaFeeds, Id: feedID, Order: "asc"}
+	datum, _ := cache.Get(key)
+	datum.UpdatedTime = time.Now().Add(-cachePolicies[api.Status.MangaFeeds].TTL - time.Minute)
+	err := cache.Set(key, datum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent := calls.Load()
+	
+	feed := FeedRequest(context.Background(), feedID, "asc", 0)
+	if len(feed.Data) != 12 {
+		t.Fatal("stale feed not served")
+	}
+	// the record is refreshed in the background
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		datum, _ = cache.Get(key)
+		if time.Since(datum.UpdatedTime) < time.Minute {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stale feed not refreshed")
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	if calls.Load() == sent {
+		t.Fatal("no request sent to refresh the stale feed")
+	}
+}
@@ -0,0 +1,233 @@
+package mangadex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRetries is the number of times a failed request is sent again.
+	maxRetries = 3
+	
+	// callTimeout is the deadline of a whole call, retries included, and
+	// attemptTimeout the one of a single request.
+	callTimeout    = time.Second * 30
+	attemptTimeout = time.Second * 5
+	
+	// backoffBase and backoffMax bound the exponential backoff between two retries.
+	backoffBase = time.Millisecond * 500
+	backoffMax  = time.Second * 30
+)
+
+// ErrTooManyRequests is returned when MangaDex still answers 429 Too Many
+// Requests after all retries.
+var ErrTooManyRequests = errors.New("too many requests")
+
+// Client sends the requests to MangaDex, respecting its rate limits: about
+// 5 requests per second for the whole API, and 40 requests per minute for
+// the at-home/server endpoint. Failed requests (429, 5xx and network errors)
+// are retried with an exponential backoff, or after the delay asked by
+// MangaDex in the Retry-After and X-RateLimit-Retry-After headers.
+type Client struct {
+	HTTP    *http.Client
+	APIHost string
+	global  *Limiter
+	atHome  *Limiter
+}
+
+// NewClient
+//
+//	@Description: creates a Client for MangaDex API served on `apiHost`.
+//	@param apiHost
+//	@return *Client
+func NewClient(apiHost string) *Client {
+	return &Client{
+		HTTP:    &http.Client{},
+		APIHost: apiHost,
+		global:  NewLimiter(5, 5),
+		// 36 requests per minute after a burst of 4: never more than 40 in a minute
+		atHome: NewLimiter(0.6, 4),
+	}
+}
+
+// Default is the Client shared by the whole project.
+var Default = NewClient(hostOf(APIURL))
+
+// limiters
+//
+//	@Description: returns the Limiter that apply to a request's URL.
+//	@receiver c
+//	@param u
+//	@return []*Limiter
+func (c *Client) limiters(u *url.URL) []*Limiter {
+	if u.Host != c.APIHost {
+		return nil
+	}
+	if strings.HasPrefix(u.Path, "/at-home/server/") {
+		return []*Limiter{c.global, c.atHome}
+	}
+	return []*Limiter{c.global}
+}
+
+// Get
+//
+//	@Description: sends a GET request to an `url` with a `query`. The response's
+//	body is also returned when the status is not 200 OK.
+//	@receiver c
+//	@param ctx
+//	@param rawURL
+//	@param query
+//	@return []byte
+//	@return error
+func (c *Client) Get(ctx context.Context, rawURL string, query url.Values) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, rawURL, query)
+}
+
+// Head
+//
+//	@Description: sends a HEAD request to an `url`.
+//	@receiver c
+//	@param ctx
+//	@param rawURL
+//	@return error
+func (c *Client) Head(ctx context.Context, rawURL string) error {
+	_, err := c.do(ctx, http.MethodHead, rawURL, nil)
+	return err
+}
+
+// do
+//
+//	@Description: sends a request, retrying it if needed, until the `ctx` is done.
+//	@receiver c
+//	@param ctx
+//	@param method
+//	@param rawURL
+//	@param query
+//	@return []byte
+//	@return error
+func (c *Client) do(ctx context.Context, method string, rawURL string, query url.Values) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if query != nil {
+		req.URL.RawQuery = query.Encode()
+	}
+	limiters := c.limiters(req.URL)
+	
+	for attempt := 0; ; attempt++ {
+		for _, limiter := range limiters {
+			err = limiter.Wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		
+		var body []byte
+		var retryAfter time.Duration
+		attemptCtx, cancelAttempt := context.WithTimeout(ctx, attemptTimeout)
+		res, err := c.HTTP.Do(req.WithContext(attemptCtx))
+		if err == nil {
+			body, err = io.ReadAll(res.Body)
+			res.Body.Close()
+		}
+		cancelAttempt()
+		
+		if err == nil {
+			c.observe(res.Header, limiters)
+			if res.StatusCode == http.StatusOK {
+				return body, nil
+			}
+			err = errors.New("error " + res.Status)
+			if res.StatusCode == http.StatusTooManyRequests {
+				err = fmt.Errorf("%w: %s", ErrTooManyRequests, res.Status)
+			} else if res.StatusCode < 500 {
+				// the request is wrong, sending it again won't help
+				return body, err
+			}
+			retryAfter = retryDelay(res.Header)
+		}
+		
+		if attempt >= maxRetries || ctx.Err() != nil {
+			return body, err
+		}
+		delay := backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return body, err
+		}
+	}
+}
+
+// observe
+//
+//	@Description: pauses the `limiters` when the X-RateLimit-* headers of a
+//	response tell that no request is left until a given time.
+//	@receiver c
+//	@param header
+//	@param limiters
+func (c *Client) observe(header http.Header, limiters []*Limiter) {
+	remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	if err != nil || remaining > 0 {
+		return
+	}
+	retryAt, err := strconv.ParseInt(header.Get("X-RateLimit-Retry-After"), 10, 64)
+	if err != nil {
+		return
+	}
+	for _, limiter := range limiters {
+		limiter.Pause(time.Unix(retryAt, 0))
+	}
+}
+
+// retryDelay
+//
+//	@Description: returns the delay asked by MangaDex before retrying, from the
+//	Retry-After header (seconds or HTTP date) or the X-RateLimit-Retry-After
+//	header (Unix timestamp).
+//	@param header
+//	@return time.Duration
+func retryDelay(header http.Header) time.Duration {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return time.Until(date)
+		}
+	}
+	if value := header.Get("X-RateLimit-Retry-After"); value != "" {
+		if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Until(time.Unix(timestamp, 0))
+		}
+	}
+	return 0
+}
+
+// backoff
+//
+//	@Description: returns a random delay before the retry number `attempt`
+//	(exponential backoff with full jitter).
+//	@param attempt
+//	@return time.Duration
+func backoff(attempt int) time.Duration {
+	ceiling := min(backoffMax, backoffBase<<attempt)
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
@@ -0,0 +1,146 @@
+package mangadex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/mangadex/mock"
+)
+
+// feedID is a manga of the mock with 12 chapters.
+const feedID = "1bac27a7-b386-47a4-8991-603f28c13091"
+
+// newTestClient
+//
+//	@Description: starts the `handler` and returns a Client sending its
+//	requests to it, with the count of requests received.
+//	@param t
+//	@param handler
+//	@return *Client
+//	@return string: the handler's URL.
+//	@return *atomic.Int32
+func newTestClient(t *testing.T, handler http.Handler) (*Client, string, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(hostOf(server.URL)), server.URL, &calls
+}
+
+func TestClientFeedFromMock(t *testing.T) {
+	fake, err := mock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, baseURL, _ := newTestClient(t, fake)
+	
+	query := url.Values{"order[chapter]": {"asc"}, "limit": {"5"}, "offset": {"10"}}
+	body, err := client.Get(context.Background(), baseURL+"/manga/"+feedID+"/feed", query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed struct {
+		Data []struct {
+			Attributes struct {
+				Chapter string `json:"chapter"`
+			} `json:"attributes"`
+		} `json:"data"`
+		Total int `json:"total"`
+	}
+	err = json.Unmarshal(body, &feed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feed.Total != 12 || len(feed.Data) != 2 {
+		t.Fatalf("got %d chapters of %d, want 2 of 12", len(feed.Data), feed.Total)
+	}
+	
+	_, err = client.Get(context.Background(), baseURL+"/manga/unknown/feed", nil)
+	if err == nil {
+		t.Fatal("unknown manga: no error")
+	}
+}
+
+func TestClientRetriesTooManyRequests(t *testing.T) {
+	var attempts atomic.Int32
+	client, baseURL, calls := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	
+	body, err := client.Get(context.Background(), baseURL+"/manga", nil)
+	if err != nil || string(body) != `{"result":"ok"}` {
+		t.Fatalf("Get = %q, %v", body, err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("%d requests sent, want 3", calls.Load())
+	}
+}
+
+func TestClientGivesUpAfterRetries(t *testing.T) {
+	client, baseURL, calls := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	
+	_, err := client.Get(context.Background(), baseURL+"/manga", nil)
+	if !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("Get error = %v, want %v", err, ErrTooManyRequests)
+	}
+	if calls.Load() != maxRetries+1 {
+		t.Fatalf("%d requests sent, want %d", calls.Load(), maxRetries+1)
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	fake, err := mock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, baseURL, calls := newTestClient(t, fake)
+	
+	_, err = client.Get(context.Background(), baseURL+"/manga/"+feedID+"/feed", url.Values{"limit": {"-1"}})
+	if err == nil {
+		t.Fatal("invalid limit: no error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("%d requests sent, want 1", calls.Load())
+	}
+}
+
+func TestClientPausesOnRateLimitHeaders(t *testing.T) {
+	client, baseURL, _ := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Retry-After", strconv.FormatInt(time.Now().Add(time.Second*2).Unix(), 10))
+		w.Write([]byte(`{"result":"ok"}`))
+	}))
+	
+	_, err := client.Get(context.Background(), baseURL+"/manga", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait := client.global.reserve(); wait < time.Second {
+		t.Fatalf("next request waits %s, the limiter wasn't paused", wait)
+	}
+	
+	// a cancelled request gives up instead of waiting for the end of the pause
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = client.Get(ctx, baseURL+"/manga", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Get error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
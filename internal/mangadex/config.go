@@ -0,0 +1,45 @@
+package mangadex
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// APIURL is the URL of MangaDex API (without trailing slash). It can be set
+// with the MANGADEX_API_URL environment variable, to use a mock for example.
+var APIURL = urlFromEnv("MANGADEX_API_URL", "https://api.mangadex.org")
+
+// UploadsURL is the URL of the host serving MangaDex covers (without trailing
+// slash). It can be set with the MANGADEX_UPLOADS_URL environment variable.
+var UploadsURL = urlFromEnv("MANGADEX_UPLOADS_URL", "https://uploads.mangadex.org")
+
+// urlFromEnv
+//
+//	@Description: reads an URL from the environment variable `name`, or
+//	returns `fallback` if it is not set. An invalid URL stops the program.
+//	@param name
+//	@param fallback
+//	@return string
+func urlFromEnv(name, fallback string) string {
+	value := strings.TrimRight(os.Getenv(name), "/")
+	if value == "" {
+		return fallback
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		log.Fatalf("invalid %s: %q", name, value)
+	}
+	return value
+}
+
+// hostOf
+//
+//	@Description: returns the host (with port) of a valid `rawURL`.
+//	@param rawURL
+//	@return string
+func hostOf(rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	return u.Host
+}
@@ -0,0 +1,111 @@
+package mangadex
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket: it allows `rate` requests per second on average,
+// with bursts of up to `burst` requests.
+type Limiter struct {
+	mutex       sync.Mutex
+	rate        float64
+	burst       float64
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+}
+
+// NewLimiter
+//
+//	@Description: creates a full Limiter.
+//	@param rate: the number of requests allowed per second.
+//	@param burst: the maximum number of requests allowed at once.
+//	@return *Limiter
+func NewLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// reserve
+//
+//	@Description: takes a token and returns how long the caller must wait
+//	before using it.
+//	@receiver l
+//	@return time.Duration
+func (l *Limiter) reserve() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	if pause := l.pausedUntil.Sub(now); pause > 0 {
+		wait += pause
+	}
+	return wait
+}
+
+// cancel
+//
+//	@Description: gives back a token taken by reserve and not used, so that
+//	the cancelled requests don't delay the next ones.
+//	@receiver l
+func (l *Limiter) cancel() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// Wait
+//
+//	@Description: blocks until a request is allowed or the `ctx` is done.
+//	@receiver l
+//	@param ctx
+//	@return error: the context's error if it is done before.
+func (l *Limiter) Wait(ctx context.Context) error {
+	wait := l.reserve()
+	if wait <= 0 {
+		if ctx.Err() != nil {
+			l.cancel()
+		}
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// Pause
+//
+//	@Description: empties the bucket and blocks all requests until `until`
+//	(used when MangaDex tells the client to slow down).
+//	@receiver l
+//	@param until
+func (l *Limiter) Pause(until time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	
+	l.tokens = min(l.tokens, 0)
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
@@ -0,0 +1,58 @@
+package mangadex
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiterBurst(t *testing.T) {
+	limiter := NewLimiter(1, 3)
+	for i := 0; i < 3; i++ {
+		if wait := limiter.reserve(); wait > 0 {
+			t.Fatalf("request %d waits %s within the burst", i, wait)
+		}
+	}
+	if wait := limiter.reserve(); wait <= 0 {
+		t.Fatal("request after the burst doesn't wait")
+	}
+}
+
+func TestLimiterCancelledWaitGivesTokenBack(t *testing.T) {
+	limiter := NewLimiter(0.5, 1)
+	limiter.reserve()
+	
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		err := limiter.Wait(ctx)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Wait = %v, want %v", err, context.DeadlineExceeded)
+		}
+	}
+	// only the first reservation is still pending: the next one waits about 2s, not 12s
+	if wait := limiter.reserve(); wait > time.Second*3 {
+		t.Fatalf("next request waits %s, the cancelled ones weren't given back", wait)
+	}
+}
+
+func TestLimiterCancelledContext(t *testing.T) {
+	limiter := NewLimiter(1, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Wait = %v, want %v", err, context.Canceled)
+	}
+	if wait := limiter.reserve(); wait > 0 {
+		t.Fatalf("token of the cancelled request wasn't given back, wait %s", wait)
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	limiter := NewLimiter(100, 10)
+	limiter.Pause(time.Now().Add(time.Second))
+	if wait := limiter.reserve(); wait < time.Millisecond*900 {
+		t.Fatalf("paused limiter waits %s only", wait)
+	}
+}
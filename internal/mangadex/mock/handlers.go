@@ -0,0 +1,393 @@
+package mock
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// writeJSON
+//
+//	@Description: sends `v` as a JSON response with the `status` code.
+//	@param w
+//	@param status
+//	@param v
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError
+//
+//	@Description: sends an error response shaped like MangaDex API's ones.
+//	@param w
+//	@param status
+//	@param detail
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJSON(w, status, map[string]any{
+		"result": "error",
+		"errors": []map[string]any{{
+			"id":      "mock",
+			"status":  status,
+			"title":   http.StatusText(status),
+			"detail":  detail,
+			"context": nil,
+		}},
+	})
+}
+
+// page
+//
+//	@Description: reads the limit and offset query params of `r` and returns
+//	them along with the matching part of `data`.
+//	@param r
+//	@param data
+//	@param defaultLimit
+//	@param maxLimit
+//	@return collection
+//	@return bool: false if the params are invalid.
+func page(r *http.Request, data []json.RawMessage, defaultLimit, maxLimit int) (collection, bool) {
+	limit, offset := defaultLimit, 0
+	var err error
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 0 || limit > maxLimit {
+			return collection{}, false
+		}
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		offset, err = strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return collection{}, false
+		}
+	}
+	start := min(offset, len(data))
+	end := min(offset+limit, len(data))
+	return collection{
+		Result:   "ok",
+		Response: "collection",
+		Data:     append([]json.RawMessage{}, data[start:end]...),
+		Limit:    limit,
+		Offset:   offset,
+		Total:    len(data),
+	}, true
+}
+
+// findManga
+//
+//	@Description: returns the recorded manga of the `id`.
+//	@receiver s
+//	@param id
+//	@return manga
+//	@return bool
+func (s *Server) findManga(id string) (manga, bool) {
+	for _, m := range s.mangas {
+		if m.Id == id {
+			return m, true
+		}
+	}
+	return manga{}, false
+}
+
+// authors
+//
+//	@Description: returns the ids and lowercase names of the manga's authors,
+//	and of its artists if `withArtists` is true.
+//	@receiver m
+//	@param withArtists
+//	@return []string
+func (m manga) authors(withArtists bool) []string {
+	var authors []string
+	for _, relationship := range m.Relationships {
+		if relationship.Type == "author" || (withArtists && relationship.Type == "artist") {
+			authors = append(authors, relationship.Id, strings.ToLower(relationship.Attributes.Name))
+		}
+	}
+	return authors
+}
+
+// hasTags
+//
+//	@Description: checks if the manga has all the tags (`all` true) or any of
+//	them.
+//	@receiver m
+//	@param tags
+//	@param all
+//	@return bool
+func (m manga) hasTags(tags []string, all bool) bool {
+	for _, tag := range tags {
+		found := slices.ContainsFunc(m.Attributes.Tags, func(t tagRef) bool { return t.Id == tag })
+		if found != all {
+			return found
+		}
+	}
+	return all
+}
+
+// matches
+//
+//	@Description: checks if the manga matches the filters of a GET /manga request.
+//	@receiver m
+//	@param query
+//	@return bool
+func (m manga) matches(query url.Values) bool {
+	if ids := query["ids[]"]; len(ids) > 0 && !slices.Contains(ids, m.Id) {
+		return false
+	}
+	if title := strings.ToLower(query.Get("title")); title != "" {
+		found := false
+		for _, value := range m.Attributes.Title {
+			found = found || strings.Contains(strings.ToLower(value), title)
+		}
+		if !found {
+			return false
+		}
+	}
+	if author := strings.ToLower(query.Get("author")); author != "" && !slices.Contains(m.authors(false), author) {
+		return false
+	}
+	if author := strings.ToLower(query.Get("authorOrArtist")); author != "" && !slices.Contains(m.authors(true), author) {
+		return false
+	}
+	if tags := query["includedTags[]"]; len(tags) > 0 && !m.hasTags(tags, query.Get("includedTagsMode") != "OR") {
+		return false
+	}
+	if tags := query["excludedTags[]"]; len(tags) > 0 && m.hasTags(tags, query.Get("excludedTagsMode") == "AND") {
+		return false
+	}
+	if status := query["status[]"]; len(status) > 0 && !slices.Contains(status, m.Attributes.Status) {
+		return false
+	}
+	if publics := query["publicationDemographic[]"]; len(publics) > 0 {
+		public := "none"
+		if m.Attributes.PublicationDemographic != nil {
+			public = *m.Attributes.PublicationDemographic
+		}
+		if !slices.Contains(publics, public) {
+			return false
+		}
+	}
+	if ratings := query["contentRating[]"]; len(ratings) > 0 && !slices.Contains(ratings, m.Attributes.ContentRating) {
+		return false
+	}
+	return true
+}
+
+// compareMangas
+//
+//	@Description: returns the function comparing two mangas according to the
+//	order[{field}] query params of a GET /manga request.
+//	@receiver s
+//	@param query
+//	@return func(a, b manga) int
+func (s *Server) compareMangas(query url.Values) func(a, b manga) int {
+	var fields []string
+	for name := range query {
+		if field, ok := strings.CutPrefix(name, "order["); ok {
+			fields = append(fields, strings.TrimSuffix(field, "]"))
+		}
+	}
+	slices.Sort(fields)
+	
+	return func(a, b manga) int {
+		for _, field := range fields {
+			var result int
+			switch field {
+			case "title":
+				result = cmp.Compare(a.Attributes.Title["en"], b.Attributes.Title["en"])
+			case "year":
+				result = cmp.Compare(yearOf(a), yearOf(b))
+			case "createdAt":
+				result = cmp.Compare(a.Attributes.CreatedAt, b.Attributes.CreatedAt)
+			case "updatedAt", "latestUploadedChapter":
+				result = cmp.Compare(a.Attributes.UpdatedAt, b.Attributes.UpdatedAt)
+			case "rating":
+				result = cmp.Compare(s.rating[a.Id], s.rating[b.Id])
+			case "followedCount":
+				result = cmp.Compare(s.followers[a.Id], s.followers[b.Id])
+			}
+			if query.Get("order["+field+"]") == "desc" {
+				result = -result
+			}
+			if result != 0 {
+				return result
+			}
+		}
+		return 0
+	}
+}
+
+// yearOf
+//
+//	@Description: returns the manga's year, or 0 if it is unknown.
+//	@param m
+//	@return int
+func yearOf(m manga) int {
+	if m.Attributes.Year == nil {
+		return 0
+	}
+	return *m.Attributes.Year
+}
+
+// mangaList serves GET /manga.
+func (s *Server) mangaList(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var mangas []manga
+	for _, m := range s.mangas {
+		if m.matches(query) {
+			mangas = append(mangas, m)
+		}
+	}
+	slices.SortStableFunc(mangas, s.compareMangas(query))
+	
+	data := make([]json.RawMessage, len(mangas))
+	for i, m := range mangas {
+		data[i] = m.Raw
+	}
+	result, ok := page(r, data, 10, 100)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid limit or offset")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// tagList serves GET /manga/tag.
+func (s *Server) tagList(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.tags)
+}
+
+// mangaById serves GET /manga/{id}.
+func (s *Server) mangaById(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.findManga(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "Manga with ID \""+r.PathValue("id")+"\" was not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result":   "ok",
+		"response": "entity",
+		"data":     m.Raw,
+	})
+}
+
+// mangaFeed serves GET /manga/{id}/feed.
+func (s *Server) mangaFeed(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.findManga(r.PathValue("id")); !ok {
+		writeError(w, http.StatusNotFound, "Manga with ID \""+r.PathValue("id")+"\" was not found")
+		return
+	}
+	chapters := slices.Clone(s.feeds[r.PathValue("id")])
+	slices.SortStableFunc(chapters, func(a, b chapter) int {
+		x, _ := strconv.ParseFloat(a.Attributes.Chapter, 64)
+		y, _ := strconv.ParseFloat(b.Attributes.Chapter, 64)
+		return cmp.Compare(x, y)
+	})
+	if r.URL.Query().Get("order[chapter]") == "desc" {
+		slices.Reverse(chapters)
+	}
+	
+	data := make([]json.RawMessage, len(chapters))
+	for i, c := range chapters {
+		data[i] = c.Raw
+	}
+	result, ok := page(r, data, 100, 500)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "invalid limit or offset")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mangaStats serves GET /statistics/manga/{id}.
+func (s *Server) mangaStats(w http.ResponseWriter, r *http.Request) {
+	stats, ok := s.stats[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Manga with ID \""+r.PathValue("id")+"\" was not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result":     "ok",
+		"statistics": map[string]json.RawMessage{r.PathValue("id"): stats},
+	})
+}
+
+// atHomeServer serves GET /at-home/server/{id}: the scans are served by the
+// mock itself.
+func (s *Server) atHomeServer(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.chapters[r.PathValue("id")]
+	if !ok {
+		writeError(w, http.StatusNotFound, "Chapter with ID \""+r.PathValue("id")+"\" was not found")
+		return
+	}
+	sum := sha256.Sum256([]byte(c.Id))
+	hash := hex.EncodeToString(sum[:16])
+	data := make([]string, c.Attributes.Pages)
+	dataSaver := make([]string, c.Attributes.Pages)
+	for i := range data {
+		data[i] = "x" + strconv.Itoa(i+1) + "-" + hash + ".png"
+		dataSaver[i] = "x" + strconv.Itoa(i+1) + "-" + hash + ".jpg"
+	}
+	
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"result":  "ok",
+		"baseUrl": scheme + "://" + r.Host,
+		"chapter": map[string]any{
+			"hash":      hash,
+			"data":      data,
+			"dataSaver": dataSaver,
+		},
+	})
+}
+
+// image serves the covers and the scans: a plain picture whose color depends
+// on its path.
+func (s *Server) image(w http.ResponseWriter, r *http.Request) {
+	h := fnv.New32a()
+	h.Write([]byte(r.URL.Path))
+	sum := h.Sum32()
+	fill := color.RGBA{R: uint8(sum >> 16), G: uint8(sum >> 8), B: uint8(sum), A: 255}
+	
+	width, height := 512, 728
+	if strings.HasPrefix(r.URL.Path, "/covers/") {
+		width, height = 256, 364
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// a frame, to tell the pages apart
+			if x < 8 || y < 8 || x >= width-8 || y >= height-8 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+	
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,213 @@
+// Package mock is a fake MangaDex: it serves the API endpoints used by the
+// project (mangas, feeds, statistics, tags and at-home servers) and the
+// uploads (covers and scans) from the responses recorded in `fixtures`, so
+// that the website and the scraper can run offline.
+//
+// Point MANGADEX_API_URL and MANGADEX_UPLOADS_URL to the mock's URL to use it.
+package mock
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// fixtures holds the recorded responses:
+//   - manga.json: GET /manga with every manga of the mock.
+//   - tag.json: GET /manga/tag.
+//   - statistics.json: GET /statistics/manga with every manga of the mock.
+//   - feed/{manga-id}.json: GET /manga/{manga-id}/feed with all its chapters.
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// manga is a recorded manga, along with the fields used to filter and sort
+// the mangas.
+type manga struct {
+	Raw        json.RawMessage `json:"-"`
+	Id         string          `json:"id"`
+	Attributes struct {
+		Title                  map[string]string `json:"title"`
+		PublicationDemographic *string           `json:"publicationDemographic"`
+		Status                 string            `json:"status"`
+		Year                   *int              `json:"year"`
+		ContentRating          string            `json:"contentRating"`
+		Tags                   []tagRef          `json:"tags"`
+		CreatedAt              string            `json:"createdAt"`
+		UpdatedAt              string            `json:"updatedAt"`
+	} `json:"attributes"`
+	Relationships []struct {
+		Id         string `json:"id"`
+		Type       string `json:"type"`
+		Attributes struct {
+			Name string `json:"name"`
+		} `json:"attributes"`
+	} `json:"relationships"`
+}
+
+// tagRef is a tag of a recorded manga.
+type tagRef struct {
+	Id string `json:"id"`
+}
+
+// chapter is a recorded chapter, along with the fields used to sort the
+// chapters and to build its at-home server response.
+type chapter struct {
+	Raw        json.RawMessage `json:"-"`
+	Id         string          `json:"id"`
+	Attributes struct {
+		Chapter string `json:"chapter"`
+		Pages   int    `json:"pages"`
+	} `json:"attributes"`
+}
+
+// collection is the shape of MangaDex API's list responses.
+type collection struct {
+	Result   string            `json:"result"`
+	Response string            `json:"response"`
+	Data     []json.RawMessage `json:"data"`
+	Limit    int               `json:"limit"`
+	Offset   int               `json:"offset"`
+	Total    int               `json:"total"`
+}
+
+// Server is the fake MangaDex, serving both the API and the uploads.
+type Server struct {
+	mux       *http.ServeMux
+	mangas    []manga
+	feeds     map[string][]chapter
+	chapters  map[string]chapter
+	stats     map[string]json.RawMessage
+	tags      []byte
+	rating    map[string]float64
+	followers map[string]int
+}
+
+// New
+//
+//	@Description: loads the fixtures and creates a Server.
+//	@return *Server
+//	@return error: if a fixture can't be read.
+func New() (*Server, error) {
+	s := &Server{
+		mux:       http.NewServeMux(),
+		feeds:     make(map[string][]chapter),
+		chapters:  make(map[string]chapter),
+		stats:     make(map[string]json.RawMessage),
+		rating:    make(map[string]float64),
+		followers: make(map[string]int),
+	}
+	err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	
+	s.mux.HandleFunc("GET /manga", s.mangaList)
+	s.mux.HandleFunc("GET /manga/tag", s.tagList)
+	s.mux.HandleFunc("GET /manga/{id}", s.mangaById)
+	s.mux.HandleFunc("GET /manga/{id}/feed", s.mangaFeed)
+	s.mux.HandleFunc("GET /statistics/manga/{id}", s.mangaStats)
+	s.mux.HandleFunc("GET /at-home/server/{id}", s.atHomeServer)
+	s.mux.HandleFunc("GET /covers/{manga}/{file}", s.image)
+	s.mux.HandleFunc("GET /data/{hash}/{file}", s.image)
+	s.mux.HandleFunc("GET /data-saver/{hash}/{file}", s.image)
+	s.mux.HandleFunc("GET /dataSaver/{hash}/{file}", s.image)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "no route matched with those values")
+	})
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// load
+//
+//	@Description: reads all fixtures.
+//	@receiver s
+//	@return error
+func (s *Server) load() error {
+	var err error
+	s.tags, err = fixtures.ReadFile("fixtures/tag.json")
+	if err != nil {
+		return err
+	}
+	
+	var mangas collection
+	err = readFixture("fixtures/manga.json", &mangas)
+	if err != nil {
+		return err
+	}
+	for _, raw := range mangas.Data {
+		m := manga{Raw: raw}
+		err = json.Unmarshal(raw, &m)
+		if err != nil {
+			return err
+		}
+		s.mangas = append(s.mangas, m)
+	}
+	
+	var stats struct {
+		Statistics map[string]json.RawMessage `json:"statistics"`
+	}
+	err = readFixture("fixtures/statistics.json", &stats)
+	if err != nil {
+		return err
+	}
+	for id, raw := range stats.Statistics {
+		var stat struct {
+			Rating struct {
+				Bayesian float64 `json:"bayesian"`
+			} `json:"rating"`
+			Follows int `json:"follows"`
+		}
+		err = json.Unmarshal(raw, &stat)
+		if err != nil {
+			return err
+		}
+		s.stats[id] = raw
+		s.rating[id] = stat.Rating.Bayesian
+		s.followers[id] = stat.Follows
+	}
+	
+	files, err := fs.ReadDir(fixtures, "fixtures/feed")
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		var feed collection
+		err = readFixture("fixtures/feed/"+file.Name(), &feed)
+		if err != nil {
+			return err
+		}
+		id := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+		for _, raw := range feed.Data {
+			c := chapter{Raw: raw}
+			err = json.Unmarshal(raw, &c)
+			if err != nil {
+				return err
+			}
+			s.feeds[id] = append(s.feeds[id], c)
+			s.chapters[c.Id] = c
+		}
+	}
+	return nil
+}
+
+// readFixture
+//
+//	@Description: decodes the fixture `name` into `v`.
+//	@param name
+//	@param v
+//	@return error
+func readFixture(name string, v any) error {
+	data, err := fixtures.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
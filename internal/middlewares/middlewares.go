@@ -2,11 +2,12 @@ package middlewares
 
 import (
 	"errors"
+	"html/template"
 	"log"
 	"log/slog"
 	"net/http"
 	
-	"mangathorg/internal/models/api"
+	"mangathorg/internal/mangadex"
 	"mangathorg/internal/models/server"
 	"mangathorg/internal/utils"
 )
@@ -24,12 +25,33 @@ var Log server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 		if err != nil {
 			utils.Logger.Info("Visitor", slog.Int("req_id", LogId), slog.String("client_ip", utils.GetIP(r)), slog.String("req_method", r.Method), slog.String("req_url", r.URL.String()))
 		} else {
-			utils.Logger.Info("User", slog.Int("req_id", LogId), slog.Any("user", utils.SessionsData[cookie.Value]), slog.String("client_ip", utils.GetIP(r)), slog.String("req_method", r.Method), slog.String("req_url", r.URL.String()))
+			session, _ := utils.LookupSession(cookie.Value)
+			utils.Logger.Info("User", slog.Int("req_id", LogId), slog.Any("user", session), slog.String("client_ip", utils.GetIP(r)), slog.String("req_method", r.Method), slog.String("req_url", r.URL.String()))
 		}
 		next.ServeHTTP(w, r)
 	}
 }
 
+// authenticate
+//
+//	@Description: checks if the client has a valid opened session and slides
+//	its expiration time, or opens a new one from its "remember me" token.
+//	@param w
+//	@param r
+//	@return *http.Request: holding the client's session ID.
+//	@return bool: false if the client isn't logged.
+func authenticate(w *http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if !utils.CheckSession(r) {
+		return utils.ResumeSession(w, r)
+	}
+	
+	r, err := utils.RefreshSession(w, r)
+	if err != nil {
+		utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err), slog.Int("req_id", LogId))
+	}
+	return r, true
+}
+
 // Guard is a models.Middleware that verify if a user has an opened session
 // through the cookies and let it pass if ok, and redirects if not.
 var Guard server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
@@ -37,18 +59,13 @@ var Guard server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 		log.Println("middlewares.Guard()")
 		
 		// Checks if the user has a valid opened session
-		ok := utils.CheckSession(r)
+		r, ok := authenticate(&w, r)
 		if !ok {
 			utils.Logger.Warn("Invalid session", slog.Int("req_id", LogId), slog.String("req_url", r.URL.String()), slog.Int("http_status", http.StatusUnauthorized))
 			http.Redirect(w, r, "/login?err=restricted", http.StatusSeeOther)
 			return
 		}
 		
-		err := utils.RefreshSession(&w, r)
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err), slog.Int("req_id", LogId))
-		}
-		
 		// Use user data (e.g., display username) // testing
 		// fmt.Fprintf(w, "Welcome, user %s", userData["user_id"])
 		next.ServeHTTP(w, r)
@@ -62,39 +79,42 @@ var SimpleGuard server.Middleware = func(next http.HandlerFunc) http.HandlerFunc
 		log.Println("middlewares.SimpleGuard()")
 		
 		// Checks if the user has a valid opened session
-		ok := utils.CheckSession(r)
+		r, ok := authenticate(&w, r)
 		if !ok {
 			utils.Logger.Warn("Invalid session", slog.Int("req_id", LogId), slog.String("req_url", r.URL.String()), slog.Int("http_status", http.StatusUnauthorized))
 			http.Error(w, "Invalid session", http.StatusUnauthorized)
 			return
 		}
 		
-		// retrieving the in-memory current session data
-		cookie, err := r.Cookie("session_id")
-		if err != nil {
-			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err))
-		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// ApiGuard is a models.Middleware that verify if a user has an opened session
+// through the cookies and let it pass if ok, and send a JSON error if not
+// (used by the JSON API).
+var ApiGuard server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("middlewares.ApiGuard()")
 		
-		// copying the cookie in the updatedCookie to retrieve it in the next handler
-		cookie.Name = "updatedCookie"
-		r.AddCookie(cookie)
+		// Checks if the user has a valid opened session
+		r, ok := authenticate(&w, r)
+		if !ok {
+			utils.Logger.Warn("Invalid session", slog.Int("req_id", LogId), slog.String("req_url", r.URL.String()), slog.Int("http_status", http.StatusUnauthorized))
+			utils.SendJSONError(w, http.StatusUnauthorized, "restricted access: you need a valid session to proceed")
+			return
+		}
 		
 		next.ServeHTTP(w, r)
 	}
 }
 
 // UserCheck is a models.Middleware that checks if the client is logged,
-// and if yes, it refreshes its sessionID
+// and if yes, it refreshes its session
 var UserCheck server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("middlewares.UserCheck()")
-		exists := utils.CheckSession(r)
-		if exists {
-			err := utils.RefreshSession(&w, r)
-			if err != nil {
-				utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", err), slog.Int("req_id", LogId))
-			}
-		}
+		r, _ = authenticate(&w, r)
 		next.ServeHTTP(w, r)
 	}
 }
@@ -104,7 +124,7 @@ var UserCheck server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 var OnlyVisitors server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Println("middlewares.OnlyVisitors()")
-		exists := utils.CheckSession(r)
+		_, exists := authenticate(&w, r)
 		if exists {
 			http.Redirect(w, r, "/principal", http.StatusSeeOther)
 			return
@@ -113,12 +133,88 @@ var OnlyVisitors server.Middleware = func(next http.HandlerFunc) http.HandlerFun
 	}
 }
 
+// CSRF is a models.Middleware that checks the CSRF token sent with the
+// state-changing requests, and displays the Error403 page if it is missing or
+// invalid. It must be joined after the session middlewares, since the token is
+// bound to the client's session.
+var CSRF server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		log.Println("middlewares.CSRF()")
+		r, ok := utils.CheckCSRF(&w, r)
+		if !ok {
+			utils.Logger.Warn("Invalid CSRF token", slog.Int("req_id", LogId), slog.String("client_ip", utils.GetIP(r)), slog.String("req_url", r.URL.String()), slog.Int("http_status", http.StatusForbidden))
+			forbidden(w, r, "We couldn’t verify your request, please reload the page...")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// RequireRole
+//
+//	@Description: returns a models.Middleware that lets the users holding the
+//	`role` (or a higher one) pass, and displays the Error403 page to the others.
+//	It must be joined after Guard, since it reads the client's session.
+//	@param role
+//	@return server.Middleware
+func RequireRole(role string) server.Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			log.Println("middlewares.RequireRole()")
+			session, _ := utils.GetSession(r)
+			user, ok := utils.SelectUser(session.Username)
+			if !ok || !utils.HasRole(user, role) {
+				utils.Logger.Warn("Insufficient role", slog.Int("req_id", LogId), slog.Any("user", session), slog.String("role", role), slog.String("req_url", r.URL.String()), slog.Int("http_status", http.StatusForbidden))
+				forbidden(w, r, "You aren’t allowed to access this page...")
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+	}
+}
+
+// forbidden
+//
+//	@Description: displays the Error403 page with the `message`.
+//	@param w
+//	@param r
+//	@param message
+func forbidden(w http.ResponseWriter, r *http.Request, message string) {
+	w.WriteHeader(http.StatusForbidden)
+	
+	var data = struct {
+		IsConnected bool
+		Username    string
+		AvatarImg   string
+		Message     string
+	}{
+		Message: message,
+	}
+	if utils.CheckSession(r) {
+		session, _ := utils.GetSession(r)
+		if user, ok := utils.SelectUser(session.Username); ok {
+			data.IsConnected = true
+			data.Username = user.Username
+			data.AvatarImg = user.Avatar
+		}
+	}
+	
+	tmpl, err := template.ParseFiles(utils.Path+"templates/base.gohtml", utils.Path+"templates/header-line2.gohtml", utils.Path+"templates/error403.gohtml")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = tmpl.ExecuteTemplate(w, "base", data)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
 // CheckApi is a models.Middleware that checks if the API is working properly,
 // and if no, it redirects to the API error handler.
 var CheckApi server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		
-		_, err := api.Client.Head("https://api.mangadex.org/manga/tag")
+		err := mangadex.Default.Head(r.Context(), mangadex.APIURL+"/manga/tag")
 		if err != nil {
 			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("an error occurred with the API")))
 			http.Redirect(w, r, "/ErrorAPI", http.StatusSeeOther)
@@ -129,6 +225,22 @@ var CheckApi server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ApiCheckApi is a models.Middleware that checks if MangaDex API is working
+// properly, and if no, it sends a JSON error (used by the JSON API).
+var ApiCheckApi server.Middleware = func(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		
+		err := mangadex.Default.Head(r.Context(), mangadex.APIURL+"/manga/tag")
+		if err != nil {
+			utils.Logger.Error(utils.GetCurrentFuncName(), slog.Any("output", errors.New("an error occurred with the API")))
+			utils.SendJSONError(w, http.StatusBadGateway, "MangaDex API is unavailable")
+			return
+		}
+		
+		next.ServeHTTP(w, r)
+	}
+}
+
 // Join is used to concatenate various middlewares, for better visibility.
 // it takes the http.HandlerFunc corresponding to the route, and then
 // any number of models.Middleware that will be concatenated in order like this:
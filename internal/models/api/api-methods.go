@@ -1,23 +1,19 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
-	"io"
 	"log"
 	"math"
-	"net/http"
 	"net/url"
 	"strconv"
 	"sync"
 	"time"
+	
+	"mangathorg/internal/mangadex"
 )
 
-// Client is the http.Client used for all API requests.
-var Client = http.Client{
-	Timeout: time.Second * 5,
-}
-
 // Params
 //
 //	@Description: generates all parameters names for a specific manga request.
@@ -196,11 +192,12 @@ func (data *ApiMangaStats) SingleCacheData(id string, order string, offset int)
 //
 //	@Description: sends an ApiManga request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiManga) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiManga) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
@@ -208,7 +205,7 @@ func (data *ApiManga) SendRequest(baseURL string, endpoint string, query url.Val
 	query.Add("includes[]", "cover_art")
 	query.Add("includes[]", "author")
 	
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -230,11 +227,12 @@ func (data *ApiManga) SendRequest(baseURL string, endpoint string, query url.Val
 //
 //	@Description: sends an ApiSingleManga request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiSingleManga) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiSingleManga) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
@@ -242,7 +240,7 @@ func (data *ApiSingleManga) SendRequest(baseURL string, endpoint string, query u
 	query.Add("includes[]", "cover_art")
 	query.Add("includes[]", "author")
 	
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -264,15 +262,16 @@ func (data *ApiSingleManga) SendRequest(baseURL string, endpoint string, query u
 //
 //	@Description: sends an ApiTags request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiTags) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiTags) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -294,16 +293,17 @@ func (data *ApiTags) SendRequest(baseURL string, endpoint string, query url.Valu
 //
 //	@Description: sends an ApiMangaFeed request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiMangaFeed) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiMangaFeed) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
 	query.Add("translatedLanguage[]", "en")
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -326,15 +326,16 @@ func (data *ApiMangaFeed) SendRequest(baseURL string, endpoint string, query url
 //
 //	@Description: sends an ApiChapterScan request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiChapterScan) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiChapterScan) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -356,15 +357,16 @@ func (data *ApiChapterScan) SendRequest(baseURL string, endpoint string, query u
 //
 //	@Description: sends an ApiMangaStats request.
 //	@receiver data
+//	@param ctx
 //	@param baseURL
 //	@param endpoint
 //	@param query
 //	@return error
-func (data *ApiMangaStats) SendRequest(baseURL string, endpoint string, query url.Values) error {
+func (data *ApiMangaStats) SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error {
 	if query == nil {
 		query = make(url.Values)
 	}
-	body, err := Request(baseURL+endpoint, query)
+	body, err := Request(ctx, baseURL+endpoint, query)
 	if err != nil {
 		return err
 	}
@@ -384,39 +386,15 @@ func (data *ApiMangaStats) SendRequest(baseURL string, endpoint string, query ur
 
 // Request
 //
-//	@Description: sends a request to an `url` with a `query`.
+//	@Description: sends a request to an `url` with a `query` through the shared
+//	rate-limited MangaDex client.
+//	@param ctx
 //	@param url
 //	@param query
 //	@return []byte
 //	@return error
-func Request(url string, query url.Values) ([]byte, error) {
-	req, errReq := http.NewRequest(http.MethodGet, url, nil)
-	if errReq != nil {
-		return nil, errReq
-	}
-	
-	if query != nil {
-		req.URL.RawQuery = query.Encode()
-	}
-	
-	res, errRes := Client.Do(req)
-	if res.Body != nil {
-		defer res.Body.Close()
-	} else {
-		return nil, errRes
-	}
-	
-	body, errBody := io.ReadAll(res.Body)
-	if errBody != nil {
-		return nil, errBody
-	}
-	
-	var err error
-	if res.StatusCode != 200 {
-		err = errors.New("error " + res.Status)
-	}
-	
-	return body, err
+func Request(ctx context.Context, url string, query url.Values) ([]byte, error) {
+	return mangadex.Default.Get(ctx, url, query)
 }
 
 // Stats
@@ -450,16 +428,20 @@ func (data *ApiMangaStats) Stats(id string) Statistics {
 //
 //	@Description: converts an ApiManga to a MangasInBulk with all needed data.
 //	@receiver data
+//	@param ctx
 //	@return MangasInBulk
-func (data *ApiManga) Format() MangasInBulk {
+func (data *ApiManga) Format(ctx context.Context) MangasInBulk {
 	var formattedMangas MangasInBulk
+	if len(data.Data) > 0 {
+		formattedMangas.Mangas = make([]MangaUsefullData, len(data.Data))
+	}
 	var wg sync.WaitGroup
-	for _, datum := range data.Data {
+	for i := range data.Data {
 		wg.Add(1)
-		go func(data *Manga, mangas *[]MangaUsefullData, wg *sync.WaitGroup) {
+		go func(i int) {
 			defer wg.Done()
-			*mangas = append(*mangas, data.Format())
-		}(&datum, &formattedMangas.Mangas, &wg)
+			formattedMangas.Mangas[i] = data.Data[i].Format(ctx)
+		}(i)
 	}
 	wg.Wait()
 	formattedMangas.NbMangas = data.Total
@@ -470,8 +452,9 @@ func (data *ApiManga) Format() MangasInBulk {
 //
 //	@Description: converts a Manga to a MangaUsefullData with all needed data.
 //	@receiver data
+//	@param ctx
 //	@return MangaUsefullData
-func (data *Manga) Format() MangaUsefullData {
+func (data *Manga) Format(ctx context.Context) MangaUsefullData {
 	
 	var feed ApiMangaFeed
 	var query = make(url.Values)
@@ -480,7 +463,7 @@ func (data *Manga) Format() MangaUsefullData {
 	query.Add("includes[]", "scanlation_group")
 	query.Add("limit", "1")
 	
-	err := feed.SendRequest("https://api.mangadex.org/", "manga/"+data.Id+"/feed", query)
+	err := feed.SendRequest(ctx, mangadex.APIURL+"/", "manga/"+data.Id+"/feed", query)
 	if err != nil {
 		log.Println("request error:", err)
 	}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"net/url"
 	"time"
 )
@@ -29,7 +30,7 @@ var MangaStatus = []string{
 //	It declares three common methods for these types.
 type ApiData interface {
 	SingleCacheData(id string, order string, offset int) SingleCacheData
-	SendRequest(baseURL string, endpoint string, query url.Values) error
+	SendRequest(ctx context.Context, baseURL string, endpoint string, query url.Values) error
 	CheckResponse() error
 }
 
@@ -146,6 +147,8 @@ type MangaUsefullData struct {
 	NbChapter              int
 	IsFavorite             bool
 	LastChapterRead        string
+	LastChapterReadNb      string
+	LastChapterReadOffset  int
 }
 
 // Manga is the common structure for Mangas used by MangaDex API.
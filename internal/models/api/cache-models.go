@@ -25,20 +25,60 @@ var Status = struct {
 	MangaStats:   "manga_stats",
 }
 
-// StatusCache is the data structure of the status.json cache file.
-type StatusCache struct {
-	LastUploaded bool     `json:"last_uploaded"`
-	Popular      bool     `json:"popular"`
-	Tags         bool     `json:"tags"`
-	Categories   []string `json:"categories"`
-	Mangas       []string `json:"mangas"`
-	MangaFeeds   []string `json:"manga_feeds"`
-	ChaptersScan []string `json:"chapters_scan"`
-	MangaStats   []string `json:"manga_stats"`
+// CacheKinds lists all kinds of data stored in the cache.
+var CacheKinds = []string{
+	Status.LastUploaded,
+	Status.Popular,
+	Status.Tags,
+	Status.Categories,
+	Status.Mangas,
+	Status.MangaFeeds,
+	Status.ChaptersScan,
+	Status.MangaStats,
 }
 
-// CacheData is the data structure for all data stored in the cache.
-type CacheData []SingleCacheData
+// CacheKey identifies a single record in a Cache.
+type CacheKey struct {
+	Kind   string
+	Id     string
+	Order  string
+	Offset int
+}
+
+// Cache is the storage of the data fetched from MangaDex API.
+type Cache interface {
+	// Get returns the record stored under the key, if any.
+	Get(key CacheKey) (SingleCacheData, bool)
+	// Set stores a record under the key, replacing the previous one.
+	Set(key CacheKey, datum SingleCacheData) error
+	// Delete removes the record stored under the key.
+	Delete(key CacheKey)
+	// Range calls f for every stored key until f returns false.
+	Range(f func(key CacheKey, updatedTime time.Time) bool)
+	// Clear removes all records.
+	Clear() error
+}
+
+// CacheStats counts the records of a kind stored in the Cache, according to
+// their freshness.
+type CacheStats struct {
+	Kind string
+	// Fresh records are younger than their TTL.
+	Fresh int
+	// Stale records are still served while being refreshed in the background.
+	Stale int
+	// Expired records are waiting for the next sweep.
+	Expired int
+}
+
+// UpstreamHealth is the result of a health check of MangaDex API.
+type UpstreamHealth struct {
+	URL       string
+	CheckTime time.Time
+	Latency   time.Duration
+	// Error is empty if MangaDex API answered.
+	Error string
+}
 
 // SingleCacheData is the data structure for every single data record stored in
 // the cache.
@@ -15,6 +15,30 @@ type Session struct {
 	Username       string    `json:"username"`
 	IpAddress      string    `json:"ip_address"`
 	ExpirationTime time.Time `json:"expiration_time"`
+	UserAgent      string    `json:"user_agent"`
+	CreatedAt      time.Time `json:"created_at"`
+	LastSeen       time.Time `json:"last_seen"`
+	// Rotated is set on the previous session ID after a rotation: it stays
+	// valid until the end of the grace window, without being extended.
+	Rotated bool `json:"rotated,omitempty"`
+	// RememberSelector is the Selector of the RememberToken of the device,
+	// if the user asked to be remembered.
+	RememberSelector string `json:"remember_selector,omitempty"`
+}
+
+// RememberToken is the persistent token of a "remember me" login. The
+// remember_me cookie holds its Selector and a secret validator, which is only
+// stored hashed.
+type RememberToken struct {
+	Selector       string    `json:"selector"`
+	ValidatorHash  string    `json:"validator_hash"`
+	UserID         int       `json:"user_id"`
+	ExpirationTime time.Time `json:"expiration_time"`
+	// PreviousValidatorHash is the hash of the validator replaced by the last
+	// rotation, at RotatedAt: it stays valid during the grace window, for the
+	// requests sent by the device at the same time.
+	PreviousValidatorHash string    `json:"previous_validator_hash,omitempty"`
+	RotatedAt             time.Time `json:"rotated_at,omitempty"`
 }
 
 // Credentials is the structure used to authenticate a user at login.
@@ -25,30 +49,114 @@ type Credentials struct {
 
 // User is the structure used to store all user related data.
 type User struct {
-	Id             int         `json:"id"`
-	CreationTime   time.Time   `json:"creation_time"`
-	LastConnection time.Time   `json:"last_connection"`
-	Username       string      `json:"username"`
-	Avatar         string      `json:"avatar,omitempty"`
-	HashedPwd      string      `json:"hash"`
-	Salt           string      `json:"salt"`
-	Email          string      `json:"email"`
-	MangaBanner    MangaUser   `json:"manga_banner"`
-	Favorites      []MangaUser `json:"favorites"`
+	Id             int            `json:"id"`
+	CreationTime   time.Time      `json:"creation_time"`
+	LastConnection time.Time      `json:"last_connection"`
+	Username       string         `json:"username"`
+	Avatar         string         `json:"avatar,omitempty"`
+	HashedPwd      string         `json:"hash"`
+	Salt           string         `json:"salt"`
+	Email          string         `json:"email"`
+	MangaBanner    MangaUser      `json:"manga_banner"`
+	Favorites      []MangaUser    `json:"favorites"`
+	Readings       []MangaUser    `json:"readings,omitempty"`
+	History        []HistoryEntry `json:"history,omitempty"`
+	TwoFactor      *TwoFactor     `json:"two_factor,omitempty"`
+	// Role is one of RoleUser (when empty), RoleModerator or RoleAdmin.
+	Role string `json:"role,omitempty"`
+	// Disabled users can't log in anymore.
+	Disabled bool `json:"disabled,omitempty"`
+	// ResetRequired users can't log in until they set a new password
+	// through the link sent by mail.
+	ResetRequired bool `json:"reset_required,omitempty"`
+	// Identities are the OpenID Connect accounts linked to the User.
+	Identities []Identity `json:"identities,omitempty"`
+	// Passkeys are the WebAuthn credentials registered by the User to log in
+	// without password.
+	Passkeys []Passkey `json:"passkeys,omitempty"`
+}
+
+// Identity is an OpenID Connect account, identified by the issuer of its
+// provider and its subject there.
+type Identity struct {
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+}
+
+// Passkey is a WebAuthn credential of a User, registered from one of their
+// authenticators.
+type Passkey struct {
+	ID []byte `json:"id"`
+	// PublicKey is the CBOR encoded COSE_Key of the credential.
+	PublicKey []byte `json:"public_key"`
+	// SignCount is the last signature counter sent by the authenticator.
+	SignCount  uint32    `json:"sign_count"`
+	Transports []string  `json:"transports,omitempty"`
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsed   time.Time `json:"last_used,omitempty"`
+}
+
+// The roles of the User, from the least to the most privileged.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// TwoFactor is the TOTP two-factor authentication setting of a User (nil if
+// it is off).
+type TwoFactor struct {
+	// Secret is the base32 encoded TOTP secret.
+	Secret string `json:"secret"`
+	// LastStep is the time step of the last TOTP code used, so that a code
+	// can't be used twice.
+	LastStep int64 `json:"last_step"`
+	// RecoveryCodes are the hashes of the unused recovery codes.
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// UserExport is the archive of all the data stored about a User, which they
+// can download from their profile. Their credentials are left out.
+type UserExport struct {
+	ExportTime       time.Time `json:"export_time"`
+	User             User      `json:"user"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	Sessions         []Session `json:"sessions"`
 }
 
 // MangaUser is the structure used for all user related mangas.
+// The LastChapter* fields and LastReadTime store the user's reading progress.
 type MangaUser struct {
-	Id              string `json:"id,omitempty"`
-	LastChapterRead string `json:"last_chapter_read,omitempty"`
+	Id                string    `json:"id,omitempty"`
+	LastChapterRead   string    `json:"last_chapter_read,omitempty"`
+	LastChapterNb     string    `json:"last_chapter_nb,omitempty"`
+	LastChapterOffset int       `json:"last_chapter_offset,omitempty"`
+	LastReadTime      time.Time `json:"last_read_time,omitempty"`
+}
+
+// HistoryEntry is the structure used for every entry of a user's reading history.
+type HistoryEntry struct {
+	Id        string    `json:"id"`
+	MangaId   string    `json:"manga_id"`
+	ChapterId string    `json:"chapter_id"`
+	ChapterNb string    `json:"chapter_nb,omitempty"`
+	Offset    int       `json:"offset"`
+	Page      int       `json:"page"`
+	Time      time.Time `json:"time"`
 }
 
 // TempUser is the structure for any temporary user (waiting to be confirmed or
-// which password has been forgotten).
+// which password has been forgotten). Its ConfirmID is only sent by mail: the
+// pending TempUser are stored with its hash.
 type TempUser struct {
-	ConfirmID    string
-	CreationTime time.Time
-	User         User
+	ConfirmID      string
+	CreationTime   time.Time
+	ExpirationTime time.Time
+	User           User
+	// Browser is the hash of the magic_link cookie of the browser which asked
+	// for a magic link (the only one allowed to use it).
+	Browser string `json:",omitempty"`
 }
 
 // MailConfig is the structure used to retrieve the sending mail's configuration.
@@ -58,3 +166,25 @@ type MailConfig struct {
 	Hostname string `json:"host"`
 	Port     int    `json:"port"`
 }
+
+// JSONResponse is the envelope of every response of the JSON API (/api/v1):
+// Data is set when the request succeeded, and Error when it failed.
+type JSONResponse struct {
+	Data       any             `json:"data,omitempty"`
+	Pagination *JSONPagination `json:"pagination,omitempty"`
+	Error      *JSONError      `json:"error,omitempty"`
+}
+
+// JSONPagination describes the page sent in a paginated JSONResponse.
+type JSONPagination struct {
+	Page       int `json:"page"`
+	TotalPages int `json:"total_pages"`
+	Total      int `json:"total"`
+}
+
+// JSONError is the error sent in a JSONResponse.
+type JSONError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
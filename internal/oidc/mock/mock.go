@@ -0,0 +1,415 @@
+// Package mock is a fake OpenID Connect provider: it serves the discovery
+// document, an authorization endpoint where any identity can be typed in, the
+// token, userinfo and keys endpoints, so that the OpenID Connect login can be
+// tried without a real provider.
+//
+// It checks the requests like a real provider would (client credentials,
+// redirect URI, PKCE code verifier, single-use codes) and signs the ID tokens
+// with an RSA key generated at startup.
+package mock
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// codeTime is the lifetime of an authorization code.
+	codeTime = time.Minute
+	// tokenTime is the lifetime of the ID and access tokens.
+	tokenTime = time.Hour
+	// keyId is the key id of the signing key.
+	keyId = "mock-key"
+)
+
+// identity is a user of the mock, as typed in the authorization form.
+type identity struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+}
+
+// grant is an authorization code waiting to be exchanged.
+type grant struct {
+	identity       identity
+	redirectURI    string
+	nonce          string
+	challenge      string
+	expirationTime time.Time
+}
+
+// Server is the fake OpenID Connect provider, with a single client.
+type Server struct {
+	mux          *http.ServeMux
+	issuer       string
+	clientID     string
+	clientSecret string
+	key          *rsa.PrivateKey
+	
+	mutex  sync.Mutex
+	grants map[string]grant
+	// accessTokens holds the identity of each access token.
+	accessTokens map[string]identity
+}
+
+// New
+//
+//	@Description: creates a Server which issuer identifier is `issuer` (its
+//	own URL), for the client `clientID` authenticated by `clientSecret`.
+//	@param issuer
+//	@param clientID
+//	@param clientSecret
+//	@return *Server
+//	@return error: if the signing key can't be generated.
+func New(issuer, clientID, clientSecret string) (*Server, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		mux:          http.NewServeMux(),
+		issuer:       issuer,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		key:          key,
+		grants:       make(map[string]grant),
+		accessTokens: make(map[string]identity),
+	}
+	
+	s.mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	s.mux.HandleFunc("GET /authorize", s.authorizeForm)
+	s.mux.HandleFunc("POST /authorize", s.authorize)
+	s.mux.HandleFunc("POST /token", s.token)
+	s.mux.HandleFunc("GET /userinfo", s.userinfo)
+	s.mux.HandleFunc("GET /jwks", s.jwks)
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// writeJSON
+//
+//	@Description: sends `v` as a JSON response with the `status` code.
+//	@param w
+//	@param status
+//	@param v
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError
+//
+//	@Description: sends an OAuth 2.0 error response.
+//	@param w
+//	@param status
+//	@param code
+//	@param description
+func writeError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// randomString
+//
+//	@Description: generates a random string (base64url encoded), used for the
+//	codes and the access tokens.
+//	@return string
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// discovery sends the discovery document.
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"userinfo_endpoint":                     s.issuer + "/userinfo",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+// authorizeTemplate is the form of the authorization endpoint, in which the
+// identity of the user is typed in.
+var authorizeTemplate = template.Must(template.New("authorize").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="UTF-8"><title>Mock identity provider</title></head>
+<body>
+<h1>Mock identity provider</h1>
+<p>Sign in to <b>{{.client_id}}</b> as:</p>
+<form method="post" action="/authorize">
+    {{range $key, $value := .}}<input type="hidden" name="{{$key}}" value="{{$value}}">
+    {{end}}<p><label>Subject <input name="sub" required></label></p>
+    <p><label>Email <input name="email" type="email"></label></p>
+    <p><label><input name="email_verified" type="checkbox" value="true" checked> Email verified</label></p>
+    <p><label>Username <input name="preferred_username"></label></p>
+    <p><label>Name <input name="name"></label></p>
+    <button type="submit">Sign in</button>
+</form>
+</body>
+</html>`))
+
+// checkAuthorizeRequest
+//
+//	@Description: checks the params of an authorization request.
+//	@receiver s
+//	@param params
+//	@return string: the error description, empty if the request is valid.
+func (s *Server) checkAuthorizeRequest(params url.Values) string {
+	switch {
+	case params.Get("client_id") != s.clientID:
+		return "unknown client_id"
+	case params.Get("redirect_uri") == "":
+		return "missing redirect_uri"
+	case params.Get("response_type") != "code":
+		return "unsupported response_type"
+	case params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "":
+		return "PKCE with S256 is required"
+	}
+	return ""
+}
+
+// authorizeForm displays the authorization form.
+func (s *Server) authorizeForm(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	if description := s.checkAuthorizeRequest(params); description != "" {
+		http.Error(w, description, http.StatusBadRequest)
+		return
+	}
+	values := make(map[string]string)
+	for _, key := range []string{"client_id", "redirect_uri", "response_type", "scope", "state", "nonce", "code_challenge", "code_challenge_method"} {
+		values[key] = params.Get(key)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	authorizeTemplate.Execute(w, values)
+}
+
+// authorize issues an authorization code for the identity typed in the form
+// and redirects the user back to the client.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if description := s.checkAuthorizeRequest(r.PostForm); description != "" {
+		http.Error(w, description, http.StatusBadRequest)
+		return
+	}
+	if r.PostFormValue("sub") == "" {
+		http.Error(w, "missing subject", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	s.mutex.Lock()
+	s.grants[code] = grant{
+		identity: identity{
+			Subject:           r.PostFormValue("sub"),
+			Email:             r.PostFormValue("email"),
+			EmailVerified:     r.PostFormValue("email_verified") == "true",
+			PreferredUsername: r.PostFormValue("preferred_username"),
+			Name:              r.PostFormValue("name"),
+		},
+		redirectURI:    r.PostFormValue("redirect_uri"),
+		nonce:          r.PostFormValue("nonce"),
+		challenge:      r.PostFormValue("code_challenge"),
+		expirationTime: time.Now().Add(codeTime),
+	}
+	s.mutex.Unlock()
+	
+	redirect, err := url.Parse(r.PostFormValue("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", r.PostFormValue("state"))
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusSeeOther)
+}
+
+// token exchanges an authorization code for the tokens.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	} else {
+		clientID, clientSecret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+	if clientID != s.clientID || subtle.ConstantTimeCompare([]byte(clientSecret), []byte(s.clientSecret)) != 1 {
+		writeError(w, http.StatusUnauthorized, "invalid_client", "wrong client credentials")
+		return
+	}
+	if r.PostFormValue("grant_type") != "authorization_code" {
+		writeError(w, http.StatusBadRequest, "unsupported_grant_type", "only authorization_code is supported")
+		return
+	}
+	
+	s.mutex.Lock()
+	code, ok := s.grants[r.PostFormValue("code")]
+	// the codes are single-use
+	delete(s.grants, r.PostFormValue("code"))
+	s.mutex.Unlock()
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	switch {
+	case !ok || code.expirationTime.Before(time.Now()):
+		writeError(w, http.StatusBadRequest, "invalid_grant", "unknown or expired code")
+		return
+	case code.redirectURI != r.PostFormValue("redirect_uri"):
+		writeError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri mismatch")
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != code.challenge:
+		writeError(w, http.StatusBadRequest, "invalid_grant", "wrong code_verifier")
+		return
+	}
+	
+	idToken, err := s.sign(code.identity, code.nonce)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	accessToken := randomString()
+	s.mutex.Lock()
+	s.accessTokens[accessToken] = code.identity
+	s.mutex.Unlock()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenTime.Seconds()),
+		"id_token":     idToken,
+	})
+}
+
+// sign
+//
+//	@Description: creates the RS256 ID token of the `user`.
+//	@receiver s
+//	@param user
+//	@param nonce
+//	@return string
+//	@return error
+func (s *Server) sign(user identity, nonce string) (string, error) {
+	now := time.Now()
+	return s.signJWT(map[string]any{"alg": "RS256", "typ": "JWT", "kid": keyId}, struct {
+		identity
+		Issuer   string `json:"iss"`
+		Audience string `json:"aud"`
+		Expiry   int64  `json:"exp"`
+		IssuedAt int64  `json:"iat"`
+		Nonce    string `json:"nonce,omitempty"`
+	}{
+		identity: user,
+		Issuer:   s.issuer,
+		Audience: s.clientID,
+		Expiry:   now.Add(tokenTime).Unix(),
+		IssuedAt: now.Unix(),
+		Nonce:    nonce,
+	})
+}
+
+// SignToken
+//
+//	@Description: signs any `claims` with the Server's key, to check how the
+//	clients handle the invalid or forged ID tokens. The header is the one of
+//	the Server's ID tokens, with the `header` entries added or replaced (a
+//	nil value removes the entry), whatever the algorithm they name: the
+//	signature is always RS256.
+//	@receiver s
+//	@param claims
+//	@param header
+//	@return string
+//	@return error
+func (s *Server) SignToken(claims map[string]any, header map[string]any) (string, error) {
+	h := map[string]any{"alg": "RS256", "typ": "JWT", "kid": keyId}
+	for key, value := range header {
+		if value == nil {
+			delete(h, key)
+			continue
+		}
+		h[key] = value
+	}
+	return s.signJWT(h, claims)
+}
+
+// signJWT
+//
+//	@Description: encodes the `header` and the `claims` as a JWT signed with
+//	RS256.
+//	@receiver s
+//	@param header
+//	@param claims
+//	@return string
+//	@return error
+func (s *Server) signJWT(header any, claims any) (string, error) {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// userinfo sends the identity of the access token's user.
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	accessToken, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mutex.Lock()
+	user, ok := s.accessTokens[accessToken]
+	s.mutex.Unlock()
+	if !bearer || !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "invalid_token", "unknown access token")
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// jwks sends the public signing key.
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyId,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(s.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+		}},
+	})
+}
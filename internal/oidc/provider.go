@@ -0,0 +1,302 @@
+// Package oidc is a minimal OpenID Connect relying party: it discovers the
+// provider's endpoints, builds the authorization requests of the
+// authorization code flow with PKCE, exchanges the codes for tokens and
+// verifies the ID tokens against the provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// requestTimeout is the deadline of every request sent to the provider.
+	requestTimeout = time.Second * 10
+	// metadataTime is the time the discovery document is kept before being
+	// fetched again.
+	metadataTime = time.Hour * 24
+	// maxResponseSize bounds the size of the provider's responses.
+	maxResponseSize = 1 << 20
+)
+
+var (
+	ErrDiscovery = errors.New("invalid provider configuration")
+	ErrExchange  = errors.New("code exchange failed")
+	ErrUserInfo  = errors.New("userinfo request failed")
+)
+
+// metadata is the part of the provider's discovery document used by the
+// Provider (OpenID Connect Discovery 1.0).
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Provider is an OpenID Connect provider, on which the website is registered
+// as a confidential client. Its endpoints and keys are fetched on first use.
+type Provider struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scopes are requested along with the openid scope.
+	Scopes []string
+	HTTP   *http.Client
+	
+	mutex       sync.Mutex
+	meta        *metadata
+	metaFetched time.Time
+	keys        keySet
+}
+
+// NewProvider
+//
+//	@Description: returns the Provider which issuer identifier is `issuer`.
+//	@param issuer
+//	@param clientID
+//	@param clientSecret
+//	@param redirectURL: the URL of the callback handler, registered on the provider.
+//	@return *Provider
+func NewProvider(issuer, clientID, clientSecret, redirectURL string) *Provider {
+	return &Provider{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+		Scopes:       []string{"email", "profile"},
+		HTTP:         &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// metadata
+//
+//	@Description: returns the provider's discovery document, fetching it when
+//	it is missing or outdated. The document must be the one of the
+//	configured issuer.
+//	@receiver p
+//	@param ctx
+//	@return *metadata
+//	@return error
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	
+	if p.meta != nil && time.Since(p.metaFetched) < metadataTime {
+		return p.meta, nil
+	}
+	var meta metadata
+	err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", "", &meta)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+	}
+	switch {
+	case strings.TrimRight(meta.Issuer, "/") != p.Issuer:
+		return nil, fmt.Errorf("%w: issuer %q doesn't match %q", ErrDiscovery, meta.Issuer, p.Issuer)
+	case meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "":
+		return nil, fmt.Errorf("%w: missing endpoints", ErrDiscovery)
+	}
+	p.meta = &meta
+	p.metaFetched = time.Now()
+	return p.meta, nil
+}
+
+// AuthCodeURL
+//
+//	@Description: returns the URL of the provider's authorization endpoint to
+//	redirect the user to.
+//	@receiver p
+//	@param ctx
+//	@param state: bound to the user's browser, sent back to the callback.
+//	@param nonce: bound to the user's browser, sent back in the ID token.
+//	@param verifier: the PKCE code verifier, which S256 challenge is sent.
+//	@return string
+//	@return error
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.ClientID)
+	query.Set("redirect_uri", p.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, p.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Tokens are the tokens sent by the provider's token endpoint.
+type Tokens struct {
+	IDToken     string `json:"id_token"`
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+}
+
+// Exchange
+//
+//	@Description: exchanges the authorization `code` sent to the callback for
+//	the user's Tokens. The client authenticates with client_secret_basic,
+//	unless the provider only supports client_secret_post.
+//	@receiver p
+//	@param ctx
+//	@param code
+//	@param verifier: the PKCE code verifier of the authorization request.
+//	@return Tokens
+//	@return error
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (Tokens, error) {
+	var tokens Tokens
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return tokens, err
+	}
+	
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("code_verifier", verifier)
+	basic := len(meta.TokenAuthMethods) == 0 || slices.Contains(meta.TokenAuthMethods, "client_secret_basic")
+	if !basic {
+		form.Set("client_id", p.ClientID)
+		form.Set("client_secret", p.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return tokens, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if basic {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	
+	res, err := p.HTTP.Do(req)
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize))
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}
+		json.Unmarshal(body, &oauthErr)
+		return tokens, fmt.Errorf("%w: %s %s %s", ErrExchange, res.Status, oauthErr.Error, oauthErr.Description)
+	}
+	err = json.Unmarshal(body, &tokens)
+	if err != nil {
+		return tokens, fmt.Errorf("%w: %w", ErrExchange, err)
+	}
+	if tokens.IDToken == "" {
+		return tokens, fmt.Errorf("%w: no id_token", ErrExchange)
+	}
+	return tokens, nil
+}
+
+// UserInfo
+//
+//	@Description: fetches the user's Claims from the provider's userinfo
+//	endpoint, for the providers not putting them in the ID token.
+//	@receiver p
+//	@param ctx
+//	@param accessToken
+//	@return Claims
+//	@return error
+func (p *Provider) UserInfo(ctx context.Context, accessToken string) (Claims, error) {
+	var claims Claims
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return claims, err
+	}
+	if meta.UserinfoEndpoint == "" {
+		return claims, fmt.Errorf("%w: no userinfo endpoint", ErrUserInfo)
+	}
+	err = p.getJSON(ctx, meta.UserinfoEndpoint, accessToken, &claims)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrUserInfo, err)
+	}
+	return claims, nil
+}
+
+// getJSON
+//
+//	@Description: sends a GET request to the `rawURL` and decodes its JSON
+//	response into `v`.
+//	@receiver p
+//	@param ctx
+//	@param rawURL
+//	@param accessToken: sent as a bearer token, if any.
+//	@param v
+//	@return error
+func (p *Provider) getJSON(ctx context.Context, rawURL, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	res, err := p.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", rawURL, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, maxResponseSize)).Decode(v)
+}
+
+// RandomString
+//
+//	@Description: generates a random string of 32 bytes (base64url encoded),
+//	used for the states, nonces and PKCE code verifiers.
+//	@return string
+//	@return error
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// codeChallenge
+//
+//	@Description: returns the S256 PKCE code challenge of the `verifier`.
+//	@param verifier
+//	@return string
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
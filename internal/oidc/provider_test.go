@@ -0,0 +1,288 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/oidc/mock"
+)
+
+const (
+	testClientID     = "mangathorg"
+	testClientSecret = "mock-secret"
+	testRedirectURL  = "http://localhost/login/oidc/callback"
+)
+
+// newTestProvider
+//
+//	@Description: starts a mock provider and returns the Provider of its client.
+//	@param t
+//	@return *Provider
+//	@return *mock.Server
+func newTestProvider(t *testing.T) (*Provider, *mock.Server) {
+	var fake *mock.Server
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	fake, err := mock.New(server.URL, testClientID, testClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewProvider(server.URL, testClientID, testClientSecret, testRedirectURL), fake
+}
+
+// authorize
+//
+//	@Description: signs in on the mock provider as the user `sub` with the
+//	authorization request of the `authURL`, whose params are replaced by the
+//	`override` ones, and returns the params sent back to the callback.
+//	@param t
+//	@param authURL
+//	@param sub
+//	@param override
+//	@return url.Values
+func authorize(t *testing.T, authURL string, sub string, override url.Values) url.Values {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := u.Query()
+	for key, values := range override {
+		form[key] = values
+	}
+	form.Set("sub", sub)
+	form.Set("email", sub+"@example.com")
+	form.Set("email_verified", "true")
+	
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.PostForm(u.Scheme+"://"+u.Host+u.Path, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("authorization: status %d", res.StatusCode)
+	}
+	location, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return location.Query()
+}
+
+// validClaims returns the claims of a valid ID token of the mock for the
+// `nonce`.
+func validClaims(p *Provider, nonce string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   p.Issuer,
+		"sub":   "alice",
+		"aud":   testClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": nonce,
+	}
+}
+
+func TestProviderLogin(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+	
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := authorize(t, authURL, "alice", nil)
+	if callback.Get("state") != "state" {
+		t.Fatalf("state %q sent back", callback.Get("state"))
+	}
+	tokens, err := p.Exchange(ctx, callback.Get("code"), "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := p.Verify(ctx, tokens.IDToken, "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.com" || !bool(claims.EmailVerified) {
+		t.Fatalf("wrong claims %+v", claims)
+	}
+	
+	// the codes are single-use
+	_, err = p.Exchange(ctx, callback.Get("code"), "verifier")
+	if !errors.Is(err, ErrExchange) {
+		t.Fatalf("code used twice: %v", err)
+	}
+}
+
+func TestProviderPKCE(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+	
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(authURL, "code_challenge="+codeChallenge("verifier")) || !strings.Contains(authURL, "code_challenge_method=S256") {
+		t.Fatalf("no S256 challenge in %s", authURL)
+	}
+	
+	callback := authorize(t, authURL, "alice", nil)
+	_, err = p.Exchange(ctx, callback.Get("code"), "another verifier")
+	if !errors.Is(err, ErrExchange) {
+		t.Fatalf("code exchanged with the wrong verifier: %v", err)
+	}
+	
+	// an attacker's code, requested with its own challenge
+	callback = authorize(t, authURL, "mallory", url.Values{"code_challenge": {codeChallenge("attacker")}})
+	_, err = p.Exchange(ctx, callback.Get("code"), "verifier")
+	if !errors.Is(err, ErrExchange) {
+		t.Fatalf("injected code exchanged: %v", err)
+	}
+}
+
+func TestProviderVerifyNonce(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+	
+	authURL, err := p.AuthCodeURL(ctx, "state", "nonce", "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	callback := authorize(t, authURL, "alice", url.Values{"nonce": {"replayed"}})
+	tokens, err := p.Exchange(ctx, callback.Get("code"), "verifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.Verify(ctx, tokens.IDToken, "nonce")
+	if !errors.Is(err, ErrIDToken) {
+		t.Fatalf("ID token with the wrong nonce accepted: %v", err)
+	}
+}
+
+func TestProviderVerifyClaims(t *testing.T) {
+	p, fake := newTestProvider(t)
+	ctx := context.Background()
+	
+	tests := []struct {
+		name     string
+		claims   map[string]any
+		accepted bool
+	}{
+		{"wrong audience", map[string]any{"aud": "another-client"}, false},
+		{"one of the audiences", map[string]any{"aud": []string{"another-client", testClientID}}, true},
+		{"wrong authorized party", map[string]any{"aud": []string{"another-client", testClientID}, "azp": "another-client"}, false},
+		{"wrong issuer", map[string]any{"iss": "https://evil.example.com"}, false},
+		{"expired", map[string]any{"exp": time.Now().Add(-time.Hour).Unix()}, false},
+		{"expired within the clock skew", map[string]any{"exp": time.Now().Add(-clockSkew / 2).Unix()}, true},
+		{"issued in the future", map[string]any{"iat": time.Now().Add(time.Hour).Unix()}, false},
+		{"no subject", map[string]any{"sub": ""}, false},
+		{"no nonce", map[string]any{"nonce": nil}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims := validClaims(p, "nonce")
+			for key, value := range test.claims {
+				if value == nil {
+					delete(claims, key)
+					continue
+				}
+				claims[key] = value
+			}
+			token, err := fake.SignToken(claims, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = p.Verify(ctx, token, "nonce")
+			if test.accepted && err != nil {
+				t.Fatal(err)
+			}
+			if !test.accepted && !errors.Is(err, ErrIDToken) {
+				t.Fatalf("ID token accepted: %v", err)
+			}
+		})
+	}
+}
+
+func TestProviderVerifySignature(t *testing.T) {
+	p, fake := newTestProvider(t)
+	_, other := newTestProvider(t)
+	ctx := context.Background()
+	
+	valid, err := fake.SignToken(validClaims(p, "nonce"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.Verify(ctx, valid, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+	
+	parts := strings.Split(valid, ".")
+	tampered, err := fake.SignToken(map[string]any{"sub": "mallory"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the claims of another token, under the signature of the valid one
+	tampered = parts[0] + "." + strings.Split(tampered, ".")[1] + "." + parts[2]
+	
+	// a token of another provider, with the same key id
+	foreign, err := other.SignToken(validClaims(p, "nonce"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownKid, err := fake.SignToken(validClaims(p, "nonce"), map[string]any{"kid": "another-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noKid, err := fake.SignToken(validClaims(p, "nonce"), map[string]any{"kid": nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// alg confusion: the RS256 signature presented as another algorithm
+	es256, err := fake.SignToken(validClaims(p, "nonce"), map[string]any{"alg": "ES256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs256, err := fake.SignToken(validClaims(p, "nonce"), map[string]any{"alg": "HS256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, err := fake.SignToken(validClaims(p, "nonce"), map[string]any{"alg": "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := strings.Join(strings.Split(none, ".")[:2], ".") + "."
+	
+	tests := map[string]string{
+		"tampered claims":   tampered,
+		"another provider":  foreign,
+		"unknown key id":    unknownKid,
+		"ES256 header":      es256,
+		"HS256 header":      hs256,
+		"none algorithm":    unsigned,
+		"malformed":         parts[0] + "." + parts[1],
+		"invalid signature": parts[0] + "." + parts[1] + ".!!",
+	}
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.Verify(ctx, token, "nonce")
+			if !errors.Is(err, ErrIDToken) {
+				t.Fatalf("ID token accepted: %v", err)
+			}
+		})
+	}
+	
+	// without key id, all the provider's keys are tried
+	if _, err = p.Verify(ctx, noKid, "nonce"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,344 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// clockSkew is the difference accepted between the provider's clock and
+	// ours when checking the ID tokens' times.
+	clockSkew = time.Minute
+	// keysRefreshTime is the minimum time between two fetches of the
+	// provider's keys, when an ID token is signed with an unknown key.
+	keysRefreshTime = time.Minute
+)
+
+var ErrIDToken = errors.New("invalid ID token")
+
+// Claims are the claims of an ID token or of a userinfo response used by the
+// website.
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	Expiry            float64  `json:"exp"`
+	IssuedAt          float64  `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     flexBool `json:"email_verified"`
+	PreferredUsername string   `json:"preferred_username"`
+	Name              string   `json:"name"`
+}
+
+// audience is the aud claim, which is either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	err := json.Unmarshal(data, &multiple)
+	*a = multiple
+	return err
+}
+
+// flexBool is a boolean claim, which some providers send as a string.
+type flexBool bool
+
+func (b *flexBool) UnmarshalJSON(data []byte) error {
+	var value bool
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		var text string
+		if json.Unmarshal(data, &text) != nil {
+			return err
+		}
+		value = text == "true"
+	}
+	*b = flexBool(value)
+	return nil
+}
+
+// curveAlgorithms are the signing algorithms matching the elliptic curves.
+var curveAlgorithms = map[string]string{
+	"P-256": "ES256",
+	"P-384": "ES384",
+	"P-521": "ES512",
+}
+
+// keySet holds the provider's public keys by key id.
+type keySet struct {
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// jwk is a JSON Web Key of the provider's key set (RFC 7517).
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// algorithms are the supported ID tokens' signing algorithms, with their hash.
+var algorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// Verify
+//
+//	@Description: checks the ID token's signature with the provider's keys and
+//	its claims: the issuer, the audience, the times and the `nonce` sent in
+//	the authorization request.
+//	@receiver p
+//	@param ctx
+//	@param rawIDToken
+//	@param nonce
+//	@return Claims
+//	@return error: ErrIDToken if the ID token is rejected.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	var claims Claims
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return claims, err
+	}
+	
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return claims, fmt.Errorf("%w: malformed", ErrIDToken)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	err = decodeSegment(parts[0], &header)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrIDToken, err)
+	}
+	hash, ok := algorithms[header.Alg]
+	if !ok {
+		return claims, fmt.Errorf("%w: unsupported algorithm %q", ErrIDToken, header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrIDToken, err)
+	}
+	h := hash.New()
+	h.Write([]byte(parts[0] + "." + parts[1]))
+	digest := h.Sum(nil)
+	
+	keys, err := p.publicKeys(ctx, meta, header.Kid)
+	if err != nil {
+		return claims, err
+	}
+	if !slices.ContainsFunc(keys, func(key crypto.PublicKey) bool {
+		return verifySignature(key, header.Alg, hash, digest, signature)
+	}) {
+		return claims, fmt.Errorf("%w: wrong signature", ErrIDToken)
+	}
+	
+	err = decodeSegment(parts[1], &claims)
+	if err != nil {
+		return claims, fmt.Errorf("%w: %w", ErrIDToken, err)
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != meta.Issuer:
+		return claims, fmt.Errorf("%w: issuer %q", ErrIDToken, claims.Issuer)
+	case claims.Subject == "":
+		return claims, fmt.Errorf("%w: no subject", ErrIDToken)
+	case !slices.Contains(claims.Audience, p.ClientID):
+		return claims, fmt.Errorf("%w: audience %q", ErrIDToken, claims.Audience)
+	case claims.AuthorizedParty != "" && claims.AuthorizedParty != p.ClientID:
+		return claims, fmt.Errorf("%w: authorized party %q", ErrIDToken, claims.AuthorizedParty)
+	case now.After(unixTime(claims.Expiry).Add(clockSkew)):
+		return claims, fmt.Errorf("%w: expired", ErrIDToken)
+	case unixTime(claims.IssuedAt).After(now.Add(clockSkew)):
+		return claims, fmt.Errorf("%w: issued in the future", ErrIDToken)
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1:
+		return claims, fmt.Errorf("%w: wrong nonce", ErrIDToken)
+	}
+	return claims, nil
+}
+
+// publicKeys
+//
+//	@Description: returns the provider's keys which may have signed a token
+//	with the `kid` key id (all of them if it is empty). The key set is fetched
+//	again when the key is unknown, as the provider may have rotated its keys.
+//	@receiver p
+//	@param ctx
+//	@param meta
+//	@param kid
+//	@return []crypto.PublicKey
+//	@return error
+func (p *Provider) publicKeys(ctx context.Context, meta *metadata, kid string) ([]crypto.PublicKey, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	
+	_, known := p.keys.keys[kid]
+	if (kid == "" && len(p.keys.keys) == 0) || (kid != "" && !known && time.Since(p.keys.fetched) > keysRefreshTime) {
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		err := p.getJSON(ctx, meta.JWKSURI, "", &set)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDiscovery, err)
+		}
+		p.keys = keySet{keys: make(map[string]crypto.PublicKey), fetched: time.Now()}
+		for _, key := range set.Keys {
+			if key.Use != "" && key.Use != "sig" {
+				continue
+			}
+			if publicKey, err := key.publicKey(); err == nil {
+				p.keys.keys[key.Kid] = publicKey
+			}
+		}
+	}
+	
+	if kid != "" {
+		key, ok := p.keys.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown key %q", ErrIDToken, kid)
+		}
+		return []crypto.PublicKey{key}, nil
+	}
+	var keys []crypto.PublicKey
+	for _, key := range p.keys.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// publicKey
+//
+//	@Description: decodes the RSA or elliptic curve public key of the jwk.
+//	@receiver key
+//	@return crypto.PublicKey
+//	@return error
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch key.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", key.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("invalid EC point")
+		}
+		// checking that the point is on the curve
+		_, err = ecdhCurve.NewPublicKey(append(append([]byte{4}, x...), y...))
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", key.Kty)
+}
+
+// verifySignature
+//
+//	@Description: checks the `signature` of the `digest` with the `key`,
+//	according to the `alg` signing algorithm.
+//	@param key
+//	@param alg
+//	@param hash
+//	@param digest
+//	@param signature
+//	@return bool
+func verifySignature(key crypto.PublicKey, alg string, hash crypto.Hash, digest, signature []byte) bool {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if curveAlgorithms[key.Curve.Params().Name] != alg || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// decodeSegment
+//
+//	@Description: decodes the base64url JSON segment of a JWT into `v`.
+//	@param segment
+//	@param v
+//	@return error
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// unixTime
+//
+//	@Description: converts a NumericDate claim to a time.Time.
+//	@param seconds
+//	@return time.Time
+func unixTime(seconds float64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// ExportUser
+//
+//	@Description: gathers all the data stored about the `user`: its models.User
+//	record (favorites, banner, reading progress and history) and its active
+//	models.Session, without its credentials.
+//	@param user
+//	@return server.UserExport
+func ExportUser(user server.User) server.UserExport {
+	export := server.UserExport{
+		ExportTime:       time.Now(),
+		User:             user,
+		TwoFactorEnabled: user.TwoFactor != nil,
+		Sessions:         []server.Session{},
+	}
+	export.User.HashedPwd = ""
+	export.User.Salt = ""
+	export.User.TwoFactor = nil
+	
+	for _, session := range UserSessions(user.Id) {
+		session.RememberSelector = ""
+		export.Sessions = append(export.Sessions, session)
+	}
+	return export
+}
+
+// DeleteAccount
+//
+//	@Description: logs the client out and deletes its `user` account.
+//	@param w
+//	@param r
+//	@param user
+func DeleteAccount(w *http.ResponseWriter, r *http.Request, user server.User) {
+	Logout(w, r)
+	DeleteUser(user)
+}
+
+// DeleteUser
+//
+//	@Description: revokes all the `user`'s models.Session and
+//	models.RememberToken, cancels its pending password resets and email address
+//	change, and removes its models.User from the UserStore.
+//	@param user
+func DeleteUser(user server.User) {
+	RevokeUserSessions(user.Id)
+	_, err := pendingUsers.Exec("DELETE FROM pending_users WHERE kind != 'creation' AND username = ?", user.Username)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	
+	removeUser(user.Id)
+	Logger.Info("Account deleted", slog.Int("user_id", user.Id), slog.String("username", user.Username))
+}
@@ -0,0 +1,214 @@
+package utils
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// roleRanks are the ranks of the models.User roles: a role holds the rights
+// of all the roles ranked below it.
+var roleRanks = map[string]int{
+	server.RoleUser:      0,
+	server.RoleModerator: 1,
+	server.RoleAdmin:     2,
+}
+
+// ValidRole
+//
+//	@Description: checks if the `role` is one of the models.User roles.
+//	@param role
+//	@return bool
+func ValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// UserRole
+//
+//	@Description: returns the `user`'s role (server.RoleUser when it has none).
+//	@param user
+//	@return string
+func UserRole(user server.User) string {
+	if user.Role == "" {
+		return server.RoleUser
+	}
+	return user.Role
+}
+
+// HasRole
+//
+//	@Description: checks if the `user` holds the `role` or a higher one.
+//	@param user
+//	@param role
+//	@return bool
+func HasRole(user server.User, role string) bool {
+	rank, ok := roleRanks[role]
+	return ok && roleRanks[UserRole(user)] >= rank
+}
+
+// CanManage
+//
+//	@Description: checks if the `actor` may act on the `target`'s account from
+//	the admin console: the admins manage everyone, the moderators only manage
+//	the plain users, and nobody manages themselves there.
+//	@param actor
+//	@param target
+//	@return bool
+func CanManage(actor server.User, target server.User) bool {
+	if actor.Id == target.Id || !HasRole(actor, server.RoleModerator) {
+		return false
+	}
+	return HasRole(actor, server.RoleAdmin) || roleRanks[UserRole(actor)] > roleRanks[UserRole(target)]
+}
+
+// CanLogin
+//
+//	@Description: checks if the `user` is allowed to log in: its account must
+//	be enabled, and it must not have to set a new password.
+//	@param user
+//	@return bool
+func CanLogin(user server.User) bool {
+	return !user.Disabled && !user.ResetRequired
+}
+
+// SearchUsers
+//
+//	@Description: returns the models.User which username or email contains the
+//	`query` (all of them if it is empty), sorted by id.
+//	@param query
+//	@return []server.User
+func SearchUsers(query string) []server.User {
+	all, err := users.All()
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	var found []server.User
+	for _, user := range all {
+		if query == "" || strings.Contains(strings.ToLower(user.Username), query) || strings.Contains(strings.ToLower(user.Email), query) {
+			found = append(found, user)
+		}
+	}
+	slices.SortFunc(found, func(a, b server.User) int {
+		return a.Id - b.Id
+	})
+	return found
+}
+
+// RevokeUserSessions
+//
+//	@Description: logs out all the models.Session of the models.User which Id
+//	matches the `userID` param, and forgets all its devices.
+//	@param userID
+//	@return int: the number of revoked models.Session.
+func RevokeUserSessions(userID int) int {
+	revoked := revokeSessions(func(session server.Session) bool {
+		return session.UserID == userID
+	})
+	err := sessions.DeleteUserTokens(userID, "")
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	return revoked
+}
+
+// SetUserDisabled
+//
+//	@Description: disables the `user`'s account, logging out all its
+//	models.Session, or enables it again.
+//	@param user
+//	@param disabled
+func SetUserDisabled(user server.User, disabled bool) {
+	user.Disabled = disabled
+	UpdateUser(user)
+	if disabled {
+		RevokeUserSessions(user.Id)
+		Logger.Info("Account disabled", slog.Int("user_id", user.Id))
+	} else {
+		Logger.Info("Account enabled", slog.Int("user_id", user.Id))
+	}
+}
+
+// ForcePasswordReset
+//
+//	@Description: logs out all the `user`'s models.Session and prevents it from
+//	logging in until it sets a new password through the link sent by mail.
+//	@param user
+func ForcePasswordReset(user server.User) {
+	user.ResetRequired = true
+	UpdateUser(user)
+	RevokeUserSessions(user.Id)
+	
+	var temp = server.TempUser{
+		CreationTime: time.Now(),
+		User:         user,
+	}
+	SendMail(&temp, "lost")
+	AddTempUser(temp, "lost")
+	Logger.Info("Password reset forced", slog.Int("user_id", user.Id))
+}
+
+// SetUserRole
+//
+//	@Description: gives the `role` to the `user` and logs out all its
+//	models.Session, so that its new rights apply at once.
+//	@param user
+//	@param role
+//	@return bool: false if the `role` doesn't exist.
+func SetUserRole(user server.User, role string) bool {
+	if !ValidRole(role) {
+		return false
+	}
+	if role == server.RoleUser {
+		role = ""
+	}
+	user.Role = role
+	UpdateUser(user)
+	RevokeUserSessions(user.Id)
+	Logger.Info("Role changed", slog.Int("user_id", user.Id), slog.String("role", UserRole(user)))
+	return true
+}
+
+// HasAdmin
+//
+//	@Description: checks if a models.User has the admin role.
+//	@return bool
+//	@return error
+func HasAdmin() (bool, error) {
+	all, err := users.All()
+	if err != nil {
+		return false, err
+	}
+	for _, user := range all {
+		if user.Role == server.RoleAdmin {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PromoteAdmin
+//
+//	@Description: gives the admin role to the models.User which username matches
+//	the `username` param (used to bootstrap the first admin).
+//	@param username
+//	@return error
+func PromoteAdmin(username string) error {
+	user, ok, err := users.SelectByUsername(username)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUserNotFound, username)
+	}
+	if user.Role == server.RoleAdmin {
+		return nil
+	}
+	user.Role = server.RoleAdmin
+	return users.Update(user)
+}
@@ -1,23 +1,165 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	
+	"golang.org/x/crypto/argon2"
 	
 	"mangathorg/internal/models/server"
 )
 
-// pepper is a constant value used to hash passwords.
-var pepper = []byte{0x7, 0x4e, 0x6, 0xe8, 0xc5, 0xc4, 0xf3, 0xe, 0x6d, 0x4d, 0xe, 0x72, 0x8a, 0xbd, 0x85, 0x9c, 0xc8, 0xa9, 0xc7, 0xe3, 0x59, 0x4f, 0x97, 0xe2, 0xb, 0x85, 0x3e, 0x21, 0xad, 0xba, 0xe2, 0x17, 0x13, 0xb8, 0x3f, 0xd1, 0x52, 0x50, 0x6e, 0xa8, 0xd2, 0x8, 0xd3, 0x8a, 0x7f, 0x28, 0xc5, 0xc2, 0x3f, 0x64, 0x99, 0xb8, 0x23, 0x66, 0x11, 0xf0, 0xc4, 0x4, 0x59, 0x3, 0x2d, 0x45, 0x4e, 0xe7}
+// legacyPepper is the constant value used to hash the passwords before
+// Argon2id: it is only kept to check the legacy hashes, which are replaced at
+// the users' next login.
+var legacyPepper = []byte{0x7, 0x4e, 0x6, 0xe8, 0xc5, 0xc4, 0xf3, 0xe, 0x6d, 0x4d, 0xe, 0x72, 0x8a, 0xbd, 0x85, 0x9c, 0xc8, 0xa9, 0xc7, 0xe3, 0x59, 0x4f, 0x97, 0xe2, 0xb, 0x85, 0x3e, 0x21, 0xad, 0xba, 0xe2, 0x17, 0x13, 0xb8, 0x3f, 0xd1, 0x52, 0x50, 0x6e, 0xa8, 0xd2, 0x8, 0xd3, 0x8a, 0x7f, 0x28, 0xc5, 0xc2, 0x3f, 0x64, 0x99, 0xb8, 0x23, 0x66, 0x11, 0xf0, 0xc4, 0x4, 0x59, 0x3, 0x2d, 0x45, 0x4e, 0xe7}
+
+const (
+	// saltLength and keyLength are the sizes in bytes of the Argon2id salt and hash.
+	saltLength = 16
+	keyLength  = 32
+)
+
+// argon2Params are the Argon2id cost parameters.
+type argon2Params struct {
+	// Memory is the memory used in KiB.
+	Memory uint32
+	// Iterations is the number of passes over the memory.
+	Iterations uint32
+	// Parallelism is the number of threads used.
+	Parallelism uint8
+}
+
+// pepper is a secret value mixed to the passwords before hashing them,
+// identified by its Id in the stored hashes.
+type pepper struct {
+	Id     string
+	Secret []byte
+}
+
+// passwordParams are the Argon2id parameters used for new hashes, set with the
+// ARGON2_MEMORY (KiB), ARGON2_ITERATIONS and ARGON2_PARALLELISM environment
+// variables (64 MiB, 3 and 2 by default).
+var passwordParams = argon2Params{
+	Memory:      uint32(intFromEnv("ARGON2_MEMORY", 64*1024, 8, 4*1024*1024)),
+	Iterations:  uint32(intFromEnv("ARGON2_ITERATIONS", 3, 1, 100)),
+	Parallelism: uint8(intFromEnv("ARGON2_PARALLELISM", 2, 1, 255)),
+}
+
+// currentPepper is the pepper used for new hashes (PASSWORD_PEPPER environment
+// variable), and oldPeppers the previous ones, still accepted to check the
+// passwords until they are hashed again (PASSWORD_OLD_PEPPERS environment
+// variable, comma separated). They are written as `<id>:<base64 secret>`.
+var currentPepper, oldPeppers = loadPeppers()
+
+// pepperIdRegexp matches the valid pepper ids.
+var pepperIdRegexp = regexp.MustCompile(`^[a-zA-Z0-9.-]{1,32}$`)
+
+// intFromEnv
+//
+//	@Description: reads an integer from the environment variable `name`, or
+//	returns `fallback` if it is not set. An invalid value stops the program.
+//	@param name
+//	@param fallback
+//	@param minimum
+//	@param maximum
+//	@return int
+func intFromEnv(name string, fallback, minimum, maximum int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < minimum || n > maximum {
+		log.Fatalf("invalid %s: %q (expected an integer between %d and %d)", name, value, minimum, maximum)
+	}
+	return n
+}
+
+// parsePepper
+//
+//	@Description: parses a pepper written as `<id>:<base64 secret>`.
+//	@param value
+//	@return pepper
+//	@return error
+func parsePepper(value string) (pepper, error) {
+	id, secret, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok || !pepperIdRegexp.MatchString(id) {
+		return pepper{}, errors.New("a pepper must be written as <id>:<base64 secret>")
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil || len(key) < 16 {
+		return pepper{}, errors.New("the secret of pepper " + id + " must be at least 16 bytes encoded in base64")
+	}
+	return pepper{Id: id, Secret: key}, nil
+}
+
+// loadPeppers
+//
+//	@Description: reads the current and old peppers from the environment.
+//	Without PASSWORD_PEPPER, the passwords are hashed without pepper (only
+//	outside production, see InitUsers).
+//	@return *pepper
+//	@return map[string]pepper
+func loadPeppers() (*pepper, map[string]pepper) {
+	var current *pepper
+	old := make(map[string]pepper)
+	if value := os.Getenv("PASSWORD_PEPPER"); value != "" {
+		p, err := parsePepper(value)
+		if err != nil {
+			log.Fatalf("invalid PASSWORD_PEPPER: %s", err.Error())
+		}
+		current = &p
+	}
+	if value := os.Getenv("PASSWORD_OLD_PEPPERS"); value != "" {
+		for _, item := range strings.Split(value, ",") {
+			p, err := parsePepper(item)
+			if err != nil {
+				log.Fatalf("invalid PASSWORD_OLD_PEPPERS: %s", err.Error())
+			}
+			old[p.Id] = p
+		}
+	}
+	return current, old
+}
+
+// findPepper
+//
+//	@Description: returns the secret of the pepper identified by `id`.
+//	@param id: empty for the hashes done without pepper.
+//	@return []byte
+//	@return bool
+func findPepper(id string) ([]byte, bool) {
+	if id == "" {
+		return nil, true
+	}
+	if currentPepper != nil && currentPepper.Id == id {
+		return currentPepper.Secret, true
+	}
+	p, ok := oldPeppers[id]
+	return p.Secret, ok
+}
 
 // newSalt
 //
 //	@Description: generates a random Salt.
+//	@param length
 //	@return []byte
-func newSalt() []byte {
-	b := make([]byte, 64)
+func newSalt(length int) []byte {
+	b := make([]byte, length)
 	_, err := rand.Read(b)
 	if err != nil {
 		return nil
@@ -25,16 +167,17 @@ func newSalt() []byte {
 	return b
 }
 
-// hash
+// legacyHash
 //
-//	@Description: iteratively hashes the pwd with the salt and pepper and returns it.
+//	@Description: hashes the pwd with the salt and legacy pepper like the
+//	passwords were hashed before Argon2id.
 //	@param pwd
 //	@param salt
 //	@return string
-func hash(pwd string, salt []byte) string {
+func legacyHash(pwd string, salt []byte) string {
 	saltedPwd := append(salt, []byte(pwd)...)
 	sha := sha512.New()
-	sha.Write(append(saltedPwd, pepper...))
+	sha.Write(append(saltedPwd, legacyPepper...))
 	// key stretching
 	for range 10071 {
 		sha.Sum(sha.Sum(nil))
@@ -42,20 +185,163 @@ func hash(pwd string, salt []byte) string {
 	return hex.EncodeToString(sha.Sum(nil))
 }
 
+// argon2Key
+//
+//	@Description: derives the Argon2id key of the pwd, mixed with the `secret`
+//	pepper if any.
+//	@param pwd
+//	@param salt
+//	@param secret
+//	@param params
+//	@param length
+//	@return []byte
+func argon2Key(pwd string, salt []byte, secret []byte, params argon2Params, length uint32) []byte {
+	input := []byte(pwd)
+	if secret != nil {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		input = mac.Sum(nil)
+	}
+	return argon2.IDKey(input, salt, params.Iterations, params.Memory, params.Parallelism, length)
+}
+
+// phcHash is a decoded Argon2id hash, stored in the PHC string format:
+// $argon2id$v=19$m=<memory>,t=<iterations>,p=<parallelism>[,keyid=<pepper id>]$<salt>$<hash>
+type phcHash struct {
+	Params   argon2Params
+	PepperId string
+	Salt     []byte
+	Key      []byte
+}
+
+// String encodes the phcHash in the PHC string format.
+func (h phcHash) String() string {
+	params := fmt.Sprintf("m=%d,t=%d,p=%d", h.Params.Memory, h.Params.Iterations, h.Params.Parallelism)
+	if h.PepperId != "" {
+		params += ",keyid=" + h.PepperId
+	}
+	return fmt.Sprintf("$argon2id$v=%d$%s$%s$%s", argon2.Version, params,
+		base64.RawStdEncoding.EncodeToString(h.Salt), base64.RawStdEncoding.EncodeToString(h.Key))
+}
+
+// parsePHC
+//
+//	@Description: decodes an Argon2id hash stored in the PHC string format.
+//	@param encoded
+//	@return phcHash
+//	@return error
+func parsePHC(encoded string) (phcHash, error) {
+	var h phcHash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != "argon2id" {
+		return h, errors.New("not an argon2id hash")
+	}
+	if parts[2] != "v="+strconv.Itoa(argon2.Version) {
+		return h, errors.New("unsupported argon2 version " + parts[2])
+	}
+	for _, param := range strings.Split(parts[3], ",") {
+		name, value, _ := strings.Cut(param, "=")
+		var n uint64
+		var err error
+		switch name {
+		case "m":
+			n, err = strconv.ParseUint(value, 10, 32)
+			h.Params.Memory = uint32(n)
+		case "t":
+			n, err = strconv.ParseUint(value, 10, 32)
+			h.Params.Iterations = uint32(n)
+		case "p":
+			n, err = strconv.ParseUint(value, 10, 8)
+			h.Params.Parallelism = uint8(n)
+		case "keyid":
+			h.PepperId = value
+		default:
+			err = errors.New("unknown parameter " + name)
+		}
+		if err != nil {
+			return h, err
+		}
+	}
+	if h.Params.Memory == 0 || h.Params.Iterations == 0 || h.Params.Parallelism == 0 {
+		return h, errors.New("missing argon2id parameters")
+	}
+	var err error
+	h.Salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return h, err
+	}
+	h.Key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(h.Key) == 0 {
+		return h, errors.New("invalid argon2id hash")
+	}
+	return h, nil
+}
+
 // NewPwd
 //
-//	@Description: generates a new salt and pwd (hashed).
+//	@Description: hashes a new password with Argon2id, the current parameters
+//	and pepper (the salt is stored in the returned hash).
 //	@param pwd
 //	@return string
-//	@return string
-func NewPwd(pwd string) (string, string) {
-	salt := newSalt()
-	return hash(pwd, salt), base64.StdEncoding.EncodeToString(salt)
+func NewPwd(pwd string) string {
+	h := phcHash{
+		Params: passwordParams,
+		Salt:   newSalt(saltLength),
+	}
+	var secret []byte
+	if currentPepper != nil {
+		h.PepperId, secret = currentPepper.Id, currentPepper.Secret
+	}
+	h.Key = argon2Key(pwd, h.Salt, secret, h.Params, keyLength)
+	return h.String()
+}
+
+// verifyPwd
+//
+//	@Description: checks the password against the user's stored hash.
+//	@param user
+//	@param pwd
+//	@return ok: whether the password is correct.
+//	@return outdated: whether the hash must be replaced (legacy hash, or older
+//	parameters or pepper).
+func verifyPwd(user server.User, pwd string) (ok bool, outdated bool) {
+	if user.HashedPwd == "" {
+		// the users created through OpenID Connect have no password
+		return false, false
+	}
+	if !strings.HasPrefix(user.HashedPwd, "$") {
+		salt, err := base64.StdEncoding.DecodeString(user.Salt)
+		if err != nil {
+			return false, false
+		}
+		return subtle.ConstantTimeCompare([]byte(user.HashedPwd), []byte(legacyHash(pwd, salt))) == 1, true
+	}
+	
+	h, err := parsePHC(user.HashedPwd)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err), slog.String("user", user.Username))
+		return false, false
+	}
+	secret, found := findPepper(h.PepperId)
+	if !found {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("unknown pepper "+h.PepperId)), slog.String("user", user.Username))
+		return false, false
+	}
+	key := argon2Key(pwd, h.Salt, secret, h.Params, uint32(len(h.Key)))
+	if subtle.ConstantTimeCompare(h.Key, key) != 1 {
+		return false, false
+	}
+	currentId := ""
+	if currentPepper != nil {
+		currentId = currentPepper.Id
+	}
+	return true, h.Params != passwordParams || h.PepperId != currentId
 }
 
 // CheckPwd
 //
 //	@Description: checks whether the models.Credentials are correct or not.
+//	A correct password with an outdated hash is hashed again and saved.
 //	@param cred
 //	@return bool
 func CheckPwd(cred server.Credentials) bool {
@@ -63,9 +349,11 @@ func CheckPwd(cred server.Credentials) bool {
 	if !ok {
 		return false
 	}
-	salt, err := base64.StdEncoding.DecodeString(user.Salt)
-	if err != nil {
-		return false
+	ok, outdated := verifyPwd(user, cred.Password)
+	if ok && outdated {
+		user.HashedPwd, user.Salt = NewPwd(cred.Password), ""
+		UpdateUser(user)
+		Logger.Info("password rehashed", slog.String("user", user.Username))
 	}
-	return user.HashedPwd == hash(cred.Password, salt)
+	return ok
 }
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/http"
+)
+
+// csrfKey is the secret key signing the CSRF tokens. It is generated at
+// startup: the forms opened before a restart have to be reloaded.
+var csrfKey = newCSRFKey()
+
+// newCSRFKey
+//
+//	@Description: generates a random csrfKey.
+//	@return []byte
+func newCSRFKey() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	return key
+}
+
+// csrfCookie
+//
+//	@Description: creates the csrf_id cookie, which binds the visitors' CSRF
+//	tokens to their browser until they log in.
+//	@param value
+//	@return *http.Cookie
+func csrfCookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     "csrf_id",
+		Value:    value,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// csrfContextKey is the key of the CSRF token in the requests' context.
+type csrfContextKey struct{}
+
+// signCSRF
+//
+//	@Description: computes the CSRF token bound to the `id` (a session ID or a
+//	csrf_id cookie).
+//	@param id
+//	@return string
+func signCSRF(id string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte("csrf:" + id))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// isSafeMethod
+//
+//	@Description: checks if the `method` doesn't change the server's state.
+//	@param method
+//	@return bool
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// CheckCSRF
+//
+//	@Description: computes the CSRF token of the client, bound to its session
+//	ID when logged or to its csrf_id cookie (written if needed) when not, and
+//	puts it in the *http.Request's context. For the state-changing methods, the
+//	token sent in the `csrf_token` form value or the X-CSRF-Token header must
+//	match it.
+//	@param w
+//	@param r
+//	@return *http.Request: holding the client's CSRF token.
+//	@return bool: false if the token sent is missing or invalid.
+func CheckCSRF(w *http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	id, ok := r.Context().Value(sessionContextKey{}).(string)
+	if !ok || id == "" {
+		cookie, err := r.Cookie("csrf_id")
+		if err == nil && len(cookie.Value) == 43 {
+			id = cookie.Value
+		} else {
+			id, err = randomToken(32)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			http.SetCookie(*w, csrfCookie(id))
+		}
+	}
+	token := signCSRF(id)
+	r = r.WithContext(context.WithValue(r.Context(), csrfContextKey{}, token))
+	
+	if isSafeMethod(r.Method) {
+		return r, true
+	}
+	sent := r.Header.Get("X-CSRF-Token")
+	if sent == "" {
+		sent = r.PostFormValue("csrf_token")
+	}
+	return r, hmac.Equal([]byte(sent), []byte(token))
+}
+
+// CSRFToken
+//
+//	@Description: returns the CSRF token put in the *http.Request's context by
+//	the CSRF middleware, to be sent back with the forms and javascript requests.
+//	@param r
+//	@return string
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfContextKey{}).(string)
+	return token
+}
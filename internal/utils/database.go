@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"database/sql"
+	"sync"
+	
+	_ "modernc.org/sqlite"
+)
+
+// databaseFile is the embedded SQLite database's file path.
+var databaseFile = directory + "/mangathorg.db"
+
+// database is the shared connection to databaseFile (opened on first use).
+var database struct {
+	once sync.Once
+	db   *sql.DB
+	err  error
+}
+
+// Database
+//
+//	@Description: opens (only once) and returns the embedded SQLite database.
+//	@return *sql.DB
+//	@return error
+func Database() (*sql.DB, error) {
+	database.once.Do(func() {
+		dsn := "file:" + databaseFile + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+		database.db, database.err = sql.Open("sqlite", dsn)
+		if database.err != nil {
+			return
+		}
+		database.err = database.db.Ping()
+	})
+	return database.db, database.err
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// UserSessions
+//
+//	@Description: returns the active models.Session of the user, the most
+//	recently used first.
+//	@param userID
+//	@return []models.Session
+func UserSessions(userID int) []server.Session {
+	var list []server.Session
+	for _, session := range retrieveSessions() {
+		if session.UserID == userID && !session.Rotated && session.ExpirationTime.After(time.Now()) {
+			list = append(list, session)
+		}
+	}
+	slices.SortFunc(list, func(a, b server.Session) int {
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	return list
+}
+
+// RevokeSession
+//
+//	@Description: logs out the user's models.Session which ConnectionID matches
+//	the `connectionID` param, and forgets its device.
+//	@param userID
+//	@param connectionID
+//	@return bool: false if no models.Session matched.
+func RevokeSession(userID int, connectionID int) bool {
+	return revokeSessions(func(session server.Session) bool {
+		return session.UserID == userID && session.ConnectionID == connectionID
+	}) > 0
+}
+
+// RevokeOtherSessions
+//
+//	@Description: logs out all the user's models.Session but the one found in
+//	the *http.Request, and forgets their devices.
+//	@param r
+//	@return int: the number of revoked models.Session.
+func RevokeOtherSessions(r *http.Request) int {
+	current, sessionID := GetSession(r)
+	if sessionID == "" {
+		return 0
+	}
+	
+	revoked := revokeSessions(func(session server.Session) bool {
+		return session.UserID == current.UserID && session.ConnectionID != current.ConnectionID
+	})
+	
+	err := sessions.DeleteUserTokens(current.UserID, current.RememberSelector)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	return revoked
+}
+
+// revokeSessions
+//
+//	@Description: removes the models.Session matching the `match` param, and
+//	their models.RememberToken, from the store.
+//	@param match
+//	@return int: the number of revoked models.Session.
+func revokeSessions(match func(session server.Session) bool) int {
+	all, err := sessions.All()
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	
+	var revoked int
+	for key, session := range all {
+		if !match(session) {
+			continue
+		}
+		err = sessions.Delete(key)
+		if err != nil {
+			Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+			continue
+		}
+		deleteToken(session.RememberSelector)
+		if !session.Rotated {
+			revoked++
+			Logger.Info("Session revoked", slog.Any("user", session))
+		}
+	}
+	return revoked
+}
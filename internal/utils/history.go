@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// maxHistory is the maximum number of entries kept in a models.User's reading history.
+const maxHistory = 500
+
+// generateHistoryID
+//
+//	@Description: generates a random models.HistoryEntry id.
+//	@return string
+func generateHistoryID() string {
+	b := make([]byte, 12)
+	_, err := rand.Read(b)
+	if err != nil {
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(b)
+}
+
+// AddHistoryEntry
+//
+//	@Description: adds an `entry` on top of the reading history of the models.User
+//	which username matches the `username` param. If the last entry concerns the
+//	same chapter, it is updated instead.
+//	@param username
+//	@param entry
+//	@return string: the entry's id.
+func AddHistoryEntry(username string, entry server.HistoryEntry) string {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	
+	var id string
+	_, ok := ModifyUser(username, func(user *server.User) bool {
+		// re-opening the same chapter only updates the last entry
+		if len(user.History) > 0 && user.History[0].ChapterId == entry.ChapterId {
+			user.History[0].Time = entry.Time
+			id = user.History[0].Id
+			return true
+		}
+		
+		entry.Id = generateHistoryID()
+		user.History = append([]server.HistoryEntry{entry}, user.History...)
+		if len(user.History) > maxHistory {
+			user.History = user.History[:maxHistory]
+		}
+		id = entry.Id
+		return true
+	})
+	if !ok {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		return ""
+	}
+	return id
+}
+
+// UpdateHistoryPage
+//
+//	@Description: sets the page reached in a models.HistoryEntry (only if it is
+//	further than the one already stored).
+//	@param username
+//	@param id: the entry's id.
+//	@param page
+//	@return bool: false if the entry has not been found.
+func UpdateHistoryPage(username string, id string, page int) bool {
+	var found bool
+	ModifyUser(username, func(user *server.User) bool {
+		for i, entry := range user.History {
+			if entry.Id == id {
+				found = true
+				if page > entry.Page {
+					user.History[i].Page = page
+					return true
+				}
+				return false
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// DeleteHistoryEntry
+//
+//	@Description: removes a single entry from a models.User's reading history.
+//	@param username
+//	@param id: the entry's id.
+//	@return bool: false if the entry has not been found.
+func DeleteHistoryEntry(username string, id string) bool {
+	var found bool
+	ModifyUser(username, func(user *server.User) bool {
+		for i, entry := range user.History {
+			if entry.Id == id {
+				user.History = append(user.History[:i], user.History[i+1:]...)
+				found = true
+				return true
+			}
+		}
+		return false
+	})
+	return found
+}
+
+// ClearHistory
+//
+//	@Description: removes all entries from a models.User's reading history.
+//	@param username
+//	@return bool: false if the user has not been found.
+func ClearHistory(username string) bool {
+	_, ok := ModifyUser(username, func(user *server.User) bool {
+		user.History = nil
+		return true
+	})
+	return ok
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	
+	"mangathorg/internal/models/server"
+)
+
+// SendJSON
+//
+//	@Description: sends the `response` envelope in JSON with the `status` code.
+//	@param w
+//	@param status
+//	@param response
+func SendJSON(w http.ResponseWriter, status int, response server.JSONResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
+// SendJSONError
+//
+//	@Description: sends an error in a JSON envelope, its code being the
+//	status' text in snake case (e.g. "not_found").
+//	@param w
+//	@param status
+//	@param message
+func SendJSONError(w http.ResponseWriter, status int, message string) {
+	SendJSON(w, status, server.JSONResponse{Error: &server.JSONError{
+		Status:  status,
+		Code:    strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_"),
+		Message: message,
+	}})
+}
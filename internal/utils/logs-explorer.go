@@ -0,0 +1,284 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logPageSize is the number of Log displayed on each page of the log explorer.
+const logPageSize = 50
+
+// logBlockSize is the size of the blocks read from the end of the log files.
+const logBlockSize = 64 * 1024
+
+// logFilterTime is the format of the time range of a LogFilter in the queries
+// (the one of the datetime-local inputs).
+const logFilterTime = "2006-01-02T15:04"
+
+// logFileName matches the names of the log files written by LogInit.
+var logFileName = regexp.MustCompile(`^logs_(\d{4}-\d{2}-\d{2})\.log$`)
+
+var ErrLogFilter = errors.New("invalid log filter")
+
+// LogFilter selects the Log displayed by the log explorer. Its empty fields
+// match any Log.
+type LogFilter struct {
+	From time.Time
+	To   time.Time
+	// Level is the minimum level of the Log (slog.LevelDebug matches all of them).
+	Level    slog.Level
+	Username string
+	ClientIP string
+	// URL matches the Log which request URL contains it.
+	URL   string
+	ReqId int
+}
+
+// LogPage is a page of the Log matching a LogFilter, from the newest to the
+// oldest.
+type LogPage struct {
+	Logs    Logs
+	Page    int
+	HasNext bool
+}
+
+// ParseLogFilter
+//
+//	@Description: reads the LogFilter from the `from`, `to`, `level`, `user`,
+//	`ip`, `url` and `req_id` query keys.
+//	@param query
+//	@return LogFilter
+//	@return error: ErrLogFilter if a value is malformed.
+func ParseLogFilter(query url.Values) (LogFilter, error) {
+	filter := LogFilter{
+		Level:    slog.LevelDebug,
+		Username: strings.TrimSpace(query.Get("user")),
+		ClientIP: strings.TrimSpace(query.Get("ip")),
+		URL:      strings.TrimSpace(query.Get("url")),
+	}
+	var err error
+	if value := query.Get("from"); value != "" {
+		filter.From, err = time.ParseInLocation(logFilterTime, value, time.Local)
+		if err != nil {
+			return filter, ErrLogFilter
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		filter.To, err = time.ParseInLocation(logFilterTime, value, time.Local)
+		if err != nil {
+			return filter, ErrLogFilter
+		}
+	}
+	if value := query.Get("level"); value != "" {
+		err = filter.Level.UnmarshalText([]byte(value))
+		if err != nil {
+			return filter, ErrLogFilter
+		}
+	}
+	if value := query.Get("req_id"); value != "" {
+		filter.ReqId, err = strconv.Atoi(value)
+		if err != nil || filter.ReqId <= 0 {
+			return filter, ErrLogFilter
+		}
+	}
+	return filter, nil
+}
+
+// Match
+//
+//	@Description: checks if the `entry` is selected by the LogFilter.
+//	@receiver filter
+//	@param entry
+//	@return bool
+func (filter LogFilter) Match(entry Log) bool {
+	if !filter.From.IsZero() && entry.Time.Before(filter.From) {
+		return false
+	}
+	if !filter.To.IsZero() && entry.Time.After(filter.To) {
+		return false
+	}
+	if filter.Level > slog.LevelDebug {
+		var level slog.Level
+		if level.UnmarshalText([]byte(entry.Level)) != nil || level < filter.Level {
+			return false
+		}
+	}
+	if filter.Username != "" && !strings.EqualFold(entry.User.Username, filter.Username) {
+		return false
+	}
+	if filter.ClientIP != "" && entry.ClientIP != filter.ClientIP {
+		return false
+	}
+	if filter.URL != "" && !strings.Contains(entry.ReqURL, filter.URL) {
+		return false
+	}
+	return filter.ReqId == 0 || entry.ReqId == filter.ReqId
+}
+
+// SearchLogs
+//
+//	@Description: returns the `page` of the Log matching the `filter`. The log
+//	files are read from the newest to the oldest, each of them from its end, and
+//	the reading stops as soon as the page is complete.
+//	@param filter
+//	@param page: starting at 1.
+//	@return LogPage
+//	@return error
+func SearchLogs(filter LogFilter, page int) (LogPage, error) {
+	result := LogPage{Page: max(page, 1), Logs: Logs{}}
+	files, err := logFiles(filter)
+	if err != nil {
+		return result, err
+	}
+	
+	skip := (result.Page - 1) * logPageSize
+	for _, file := range files {
+		var done bool
+		err = readLogFile(file, func(entry Log) bool {
+			if !filter.From.IsZero() && entry.Time.Before(filter.From) {
+				// the older Log can't match anymore
+				done = true
+				return false
+			}
+			if !filter.Match(entry) {
+				return true
+			}
+			if skip > 0 {
+				skip--
+				return true
+			}
+			if len(result.Logs) == logPageSize {
+				result.HasNext = true
+				done = true
+				return false
+			}
+			result.Logs = append(result.Logs, entry)
+			return true
+		})
+		if err != nil {
+			return result, err
+		}
+		if done {
+			break
+		}
+	}
+	return result, nil
+}
+
+// logFiles
+//
+//	@Description: returns the paths of the log files which day can hold Log
+//	selected by the `filter`, from the newest to the oldest.
+//	@param filter
+//	@return []string
+//	@return error
+func logFiles(filter LogFilter) ([]string, error) {
+	entries, err := os.ReadDir(Path + "logs")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	var files []string
+	for _, entry := range entries {
+		match := logFileName.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		day, err := time.ParseInLocation(time.DateOnly, match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		if (!filter.To.IsZero() && day.After(filter.To)) || (!filter.From.IsZero() && !day.AddDate(0, 0, 1).After(filter.From)) {
+			continue
+		}
+		files = append(files, entry.Name())
+	}
+	// the names hold the dates in a sortable format
+	slices.Sort(files)
+	slices.Reverse(files)
+	for i := range files {
+		files[i] = Path + "logs/" + files[i]
+	}
+	return files, nil
+}
+
+// readLogFile
+//
+//	@Description: calls `f` with every Log of the `filename` file, from the
+//	newest to the oldest, until it returns false. The malformed lines are
+//	skipped.
+//	@param filename
+//	@param f
+//	@return error
+func readLogFile(filename string, f func(entry Log) bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	
+	return readLinesBackward(file, func(line []byte) bool {
+		var entry Log
+		if json.Unmarshal(line, &entry) != nil {
+			return true
+		}
+		return f(entry)
+	})
+}
+
+// readLinesBackward
+//
+//	@Description: calls `f` with every line of the `file`, from the last one to
+//	the first one, until it returns false. The file is read by blocks of
+//	logBlockSize from its end, so that only the lines needed are loaded. The
+//	lines passed to `f` are only valid until it returns.
+//	@param file
+//	@param f
+//	@return error
+func readLinesBackward(file *os.File, f func(line []byte) bool) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	
+	offset := info.Size()
+	block := make([]byte, logBlockSize)
+	// rest is the beginning of the line cut by the previous block
+	var rest []byte
+	for offset > 0 {
+		size := min(int64(logBlockSize), offset)
+		offset -= size
+		_, err = file.ReadAt(block[:size], offset)
+		if err != nil {
+			return err
+		}
+		chunk := append(block[:size:size], rest...)
+		for {
+			i := bytes.LastIndexByte(chunk, '\n')
+			if i < 0 {
+				break
+			}
+			if line := chunk[i+1:]; len(line) > 0 && !f(line) {
+				return nil
+			}
+			chunk = chunk[:i]
+		}
+		rest = append(rest[:0], chunk...)
+	}
+	if len(rest) > 0 {
+		f(rest)
+	}
+	return nil
+}
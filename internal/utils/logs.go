@@ -1,14 +1,13 @@
 package utils
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"log/slog"
 	"os"
-	"regexp"
-	"sort"
-	"strings"
 	"sync"
 	"time"
 	
@@ -20,7 +19,7 @@ type Logs []Log
 type Log struct {
 	Time       time.Time      `json:"time"`
 	Level      string         `json:"level"`
-	Message    string         `json:"message"`
+	Message    string         `json:"msg"`
 	ReqId      int            `json:"req_id,omitempty"`
 	User       server.Session `json:"user,omitempty"`
 	ClientIP   string         `json:"client_ip,omitempty"`
@@ -32,7 +31,6 @@ type Log struct {
 
 var Logger *slog.Logger
 var logs *os.File
-var wg sync.WaitGroup
 
 // closeLog
 //
@@ -47,14 +45,10 @@ func closeLog() {
 }
 
 // LogInit is meant to be run as a goroutine to create a new log file every day
-// appending the file's creation timestamp in its name.
+// appending the file's creation timestamp in its name. In production, the logs
+// are also written as text to the standard output.
 func LogInit() {
 	env := os.Getenv("ENV")
-	if env == "production" {
-		Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
-		Logger.Info(GetCurrentFuncName(), slog.String("goroutine", "LogInit"))
-		return
-	}
 	duration := SetDailyTimer(0)
 	var jsonHandler *slog.JSONHandler
 	var err error
@@ -79,101 +73,102 @@ func LogInit() {
 		if err != nil {
 			log.Println(GetCurrentFuncName(), slog.Any("output", err))
 		}
-		jsonHandler = slog.NewJSONHandler(logs, nil)
-		Logger = slog.New(jsonHandler)
+		jsonHandler = slog.NewJSONHandler(io.MultiWriter(tail, logs), nil)
+		if env == "production" {
+			// the files are still needed by the log explorer's search
+			Logger = slog.New(teeHandler{slog.NewTextHandler(os.Stdout, nil), jsonHandler})
+		} else {
+			Logger = slog.New(jsonHandler)
+		}
 		Logger.Info(GetCurrentFuncName(), slog.String("goroutine", "LogInit"))
 		time.Sleep(duration)
 		duration = time.Hour * 24
 	}
 }
 
-// fetchLogInfo retrieves all Log from `file` and stores it in *log.
-func (log *Logs) fetchLogInfo(file string) {
-	defer wg.Done()
-	filename := "logs/" + file
-	data, err := os.ReadFile(filename)
-	if len(data) == 0 {
-		return
+// logTail is the io.Writer receiving every Log as a JSON line, which it sends
+// to the subscribers of the live tail.
+type logTail struct {
+	mu          sync.Mutex
+	subscribers map[chan Log]struct{}
+}
+
+// tail is the logTail written by the Logger.
+var tail = &logTail{subscribers: make(map[chan Log]struct{})}
+
+func (t *logTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.subscribers) == 0 {
+		return len(p), nil
 	}
-	lines := bytes.Split(data, []byte("\n"))
-	var singleLog Log
-	for _, line := range lines {
-		err = json.Unmarshal(line, &singleLog)
-		if err != nil {
-			return
+	var entry Log
+	if json.Unmarshal(p, &entry) != nil {
+		return len(p), nil
+	}
+	for subscriber := range t.subscribers {
+		select {
+		case subscriber <- entry:
+		default:
+			// the subscriber is too slow: the Log is dropped rather than
+			// blocking the Logger
 		}
-		*log = append(*log, singleLog)
 	}
+	return len(p), nil
 }
 
-// printFileNames
+// SubscribeLogs
 //
-//	@Description: verbose function, for testing
-//	@param files
-//	@return []string
-//	@return for
-func printFileNames(files []os.DirEntry) []string {
-	var result []string
-	for _, file := range files {
-		result = append(result, file.Name())
+//	@Description: subscribes to the live tail of the logs.
+//	@return <-chan Log: receiving every new Log.
+//	@return func(): to call to unsubscribe.
+func SubscribeLogs() (<-chan Log, func()) {
+	subscriber := make(chan Log, 64)
+	tail.mu.Lock()
+	tail.subscribers[subscriber] = struct{}{}
+	tail.mu.Unlock()
+	
+	return subscriber, func() {
+		tail.mu.Lock()
+		delete(tail.subscribers, subscriber)
+		tail.mu.Unlock()
 	}
-	return result
 }
 
-// RetrieveLogs fetches all Log from all files *.log in /logs directory
-// and returns a Logs array.
-func RetrieveLogs() (logArray Logs) {
-	logFiles, err := os.ReadDir(Path + "logs/.")
-	// fmt.Printf("logFiles: %#v\n", printFileNames(logFiles)) // verbose
-	if err != nil {
-		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
-	} else {
-		reg := regexp.MustCompile(`\.log$`)
-		for _, file := range logFiles {
-			if reg.MatchString(file.Name()) {
-				wg.Add(1)
-				go logArray.fetchLogInfo(file.Name())
-			}
+// teeHandler is a slog.Handler passing the records to several slog.Handler.
+type teeHandler []slog.Handler
+
+func (h teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
 		}
 	}
-	wg.Wait()
-	logArray.sortLogs()
-	return logArray
+	return false
 }
 
-// sortLogs sort all Log from the newest to the oldest.
-func (log *Logs) sortLogs() {
-	sort.Slice(*log, func(i, j int) bool {
-		return (*log)[i].Time.After((*log)[j].Time)
-	})
+func (h teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
 }
 
-// FetchAttrLogs filters Log returning only Log matching the given `level`.
-func FetchAttrLogs(attr string, value string) Logs {
-	attr = strings.ToLower(attr)
-	logs := RetrieveLogs()
-	var result Logs
-	switch attr {
-	case "level":
-		switch strings.ToUpper(value) {
-		case "INFO", "WARN", "ERROR":
-			for _, singleLog := range logs {
-				if singleLog.Level == strings.ToUpper(value) {
-					result = append(result, singleLog)
-				}
-			}
-		default:
-			return nil
-		}
-	case "user", "username":
-		for _, singleLog := range logs {
-			if strings.EqualFold(singleLog.User.Username, value) {
-				result = append(result, singleLog)
-			}
-		}
-	default:
-		return nil
+func (h teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(teeHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h teeHandler) WithGroup(name string) slog.Handler {
+	handlers := make(teeHandler, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
 	}
-	result.sortLogs()
-	return result
+	return handlers
 }
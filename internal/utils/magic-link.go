@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// magicLinkTime is the lifetime of the links sent by mail to log in without
+// password.
+const magicLinkTime time.Duration = time.Minute * 15
+
+var (
+	ErrMagicLinkInvalid = errors.New("expired or invalid magic link")
+	ErrMagicLinkBrowser = errors.New("magic link opened in another browser")
+)
+
+// magicLinkCookie
+//
+//	@Description: creates the magic_link cookie, which binds the magic links to
+//	the browser asking for them. It is Lax, since the links are opened from
+//	the users' mail clients.
+//	@param value
+//	@return *http.Cookie
+func magicLinkCookie(value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     "magic_link",
+		Value:    value,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/login/email",
+		MaxAge:   int(magicLinkTime.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// RequestMagicLink
+//
+//	@Description: sends a single-use link to the `user` by mail, to log in
+//	without password from the browser of the *http.Request only. The browser
+//	keeps its magic_link cookie, so that the links it asked for before stay
+//	valid.
+//	@param w
+//	@param r
+//	@param user
+func RequestMagicLink(w *http.ResponseWriter, r *http.Request, user server.User) {
+	var browser string
+	if cookie, err := r.Cookie("magic_link"); err == nil && cookie.Value != "" {
+		browser = cookie.Value
+	} else {
+		browser, err = randomToken(32)
+		if err != nil {
+			Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+			return
+		}
+	}
+	http.SetCookie(*w, magicLinkCookie(browser))
+	
+	var temp = server.TempUser{
+		CreationTime: time.Now(),
+		User:         user,
+		Browser:      confirmationKey(browser),
+	}
+	SendMail(&temp, "magic")
+	AddTempUser(temp, "magic")
+	Logger.Info("Magic link sent", slog.Int("user_id", user.Id), slog.String("client_ip", GetIP(r)))
+}
+
+// checkMagicLinkBrowser
+//
+//	@Description: checks if the *http.Request comes from the browser which
+//	asked for the `temp` magic link.
+//	@param r
+//	@param temp
+//	@return bool
+func checkMagicLinkBrowser(r *http.Request, temp server.TempUser) bool {
+	cookie, err := r.Cookie("magic_link")
+	return err == nil && temp.Browser != "" && confirmationKey(cookie.Value) == temp.Browser
+}
+
+// CheckMagicLink
+//
+//	@Description: checks if the magic link which ConfirmID matches the `id`
+//	param is still valid and opened in the browser which asked for it.
+//	@param r
+//	@param id
+//	@return string: the username of the user to log in.
+//	@return error: ErrMagicLinkInvalid or ErrMagicLinkBrowser.
+func CheckMagicLink(r *http.Request, id string) (string, error) {
+	temp, ok := lookupTempUser(id, "magic")
+	if !ok {
+		return "", ErrMagicLinkInvalid
+	}
+	if !checkMagicLinkBrowser(r, temp) {
+		return "", ErrMagicLinkBrowser
+	}
+	return temp.User.Username, nil
+}
+
+// CompleteMagicLink
+//
+//	@Description: uses the magic link which ConfirmID matches the `id` param.
+//	It is only removed when opened in the right browser, so that another
+//	browser can't waste it.
+//	@param r
+//	@param id
+//	@return models.User: the user to log in.
+//	@return error: ErrMagicLinkInvalid or ErrMagicLinkBrowser.
+func CompleteMagicLink(r *http.Request, id string) (server.User, error) {
+	if _, err := CheckMagicLink(r, id); err != nil {
+		return server.User{}, err
+	}
+	temp, ok := takeTempUser(id, "magic")
+	if !ok {
+		return server.User{}, ErrMagicLinkInvalid
+	}
+	user, ok := SelectUserById(temp.User.Id)
+	if !ok {
+		return server.User{}, ErrMagicLinkInvalid
+	}
+	return user, nil
+}
@@ -74,7 +74,10 @@ func fetchConfig() server.MailConfig {
 
 // SendMail
 //
-//	@Description: sends a mail to models.TempUser to create his account.
+//	@Description: sends a mail to models.TempUser to create his account, to set
+//	a new password, to confirm or cancel the change of his email address, to
+//	log in without password, or to warn him that his account has been locked
+//	out.
 //	@param temp
 //	@param status
 func SendMail(temp *server.TempUser, status string) {
@@ -96,6 +99,18 @@ func SendMail(temp *server.TempUser, status string) {
 	case "lost":
 		subject = "Set a new password"
 		templateName = "new-password-mail"
+	case "lockout":
+		subject = "Too many login attempts"
+		templateName = "lockout-mail"
+	case "email-change":
+		subject = "Confirm your new email address"
+		templateName = "email-change-mail"
+	case "email-notice":
+		subject = "Your email address is being changed"
+		templateName = "email-notice-mail"
+	case "magic":
+		subject = "Your login link"
+		templateName = "magic-link-mail"
 	}
 	
 	// Setting the headers
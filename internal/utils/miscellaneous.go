@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -47,29 +48,84 @@ func SetDailyTimer(hour int) time.Duration {
 	return d
 }
 
+// trustedProxies are the networks of the reverse proxies whose
+// X-Forwarded-For header can be trusted, set with the TRUSTED_PROXIES
+// environment variable (comma separated list of IPs or CIDRs).
+var trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+
+// parseTrustedProxies
+//
+//	@Description: parses a comma separated list of IPs or CIDRs. An invalid
+//	value stops the program.
+//	@param value
+//	@return []*net.IPNet
+func parseTrustedProxies(value string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, proxy := range strings.Split(value, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			log.Fatalf("invalid TRUSTED_PROXIES: %q", proxy)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// isTrustedProxy
+//
+//	@Description: checks if the `ip` belongs to one of the trustedProxies.
+//	@param ip
+//	@return bool
+func isTrustedProxy(ip net.IP) bool {
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetIP
 //
 //	@Description: gets the client's IP address according to the *http.Request.
+//	The X-Forwarded-For header is only read when the request comes from one of
+//	the trustedProxies: the client is then the last address of the header which
+//	isn't a trusted proxy.
 //	@param r
 //	@return string
 func GetIP(r *http.Request) string {
-	ips := r.Header.Get("X-Forwarded-For")
-	splitIps := strings.Split(ips, ",")
-	
-	if len(splitIps) > 0 {
-		// get last IP in list since ELB prepends other user defined IPs, meaning the last one is the actual client IP.
-		netIP := net.ParseIP(splitIps[len(splitIps)-1])
-		if netIP != nil {
-			return netIP.String()
-		}
-	}
-	
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	
-	netIP := net.ParseIP(ip)
+	netIP := net.ParseIP(host)
+	if netIP != nil && isTrustedProxy(netIP) {
+		// get the last untrusted IP in the list, since each proxy appends the address it received the request from.
+		splitIps := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		for i := len(splitIps) - 1; i >= 0; i-- {
+			forwarded := net.ParseIP(strings.TrimSpace(splitIps[i]))
+			if forwarded == nil {
+				break
+			}
+			netIP = forwarded
+			if !isTrustedProxy(forwarded) {
+				break
+			}
+		}
+	}
+	
 	if netIP != nil {
 		ip := netIP.String()
 		if ip == "::1" {
@@ -78,7 +134,7 @@ func GetIP(r *http.Request) string {
 		return ip
 	}
 	
-	log.Fatalln(err)
+	log.Fatalln(errors.New("invalid remote address " + r.RemoteAddr))
 	return ""
 }
 
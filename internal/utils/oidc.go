@@ -0,0 +1,303 @@
+package utils
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	
+	"mangathorg/internal/models/server"
+	"mangathorg/internal/oidc"
+)
+
+// oidcLoginTime is the time given to a user to sign in on the OpenID Connect
+// provider.
+const oidcLoginTime time.Duration = time.Minute * 10
+
+// oidcUsernameLength is the maximum length of the usernames generated for the
+// users created through OpenID Connect.
+const oidcUsernameLength = 24
+
+var (
+	ErrOIDCLogin      = errors.New("expired or forged OpenID Connect login")
+	ErrOIDCEmail      = errors.New("no verified email address")
+	ErrOIDCEmailTaken = errors.New("email address used by a pending registration")
+)
+
+// oidcProvider is the OpenID Connect provider configured by InitOIDC (nil if
+// the OpenID Connect login is off).
+var oidcProvider *oidc.Provider
+
+// oidcProviderName is the name of the provider displayed on the login page.
+var oidcProviderName string
+
+// InitOIDC
+//
+//	@Description: turns the OpenID Connect login on if the OIDC_ISSUER
+//	environment variable is set, along with OIDC_CLIENT_ID and
+//	OIDC_CLIENT_SECRET. The provider's name displayed on the login page is
+//	read from OIDC_PROVIDER_NAME. It must be called once the BaseURL is set,
+//	since the callback's URL is built from it.
+func InitOIDC() {
+	issuer := os.Getenv("OIDC_ISSUER")
+	if issuer == "" {
+		return
+	}
+	clientID, clientSecret := os.Getenv("OIDC_CLIENT_ID"), os.Getenv("OIDC_CLIENT_SECRET")
+	if clientID == "" || clientSecret == "" {
+		log.Fatalln("OIDC_CLIENT_ID and OIDC_CLIENT_SECRET must be set along with OIDC_ISSUER")
+	}
+	oidcProvider = oidc.NewProvider(issuer, clientID, clientSecret, BaseURL+"/login/oidc/callback")
+	oidcProviderName = os.Getenv("OIDC_PROVIDER_NAME")
+	if oidcProviderName == "" {
+		oidcProviderName = "SSO"
+	}
+	log.Printf("OpenID Connect login enabled with %s", oidcProvider.Issuer)
+}
+
+// OIDCProviderName
+//
+//	@Description: returns the name of the OpenID Connect provider, or an
+//	empty string if the OpenID Connect login is off.
+//	@return string
+func OIDCProviderName() string {
+	if oidcProvider == nil {
+		return ""
+	}
+	return oidcProviderName
+}
+
+// oidcLogin is an OpenID Connect login waiting for the user to come back
+// from the provider.
+type oidcLogin struct {
+	State          string
+	Nonce          string
+	Verifier       string
+	ExpirationTime time.Time
+}
+
+// oidcLogins holds the pending oidcLogin by id (the oidc_login cookie's
+// value).
+var oidcLogins = struct {
+	mutex  sync.Mutex
+	logins map[string]oidcLogin
+}{logins: make(map[string]oidcLogin)}
+
+// oidcLoginCookie
+//
+//	@Description: creates the oidc_login cookie. It is Lax, since the provider
+//	sends the user back to the callback with a cross-site redirection.
+//	@param id: "" to delete the cookie.
+//	@return *http.Cookie
+func oidcLoginCookie(id string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     "oidc_login",
+		Value:    id,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/login/oidc",
+		MaxAge:   int(oidcLoginTime.Seconds()),
+		SameSite: http.SameSiteLaxMode,
+	}
+	if id == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// StartOIDCLogin
+//
+//	@Description: creates an oidcLogin, with its state, nonce and PKCE code
+//	verifier, and writes its cookie.
+//	@param w
+//	@param r
+//	@return string: the provider's URL to redirect the user to.
+//	@return error
+func StartOIDCLogin(w *http.ResponseWriter, r *http.Request) (string, error) {
+	var login = oidcLogin{ExpirationTime: time.Now().Add(oidcLoginTime)}
+	id, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	for _, value := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		*value, err = oidc.RandomString()
+		if err != nil {
+			return "", err
+		}
+	}
+	authURL, err := oidcProvider.AuthCodeURL(r.Context(), login.State, login.Nonce, login.Verifier)
+	if err != nil {
+		return "", err
+	}
+	
+	oidcLogins.mutex.Lock()
+	defer oidcLogins.mutex.Unlock()
+	
+	// clearing the expired logins
+	for key, pending := range oidcLogins.logins {
+		if pending.ExpirationTime.Before(time.Now()) {
+			delete(oidcLogins.logins, key)
+		}
+	}
+	oidcLogins.logins[id] = login
+	http.SetCookie(*w, oidcLoginCookie(id))
+	return authURL, nil
+}
+
+// takeOIDCLogin
+//
+//	@Description: removes the oidcLogin of the *http.Request's cookie and
+//	returns it, so that it can't be used twice.
+//	@param w
+//	@param r
+//	@return oidcLogin
+//	@return bool: false if it doesn't exist or has expired.
+func takeOIDCLogin(w *http.ResponseWriter, r *http.Request) (oidcLogin, bool) {
+	cookie, err := r.Cookie("oidc_login")
+	if err != nil {
+		return oidcLogin{}, false
+	}
+	http.SetCookie(*w, oidcLoginCookie(""))
+	
+	oidcLogins.mutex.Lock()
+	defer oidcLogins.mutex.Unlock()
+	
+	login, ok := oidcLogins.logins[cookie.Value]
+	delete(oidcLogins.logins, cookie.Value)
+	return login, ok && login.ExpirationTime.After(time.Now())
+}
+
+// CompleteOIDCLogin
+//
+//	@Description: ends the oidcLogin when the provider sends the user back to
+//	the callback: the state must match the one of the oidcLogin, the code is
+//	exchanged for the ID token and the ID token is verified. The user is then
+//	found or created by oidcUser.
+//	@param w
+//	@param r
+//	@return models.User
+//	@return error
+func CompleteOIDCLogin(w *http.ResponseWriter, r *http.Request) (server.User, error) {
+	login, ok := takeOIDCLogin(w, r)
+	query := r.URL.Query()
+	if !ok || subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(login.State)) != 1 {
+		return server.User{}, ErrOIDCLogin
+	}
+	if query.Has("error") {
+		return server.User{}, fmt.Errorf("provider error: %s %s", query.Get("error"), query.Get("error_description"))
+	}
+	
+	ctx, cancel := context.WithTimeout(r.Context(), oidcLoginTime)
+	defer cancel()
+	tokens, err := oidcProvider.Exchange(ctx, query.Get("code"), login.Verifier)
+	if err != nil {
+		return server.User{}, err
+	}
+	claims, err := oidcProvider.Verify(ctx, tokens.IDToken, login.Nonce)
+	if err != nil {
+		return server.User{}, err
+	}
+	if claims.Email == "" && tokens.AccessToken != "" {
+		// the provider may only send the email address from its userinfo endpoint
+		info, err := oidcProvider.UserInfo(ctx, tokens.AccessToken)
+		if err != nil {
+			return server.User{}, err
+		}
+		if info.Subject == claims.Subject {
+			claims.Email, claims.EmailVerified = info.Email, info.EmailVerified
+			if claims.PreferredUsername == "" {
+				claims.PreferredUsername = info.PreferredUsername
+			}
+		}
+	}
+	return oidcUser(claims)
+}
+
+// oidcUser
+//
+//	@Description: returns the models.User linked to the OpenID Connect
+//	identity of the `claims`. An unknown identity is linked to the models.User
+//	using the same verified email address, or to a new models.User (without
+//	password) if there is none.
+//	@param claims
+//	@return models.User
+//	@return error
+func oidcUser(claims oidc.Claims) (server.User, error) {
+	identity := server.Identity{Issuer: claims.Issuer, Subject: claims.Subject}
+	user, ok, err := users.SelectByIdentity(identity)
+	if err != nil || ok {
+		return user, err
+	}
+	
+	email := strings.TrimSpace(strings.ToLower(claims.Email))
+	if !bool(claims.EmailVerified) || !CheckEmail(email) {
+		return user, ErrOIDCEmail
+	}
+	if exists, user := EmailExists(email); exists {
+		user.Identities = append(user.Identities, identity)
+		UpdateUser(user)
+		Logger.Info("OpenID Connect identity linked", slog.Int("user_id", user.Id), slog.String("issuer", identity.Issuer))
+		return user, nil
+	}
+	if pendingEmail(email) {
+		return user, ErrOIDCEmailTaken
+	}
+	
+	user = server.User{
+		Id:           GetIdNewUser(),
+		CreationTime: time.Now(),
+		Username:     oidcUsername(claims.PreferredUsername, email),
+		Avatar:       "profile-avatar-059.jpg",
+		Email:        email,
+		Identities:   []server.Identity{identity},
+	}
+	err = users.Create(user)
+	if err != nil {
+		return user, err
+	}
+	Logger.Info("Account created with OpenID Connect", slog.Int("user_id", user.Id), slog.String("issuer", identity.Issuer))
+	return user, nil
+}
+
+// oidcUsername
+//
+//	@Description: returns an available username for a new models.User, made
+//	from the `preferred` username sent by the provider or from the local part
+//	of its `email` address, followed by a number if it is already used.
+//	@param preferred
+//	@param email
+//	@return string
+func oidcUsername(preferred string, email string) string {
+	clean := func(name string) string {
+		name = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("-_.", r) {
+				return r
+			}
+			return -1
+		}, name)
+		return string([]rune(name)[:min(len([]rune(name)), oidcUsernameLength)])
+	}
+	base := clean(preferred)
+	if len(base) < 3 {
+		base = clean(strings.Split(email, "@")[0])
+	}
+	if len(base) < 3 {
+		base = "reader"
+	}
+	
+	username := base
+	for i := 2; ; i++ {
+		if _, used := SelectUser(username); !used && !pendingUsername(username) {
+			return username
+		}
+		username = fmt.Sprintf("%s%d", base, i)
+	}
+}
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/models/server"
+	"mangathorg/internal/oidc"
+	"mangathorg/internal/oidc/mock"
+)
+
+// TestMain runs the tests in a temporary directory, holding the JSON user
+// store and the database of the pending users.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "mangathorg-utils")
+	if err != nil {
+		log.Fatalln(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	os.Setenv("SESSION_STORE", "memory")
+	Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	InitUsers()
+	InitSessions()
+	InitPendingUsers()
+	
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// useMockProvider
+//
+//	@Description: turns the OpenID Connect login on with a mock provider for
+//	the test.
+//	@param t
+func useMockProvider(t *testing.T) {
+	var fake *mock.Server
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(provider.Close)
+	fake, err := mock.New(provider.URL, "mangathorg", "mock-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oidcProvider = oidc.NewProvider(provider.URL, "mangathorg", "mock-secret", "http://localhost/login/oidc/callback")
+	oidcProviderName = "SSO"
+	t.Cleanup(func() {
+		oidcProvider, oidcProviderName = nil, ""
+	})
+}
+
+// oidcCallback
+//
+//	@Description: starts an OpenID Connect login, signs in on the mock provider
+//	with the `identity` form values (the authorization request's params being
+//	replaced by the `override` ones) and returns the request sent back to the
+//	callback by the browser.
+//	@param t
+//	@param identity
+//	@param override
+//	@return *http.Request
+func oidcCallback(t *testing.T, identity url.Values, override url.Values) *http.Request {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = rec
+	authURL, err := StartOIDCLogin(&w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := u.Query()
+	for _, values := range []url.Values{override, identity} {
+		for key, value := range values {
+			form[key] = value
+		}
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	res, err := client.PostForm(u.Scheme+"://"+u.Host+u.Path, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusSeeOther {
+		t.Fatalf("authorization: status %d", res.StatusCode)
+	}
+	
+	r := httptest.NewRequest(http.MethodGet, res.Header.Get("Location"), nil)
+	for _, cookie := range rec.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+// completeOIDCLogin
+//
+//	@Description: calls CompleteOIDCLogin for the callback request `r`.
+//	@param r
+//	@return models.User
+//	@return error
+func completeOIDCLogin(r *http.Request) (server.User, error) {
+	var w http.ResponseWriter = httptest.NewRecorder()
+	return CompleteOIDCLogin(&w, r)
+}
+
+// verifiedIdentity returns the form values of a provider's account with a
+// verified email address.
+func verifiedIdentity(sub string, email string) url.Values {
+	return url.Values{"sub": {sub}, "email": {email}, "email_verified": {"true"}, "preferred_username": {sub}}
+}
+
+func TestOIDCLoginCreatesUser(t *testing.T) {
+	useMockProvider(t)
+	
+	user, err := completeOIDCLogin(oidcCallback(t, verifiedIdentity("new-1", "New.Reader@Example.com"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "new-1" || user.Email != "new.reader@example.com" || user.HashedPwd != "" {
+		t.Fatalf("wrong user %+v", user)
+	}
+	stored, ok := SelectUser("new-1")
+	if !ok || len(stored.Identities) != 1 || stored.Identities[0].Subject != "new-1" {
+		t.Fatalf("user not stored with its identity: %+v", stored)
+	}
+	
+	// the next logins find the user by its identity, even with another email
+	again, err := completeOIDCLogin(oidcCallback(t, verifiedIdentity("new-1", "changed@example.com"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != user.Id || again.Email != user.Email {
+		t.Fatalf("another user logged in: %+v", again)
+	}
+}
+
+func TestOIDCLoginLinksExistingUser(t *testing.T) {
+	useMockProvider(t)
+	existing := server.User{Id: GetIdNewUser(), Username: "linked", Email: "Linked.User@example.com", CreationTime: time.Now()}
+	CreateUser(existing)
+	
+	// the provider's address differs by its case
+	user, err := completeOIDCLogin(oidcCallback(t, verifiedIdentity("linked-sub", "linked.user@EXAMPLE.com"), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != existing.Id {
+		t.Fatalf("user %d logged in instead of %d", user.Id, existing.Id)
+	}
+	stored, _ := SelectUserById(existing.Id)
+	if len(stored.Identities) != 1 || stored.Identities[0].Subject != "linked-sub" || stored.Email != existing.Email {
+		t.Fatalf("identity not linked: %+v", stored)
+	}
+}
+
+func TestOIDCLoginRefusesUnverifiedEmail(t *testing.T) {
+	useMockProvider(t)
+	existing := server.User{Id: GetIdNewUser(), Username: "victim", Email: "victim@example.com", CreationTime: time.Now()}
+	CreateUser(existing)
+	
+	identity := url.Values{"sub": {"attacker"}, "email": {"victim@example.com"}}
+	_, err := completeOIDCLogin(oidcCallback(t, identity, nil))
+	if !errors.Is(err, ErrOIDCEmail) {
+		t.Fatalf("unverified email accepted: %v", err)
+	}
+	stored, _ := SelectUserById(existing.Id)
+	if len(stored.Identities) != 0 {
+		t.Fatal("unverified identity linked")
+	}
+}
+
+func TestOIDCLoginChecksState(t *testing.T) {
+	useMockProvider(t)
+	
+	r := oidcCallback(t, verifiedIdentity("state-1", "state-1@example.com"), url.Values{"state": {"forged"}})
+	_, err := completeOIDCLogin(r)
+	if !errors.Is(err, ErrOIDCLogin) {
+		t.Fatalf("wrong state accepted: %v", err)
+	}
+	
+	// without the browser's cookie
+	r = oidcCallback(t, verifiedIdentity("state-2", "state-2@example.com"), nil)
+	r.Header.Del("Cookie")
+	_, err = completeOIDCLogin(r)
+	if !errors.Is(err, ErrOIDCLogin) {
+		t.Fatalf("callback accepted without cookie: %v", err)
+	}
+	
+	// the logins can't be completed twice
+	r = oidcCallback(t, verifiedIdentity("state-3", "state-3@example.com"), nil)
+	if _, err = completeOIDCLogin(r); err != nil {
+		t.Fatal(err)
+	}
+	_, err = completeOIDCLogin(r)
+	if !errors.Is(err, ErrOIDCLogin) {
+		t.Fatalf("login completed twice: %v", err)
+	}
+}
+
+func TestOIDCLoginChecksNonceAndPKCE(t *testing.T) {
+	useMockProvider(t)
+	
+	_, err := completeOIDCLogin(oidcCallback(t, verifiedIdentity("nonce", "nonce@example.com"), url.Values{"nonce": {"replayed"}}))
+	if !errors.Is(err, oidc.ErrIDToken) {
+		t.Fatalf("wrong nonce accepted: %v", err)
+	}
+	_, err = completeOIDCLogin(oidcCallback(t, verifiedIdentity("pkce", "pkce@example.com"), url.Values{"code_challenge": {"forged-challenge"}}))
+	if !errors.Is(err, oidc.ErrExchange) {
+		t.Fatalf("code exchanged without the right verifier: %v", err)
+	}
+	if _, ok := SelectUser("nonce"); ok {
+		t.Fatal("user created from a refused login")
+	}
+}
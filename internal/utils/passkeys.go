@@ -0,0 +1,315 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"mangathorg/internal/models/server"
+	"mangathorg/internal/webauthn"
+)
+
+// passkeyCeremonyTime is the time given to a user to use their authenticator.
+const passkeyCeremonyTime time.Duration = time.Minute * 5
+
+// maxPasskeys is the maximum number of passkeys of a user.
+const maxPasskeys = 10
+
+// passkeyNameLength is the maximum length of the passkeys' names.
+const passkeyNameLength = 64
+
+var (
+	ErrPasskeyCeremony = errors.New("expired or unknown passkey ceremony")
+	ErrPasskeyLimit    = errors.New("too many passkeys")
+	ErrPasskeyTaken    = errors.New("passkey already registered")
+	ErrPasskeyUnknown  = errors.New("unknown passkey")
+)
+
+// relyingParty is the website as a WebAuthn relying party, set by
+// InitPasskeys.
+var relyingParty *webauthn.RelyingParty
+
+// InitPasskeys
+//
+//	@Description: sets the WebAuthn relying party from the BaseURL: the
+//	passkeys are bound to its host name and only work from its origin.
+func InitPasskeys() {
+	var err error
+	relyingParty, err = webauthn.NewRelyingParty("MangaThorg", BaseURL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// passkeyCeremony is a pending WebAuthn ceremony, waiting for the response
+// of the user's authenticator.
+type passkeyCeremony struct {
+	Challenge      []byte
+	ExpirationTime time.Time
+}
+
+// passkeyCeremonies holds the pending registrations by user id, and the
+// pending logins by id (the passkey_login cookie's value).
+var passkeyCeremonies = struct {
+	mutex         sync.Mutex
+	registrations map[int]passkeyCeremony
+	logins        map[string]passkeyCeremony
+}{registrations: make(map[int]passkeyCeremony), logins: make(map[string]passkeyCeremony)}
+
+// newPasskeyCeremony
+//
+//	@Description: creates a passkeyCeremony and clears the expired ones. The
+//	passkeyCeremonies' mutex must be locked.
+//	@return passkeyCeremony
+//	@return error
+func newPasskeyCeremony() (passkeyCeremony, error) {
+	for key, ceremony := range passkeyCeremonies.registrations {
+		if ceremony.ExpirationTime.Before(time.Now()) {
+			delete(passkeyCeremonies.registrations, key)
+		}
+	}
+	for key, ceremony := range passkeyCeremonies.logins {
+		if ceremony.ExpirationTime.Before(time.Now()) {
+			delete(passkeyCeremonies.logins, key)
+		}
+	}
+	challenge, err := webauthn.Challenge()
+	return passkeyCeremony{Challenge: challenge, ExpirationTime: time.Now().Add(passkeyCeremonyTime)}, err
+}
+
+// passkeyLoginCookie
+//
+//	@Description: creates the passkey_login cookie.
+//	@param id: "" to delete the cookie.
+//	@return *http.Cookie
+func passkeyLoginCookie(id string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     "passkey_login",
+		Value:    id,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/login/passkey",
+		MaxAge:   int(passkeyCeremonyTime.Seconds()),
+		SameSite: http.SameSiteStrictMode,
+	}
+	if id == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// passkeyUserHandle
+//
+//	@Description: returns the WebAuthn user handle of the `user`, stored by
+//	their authenticators and sent back when logging in.
+//	@param user
+//	@return []byte
+func passkeyUserHandle(user server.User) []byte {
+	return []byte(strconv.Itoa(user.Id))
+}
+
+// passkeyCredential
+//
+//	@Description: converts a models.Passkey to a webauthn.Credential.
+//	@param passkey
+//	@return webauthn.Credential
+func passkeyCredential(passkey server.Passkey) webauthn.Credential {
+	return webauthn.Credential{
+		ID:         passkey.ID,
+		PublicKey:  passkey.PublicKey,
+		SignCount:  passkey.SignCount,
+		Transports: passkey.Transports,
+	}
+}
+
+// BeginPasskeyRegistration
+//
+//	@Description: starts the registration of a new passkey for the `user`,
+//	replacing their pending one. The user's password must have been checked
+//	first.
+//	@param user
+//	@return webauthn.CreationOptions: sent to navigator.credentials.create.
+//	@return error: ErrPasskeyLimit if the user has too many passkeys.
+func BeginPasskeyRegistration(user server.User) (webauthn.CreationOptions, error) {
+	if len(user.Passkeys) >= maxPasskeys {
+		return webauthn.CreationOptions{}, ErrPasskeyLimit
+	}
+	passkeyCeremonies.mutex.Lock()
+	defer passkeyCeremonies.mutex.Unlock()
+	
+	ceremony, err := newPasskeyCeremony()
+	if err != nil {
+		return webauthn.CreationOptions{}, err
+	}
+	passkeyCeremonies.registrations[user.Id] = ceremony
+	
+	var exclude []webauthn.Credential
+	for _, passkey := range user.Passkeys {
+		exclude = append(exclude, passkeyCredential(passkey))
+	}
+	return relyingParty.CreationOptions(ceremony.Challenge, passkeyUserHandle(user), user.Username, exclude), nil
+}
+
+// FinishPasskeyRegistration
+//
+//	@Description: verifies the authenticator's response to the pending
+//	registration of the `user` and adds the new passkey to their account.
+//	@param user
+//	@param name: the name given to the passkey, "Passkey" if empty.
+//	@param res
+//	@return error
+func FinishPasskeyRegistration(user server.User, name string, res webauthn.RegistrationResponse) error {
+	passkeyCeremonies.mutex.Lock()
+	ceremony, ok := passkeyCeremonies.registrations[user.Id]
+	delete(passkeyCeremonies.registrations, user.Id)
+	passkeyCeremonies.mutex.Unlock()
+	if !ok || ceremony.ExpirationTime.Before(time.Now()) {
+		return ErrPasskeyCeremony
+	}
+	
+	credential, err := relyingParty.VerifyRegistration(ceremony.Challenge, res)
+	if err != nil {
+		return err
+	}
+	if len(user.Passkeys) >= maxPasskeys {
+		return ErrPasskeyLimit
+	}
+	all, err := users.All()
+	if err != nil {
+		return err
+	}
+	for _, other := range all {
+		if _, found := findPasskey(other, credential.ID); found {
+			return ErrPasskeyTaken
+		}
+	}
+	
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	user.Passkeys = append(user.Passkeys, server.Passkey{
+		ID:         credential.ID,
+		PublicKey:  credential.PublicKey,
+		SignCount:  credential.SignCount,
+		Transports: credential.Transports,
+		Name:       string([]rune(name)[:min(len([]rune(name)), passkeyNameLength)]),
+		CreatedAt:  time.Now(),
+	})
+	UpdateUser(user)
+	Logger.Info("Passkey registered", slog.Int("user_id", user.Id))
+	return nil
+}
+
+// findPasskey
+//
+//	@Description: returns the index of the `user`'s passkey which ID matches
+//	the `id` param.
+//	@param user
+//	@param id
+//	@return int
+//	@return bool
+func findPasskey(user server.User, id []byte) (int, bool) {
+	for i, passkey := range user.Passkeys {
+		if bytes.Equal(passkey.ID, id) {
+			return i, true
+		}
+	}
+	return -1, false
+}
+
+// RemovePasskey
+//
+//	@Description: removes the `user`'s passkey which ID matches the `id`
+//	param. The authenticator keeps it, but it can't be used anymore.
+//	@param user
+//	@param id
+//	@return bool: false if the user has no such passkey.
+func RemovePasskey(user server.User, id []byte) bool {
+	i, ok := findPasskey(user, id)
+	if !ok {
+		return false
+	}
+	user.Passkeys = append(user.Passkeys[:i], user.Passkeys[i+1:]...)
+	UpdateUser(user)
+	Logger.Info("Passkey removed", slog.Int("user_id", user.Id))
+	return true
+}
+
+// BeginPasskeyLogin
+//
+//	@Description: starts a passkey login and writes its cookie.
+//	@param w
+//	@return webauthn.RequestOptions: sent to navigator.credentials.get.
+//	@return error
+func BeginPasskeyLogin(w *http.ResponseWriter) (webauthn.RequestOptions, error) {
+	id, err := randomToken(32)
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+	
+	passkeyCeremonies.mutex.Lock()
+	defer passkeyCeremonies.mutex.Unlock()
+	
+	ceremony, err := newPasskeyCeremony()
+	if err != nil {
+		return webauthn.RequestOptions{}, err
+	}
+	passkeyCeremonies.logins[id] = ceremony
+	http.SetCookie(*w, passkeyLoginCookie(id))
+	return relyingParty.RequestOptions(ceremony.Challenge), nil
+}
+
+// FinishPasskeyLogin
+//
+//	@Description: verifies the authenticator's response to the passkey login
+//	of the *http.Request's cookie. The user is found by the user handle and
+//	must own the passkey, which signature counter and last use are updated.
+//	The login can't be completed twice.
+//	@param w
+//	@param r
+//	@param res
+//	@return models.User: the user to log in.
+//	@return error
+func FinishPasskeyLogin(w *http.ResponseWriter, r *http.Request, res webauthn.AssertionResponse) (server.User, error) {
+	cookie, err := r.Cookie("passkey_login")
+	if err != nil {
+		return server.User{}, ErrPasskeyCeremony
+	}
+	http.SetCookie(*w, passkeyLoginCookie(""))
+	
+	passkeyCeremonies.mutex.Lock()
+	ceremony, ok := passkeyCeremonies.logins[cookie.Value]
+	delete(passkeyCeremonies.logins, cookie.Value)
+	passkeyCeremonies.mutex.Unlock()
+	if !ok || ceremony.ExpirationTime.Before(time.Now()) {
+		return server.User{}, ErrPasskeyCeremony
+	}
+	
+	id, err := strconv.Atoi(string(res.Response.UserHandle))
+	if err != nil {
+		return server.User{}, ErrPasskeyUnknown
+	}
+	user, ok := SelectUserById(id)
+	if !ok {
+		return server.User{}, ErrPasskeyUnknown
+	}
+	i, ok := findPasskey(user, res.ID)
+	if !ok {
+		return server.User{}, ErrPasskeyUnknown
+	}
+	signCount, err := relyingParty.VerifyAssertion(ceremony.Challenge, res, passkeyCredential(user.Passkeys[i]))
+	if err != nil {
+		return server.User{}, err
+	}
+	user.Passkeys[i].SignCount = signCount
+	user.Passkeys[i].LastUsed = time.Now()
+	UpdateUser(user)
+	return user, nil
+}
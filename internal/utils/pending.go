@@ -0,0 +1,424 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// pendingTime is the lifetime of the links sent by mail to confirm an email
+// address or to set a new password.
+const pendingTime = time.Hour * 12
+
+// pendingUsers is the embedded SQLite database keeping the pending
+// models.TempUser (opened by InitPendingUsers): the newly registered
+// models.User before they confirm their email address ("creation"), the
+// models.User that forgot their password until they set a new one ("lost"),
+// the models.User changing their email address until they confirm the new
+// one ("email", sent to the new address) or cancel the change
+// ("email-cancel", sent to the former address), and the models.User logging in
+// with a link sent by mail ("magic").
+var pendingUsers *sql.DB
+
+// InitPendingUsers creates the pending_users table of the embedded SQLite
+// database if it doesn't exist.
+func InitPendingUsers() {
+	db, err := Database()
+	if err == nil {
+		_, err = db.Exec(`CREATE TABLE IF NOT EXISTS pending_users (
+			key TEXT PRIMARY KEY,
+			kind TEXT NOT NULL,
+			username TEXT NOT NULL,
+			email TEXT NOT NULL,
+			expiration INTEGER NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS pending_users_username ON pending_users(username);
+		CREATE INDEX IF NOT EXISTS pending_users_email ON pending_users(email);
+		CREATE INDEX IF NOT EXISTS pending_users_email_nocase ON pending_users(email COLLATE NOCASE);
+		CREATE INDEX IF NOT EXISTS pending_users_expiration ON pending_users(expiration);`)
+	}
+	if err != nil {
+		log.Printf("An error occurred: %s", err.Error())
+		log.Fatalln("Error while opening the pending users!")
+	}
+	pendingUsers = db
+}
+
+// confirmationKey
+//
+//	@Description: returns the key under which the models.TempUser which
+//	ConfirmID matches the `confirmID` param is stored.
+//	@param confirmID
+//	@return string
+func confirmationKey(confirmID string) string {
+	sum := sha256.Sum256([]byte(confirmID))
+	return hex.EncodeToString(sum[:])
+}
+
+// AddTempUser
+//
+//	@Description: stores the models.TempUser `temp` (which ConfirmID has been
+//	sent by mail) until it is confirmed or it expires after pendingTime
+//	(magicLinkTime for the "magic" kind).
+//	@param temp
+//	@param kind: "creation", "lost", "email", "email-cancel" or "magic".
+func AddTempUser(temp server.TempUser, kind string) {
+	if temp.ConfirmID == "" {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("empty confirmation id")))
+		return
+	}
+	key := confirmationKey(temp.ConfirmID)
+	temp.ConfirmID = ""
+	if kind != "creation" {
+		// the credentials of an existing user don't need to be copied
+		temp.User = server.User{Id: temp.User.Id, Username: temp.User.Username, Email: temp.User.Email}
+	}
+	temp.ExpirationTime = temp.CreationTime.Add(pendingTime)
+	if kind == "magic" {
+		temp.ExpirationTime = temp.CreationTime.Add(magicLinkTime)
+	}
+	
+	data, err := json.Marshal(temp)
+	if err == nil {
+		_, err = pendingUsers.Exec("INSERT INTO pending_users (key, kind, username, email, expiration, data) VALUES (?, ?, ?, ?, ?, ?)",
+			key, kind, temp.User.Username, temp.User.Email, temp.ExpirationTime.Unix(), string(data))
+	}
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
+// lookupTempUser
+//
+//	@Description: returns the unexpired models.TempUser of the `kind` which
+//	ConfirmID matches the `confirmID` param.
+//	@param confirmID
+//	@param kind
+//	@return server.TempUser
+//	@return bool
+func lookupTempUser(confirmID string, kind string) (server.TempUser, bool) {
+	var temp server.TempUser
+	if confirmID == "" {
+		return temp, false
+	}
+	
+	var data string
+	err := pendingUsers.QueryRow("SELECT data FROM pending_users WHERE key = ? AND kind = ? AND expiration > ?",
+		confirmationKey(confirmID), kind, time.Now().Unix()).Scan(&data)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), &temp)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return temp, false
+	}
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return temp, false
+	}
+	return temp, true
+}
+
+// takeTempUser
+//
+//	@Description: removes the models.TempUser of the `kind` which ConfirmID
+//	matches the `confirmID` param and returns it if it hasn't expired, so that
+//	each link can only be used once.
+//	@param confirmID
+//	@param kind
+//	@return server.TempUser
+//	@return bool
+func takeTempUser(confirmID string, kind string) (server.TempUser, bool) {
+	var temp server.TempUser
+	if confirmID == "" {
+		return temp, false
+	}
+	
+	var data string
+	err := pendingUsers.QueryRow("DELETE FROM pending_users WHERE key = ? AND kind = ? RETURNING data",
+		confirmationKey(confirmID), kind).Scan(&data)
+	if err == nil {
+		err = json.Unmarshal([]byte(data), &temp)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return temp, false
+	}
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return temp, false
+	}
+	return temp, temp.ExpirationTime.After(time.Now())
+}
+
+// pendingUsername
+//
+//	@Description: checks if the `username` is used by a models.User that hasn't
+//	confirmed its email address yet.
+//	@param username
+//	@return bool
+func pendingUsername(username string) bool {
+	return pendingExists("SELECT 1 FROM pending_users WHERE kind = 'creation' AND username = ? AND expiration > ? LIMIT 1", username)
+}
+
+// pendingEmail
+//
+//	@Description: checks if the `email` is used by a models.User that hasn't
+//	confirmed it yet, at registration or when changing its email address.
+//	@param email
+//	@return bool
+func pendingEmail(email string) bool {
+	return pendingExists("SELECT 1 FROM pending_users WHERE kind IN ('creation', 'email') AND email = ? COLLATE NOCASE AND expiration > ? LIMIT 1", email)
+}
+
+// pendingExists
+//
+//	@Description: checks if the `query` (taking the `value` and the current
+//	time as params) returns a row.
+//	@param query
+//	@param value
+//	@return bool
+func pendingExists(query string, value string) bool {
+	var found int
+	err := pendingUsers.QueryRow(query, value, time.Now().Unix()).Scan(&found)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	return found == 1
+}
+
+// PushTempUser
+//
+//	@Description: creates a new user from the pending models.TempUser which
+//	ConfirmID matches the `id` param.
+//	@param id
+//	@return bool: false if the link is expired or invalid.
+func PushTempUser(id string) bool {
+	temp, ok := takeTempUser(id, "creation")
+	if !ok {
+		return false
+	}
+	temp.User.Id = GetIdNewUser()
+	temp.User.CreationTime = time.Now()
+	temp.User.Avatar = "profile-avatar-059.jpg"
+	CreateUser(temp.User)
+	return true
+}
+
+// CheckLostUser
+//
+//	@Description: checks if the link to set a new password which ConfirmID
+//	matches the `id` param is still valid.
+//	@param id
+//	@return bool
+func CheckLostUser(id string) bool {
+	_, ok := lookupTempUser(id, "lost")
+	return ok
+}
+
+// UpdateLostUser
+//
+//	@Description: sets the `hashedPwd` as the password of the models.User that
+//	forgot it, which models.TempUser's ConfirmID matches the `id` param. Its
+//	other links to set a new password are then invalidated, and all its
+//	sessions and remembered devices are logged out.
+//	@param id
+//	@param hashedPwd
+//	@return bool: false if the link is expired or invalid.
+func UpdateLostUser(id string, hashedPwd string) bool {
+	lost, ok := takeTempUser(id, "lost")
+	if !ok {
+		return false
+	}
+	user, ok := SelectUserById(lost.User.Id)
+	if !ok {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", ErrUserNotFound))
+		return false
+	}
+	user.Salt = ""
+	user.HashedPwd = hashedPwd
+	user.ResetRequired = false
+	UpdateUser(user)
+	// whoever knew the former password mustn't stay logged in
+	RevokeUserSessions(user.Id)
+	
+	_, err := pendingUsers.Exec("DELETE FROM pending_users WHERE kind = 'lost' AND username = ?", user.Username)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	return true
+}
+
+// RequestEmailChange
+//
+//	@Description: sends a link to confirm the `email` to this new address, and a
+//	link to cancel the change to the `user`'s current address. The previous
+//	pending change of the `user`, if any, is replaced, but not the links to
+//	cancel it.
+//	@param user
+//	@param email
+func RequestEmailChange(user server.User, email string) {
+	deletePendingEmailChange(user.Username)
+	
+	var change = server.TempUser{
+		CreationTime: time.Now(),
+		User:         server.User{Id: user.Id, Username: user.Username, Email: email},
+	}
+	SendMail(&change, "email-change")
+	AddTempUser(change, "email")
+	
+	var notice = server.TempUser{
+		CreationTime: change.CreationTime,
+		User:         user,
+	}
+	SendMail(&notice, "email-notice")
+	AddTempUser(notice, "email-cancel")
+}
+
+// CheckEmailChange
+//
+//	@Description: checks if the link to confirm the email address change which
+//	ConfirmID matches the `id` param is still valid.
+//	@param id
+//	@return models.TempUser: the pending change, holding the new address.
+//	@return bool
+func CheckEmailChange(id string) (server.TempUser, bool) {
+	return lookupTempUser(id, "email")
+}
+
+// ConfirmEmailChange
+//
+//	@Description: sets the new email address of the models.User which pending
+//	change's ConfirmID matches the `id` param. The link sent to the former
+//	address can still cancel the change until it expires.
+//	@param id
+//	@return bool: false if the link is expired or invalid, or if the address
+//	has been taken by another models.User meanwhile.
+func ConfirmEmailChange(id string) bool {
+	change, ok := takeTempUser(id, "email")
+	if !ok {
+		return false
+	}
+	deletePendingEmailChange(change.User.Username)
+	
+	user, ok := SelectUserById(change.User.Id)
+	if !ok || user.Username != change.User.Username {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", ErrUserNotFound))
+		return false
+	}
+	if exists, _ := EmailExists(change.User.Email); exists {
+		return false
+	}
+	Logger.Info("Email changed", slog.Int("user_id", user.Id), slog.String("former_email", user.Email), slog.String("email", change.User.Email))
+	user.Email = change.User.Email
+	UpdateUser(user)
+	return true
+}
+
+// CheckEmailCancel
+//
+//	@Description: checks if the link to cancel the email address change which
+//	notice's ConfirmID matches the `id` param is still valid.
+//	@param id
+//	@return models.TempUser: the notice, holding the former address.
+//	@return bool
+func CheckEmailCancel(id string) (server.TempUser, bool) {
+	return lookupTempUser(id, "email-cancel")
+}
+
+// CancelEmailChange
+//
+//	@Description: cancels the email address change which notice's ConfirmID
+//	matches the `id` param: the pending change is dropped, or the former
+//	address is restored if the change has already been confirmed. Since the
+//	change may come from someone who knows the password, all the sessions of
+//	the models.User are logged out.
+//	@param id
+//	@return bool: false if the link is expired or invalid, or if the former
+//	address has been taken by another models.User meanwhile.
+func CancelEmailChange(id string) bool {
+	notice, ok := takeTempUser(id, "email-cancel")
+	if !ok {
+		return false
+	}
+	deletePendingEmailChange(notice.User.Username)
+	// the later notices would restore an address set after this one
+	_, err := pendingUsers.Exec("DELETE FROM pending_users WHERE kind = 'email-cancel' AND username = ? AND expiration >= ?",
+		notice.User.Username, notice.ExpirationTime.Unix())
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	
+	user, ok := SelectUserById(notice.User.Id)
+	if !ok || user.Username != notice.User.Username {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", ErrUserNotFound))
+		return false
+	}
+	if user.Email != notice.User.Email {
+		if exists, other := EmailExists(notice.User.Email); exists && other.Id != user.Id {
+			Logger.Warn("Email change not cancelled: the former address is taken", slog.Int("user_id", user.Id))
+			return false
+		}
+		Logger.Info("Email restored", slog.Int("user_id", user.Id), slog.String("former_email", user.Email), slog.String("email", notice.User.Email))
+		user.Email = notice.User.Email
+		UpdateUser(user)
+	}
+	RevokeUserSessions(user.Id)
+	Logger.Info("Email change cancelled", slog.Int("user_id", notice.User.Id))
+	return true
+}
+
+// deletePendingEmailChange
+//
+//	@Description: removes the email address change of the models.User which
+//	username matches the `username` param waiting for its confirmation, if
+//	any. The links to cancel the former changes stay valid.
+//	@param username
+func deletePendingEmailChange(username string) {
+	_, err := pendingUsers.Exec("DELETE FROM pending_users WHERE kind = 'email' AND username = ?", username)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
+// ManageTempUsers
+// is a goroutine that periodically removes the expired models.TempUser.
+func ManageTempUsers() {
+	time.Sleep(time.Second * 10)
+	duration := SetDailyTimer(0)
+	for {
+		Logger.Info(GetCurrentFuncName(), slog.String("goroutine", "ManageTempUsers"))
+		rows, err := pendingUsers.Query("DELETE FROM pending_users WHERE expiration <= ? RETURNING kind, username", time.Now().Unix())
+		if err != nil {
+			Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		} else {
+			for rows.Next() {
+				var kind, username string
+				err = rows.Scan(&kind, &username)
+				if err != nil {
+					Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+					continue
+				}
+				switch kind {
+				case "creation":
+					Logger.Info("TempUser cleared automatically", slog.String("username", username))
+				case "lost":
+					Logger.Info("LostUser cleared automatically", slog.String("username", username))
+				case "magic":
+					Logger.Info("Magic link cleared automatically", slog.String("username", username))
+				default:
+					Logger.Info("Email change cleared automatically", slog.String("username", username))
+				}
+			}
+			rows.Close()
+		}
+		time.Sleep(duration)
+		duration = time.Hour * 12
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// maxReadings is the maximum number of mangas kept in a models.User's reading
+// progress list.
+const maxReadings = 50
+
+// UpdateReadingProgress
+//
+//	@Description: records the reading `progress` of the models.User which username
+//	matches the `username` param. The matching favorite and banner are updated as well.
+//	@param username
+//	@param progress
+func UpdateReadingProgress(username string, progress server.MangaUser) {
+	if progress.Id == "" || progress.LastChapterRead == "" {
+		return
+	}
+	
+	if progress.LastReadTime.IsZero() {
+		progress.LastReadTime = time.Now()
+	}
+	
+	_, ok := ModifyUser(username, func(user *server.User) bool {
+		// putting the manga on top of the list (most recent first)
+		readings := []server.MangaUser{progress}
+		for _, reading := range user.Readings {
+			if reading.Id != progress.Id && len(readings) < maxReadings {
+				readings = append(readings, reading)
+			}
+		}
+		user.Readings = readings
+		
+		for i, favorite := range user.Favorites {
+			if favorite.Id == progress.Id {
+				user.Favorites[i] = progress
+			}
+		}
+		if user.MangaBanner.Id == progress.Id {
+			user.MangaBanner = progress
+		}
+		return true
+	})
+	if !ok {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+	}
+}
+
+// ReadingProgress
+//
+//	@Description: returns the reading progress of the `user` for the manga which
+//	id matches the `mangaId` param.
+//	@param user
+//	@param mangaId
+//	@return models.MangaUser
+//	@return bool
+func ReadingProgress(user server.User, mangaId string) (server.MangaUser, bool) {
+	for _, reading := range user.Readings {
+		if reading.Id == mangaId {
+			return reading, true
+		}
+	}
+	return server.MangaUser{}, false
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// rememberTime is the lifetime of a "remember me" login.
+const rememberTime time.Duration = time.Hour * 24 * 30
+
+// rememberCookie
+//
+//	@Description: creates the remember_me cookie.
+//	@param value: "<selector>:<validator>", or "" to delete the cookie.
+//	@param expirationTime
+//	@return *http.Cookie
+func rememberCookie(value string, expirationTime time.Time) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     "remember_me",
+		Value:    value,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/",
+		Expires:  expirationTime,
+		SameSite: http.SameSiteStrictMode,
+	}
+	if value == "" {
+		cookie.Expires = time.Time{}
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// randomToken
+//
+//	@Description: generates a random string of `size` bytes (URL encoded).
+//	@param size
+//	@return string
+//	@return error
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashValidator
+//
+//	@Description: returns the hash stored for the validator of a
+//	models.RememberToken.
+//	@param validator
+//	@return string
+func hashValidator(validator string) string {
+	sum := sha256.Sum256([]byte(validator))
+	return hex.EncodeToString(sum[:])
+}
+
+// RememberUser
+//
+//	@Description: creates a models.RememberToken for the models.Session found
+//	in the *http.Request and writes its remember_me cookie, so that the user
+//	stays logged in on this device for rememberTime.
+//	@param w
+//	@param r
+func RememberUser(w *http.ResponseWriter, r *http.Request) {
+	session, sessionID := GetSession(r)
+	if sessionID == "" {
+		return
+	}
+	
+	selector, err := randomToken(16)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return
+	}
+	validator, err := randomToken(32)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return
+	}
+	
+	token := server.RememberToken{
+		Selector:       selector,
+		ValidatorHash:  hashValidator(validator),
+		UserID:         session.UserID,
+		ExpirationTime: time.Now().Add(rememberTime),
+	}
+	err = sessions.SetToken(token)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return
+	}
+	
+	session.RememberSelector = selector
+	storeSession(sessionID, session)
+	http.SetCookie(*w, rememberCookie(selector+":"+validator, token.ExpirationTime))
+}
+
+// ResumeSession
+//
+//	@Description: opens a new models.Session from the remember_me cookie found
+//	in the *http.Request, if its models.RememberToken is valid, and rotates
+//	its validator. A wrong validator revokes all the user's sessions and
+//	tokens.
+//	@param w
+//	@param r
+//	@return *http.Request: holding the new session ID.
+//	@return bool: false if the client can't be logged in.
+func ResumeSession(w *http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	cookie, err := r.Cookie("remember_me")
+	if err != nil {
+		return r, false
+	}
+	
+	selector, validator, found := strings.Cut(cookie.Value, ":")
+	token, ok, err := sessions.GetToken(selector)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	if !found || !ok || token.ExpirationTime.Before(time.Now()) {
+		forgetDevice(w)
+		return r, false
+	}
+	hash := []byte(hashValidator(validator))
+	// the previous validator is still sent by the requests racing the rotation
+	previous := subtle.ConstantTimeCompare(hash, []byte(token.PreviousValidatorHash)) == 1 &&
+		time.Since(token.RotatedAt) < sessionGraceTime
+	if subtle.ConstantTimeCompare(hash, []byte(token.ValidatorHash)) != 1 && !previous {
+		// the selector is right but not the validator: the cookie was stolen
+		// and already used by someone else (or by its owner, after the
+		// thief), so the user is logged out of all their devices.
+		Logger.Warn("Stolen remember me token", slog.Int("user_id", token.UserID), slog.String("client_ip", GetIP(r)))
+		RevokeUserSessions(token.UserID)
+		forgetDevice(w)
+		return r, false
+	}
+	
+	user, ok := SelectUserById(token.UserID)
+	if !ok {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
+		deleteToken(selector)
+		forgetDevice(w)
+		return r, false
+	}
+	if !CanLogin(user) {
+		deleteToken(selector)
+		forgetDevice(w)
+		return r, false
+	}
+	
+	if previous {
+		// the device already got the new validator
+		return openSession(w, user, r, selector), true
+	}
+	
+	// a new validator is issued on every use, so that a stolen cookie only
+	// works until either its owner or the thief uses it
+	validator, err = randomToken(32)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return r, false
+	}
+	token.PreviousValidatorHash = token.ValidatorHash
+	token.RotatedAt = time.Now()
+	token.ValidatorHash = hashValidator(validator)
+	err = sessions.SetToken(token)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return r, false
+	}
+	http.SetCookie(*w, rememberCookie(selector+":"+validator, token.ExpirationTime))
+	return openSession(w, user, r, selector), true
+}
+
+// forgetDevice
+//
+//	@Description: deletes the remember_me cookie of the client.
+//	@param w
+func forgetDevice(w *http.ResponseWriter) {
+	http.SetCookie(*w, rememberCookie("", time.Time{}))
+}
+
+// deleteToken
+//
+//	@Description: removes the models.RememberToken which selector is `selector`
+//	(if any) from the store.
+//	@param selector
+func deleteToken(selector string) {
+	if selector == "" {
+		return
+	}
+	err := sessions.DeleteToken(selector)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
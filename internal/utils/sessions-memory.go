@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"sync"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// memorySessionStore is the SessionStore keeping the models.Session in memory
+// only: they are lost when the server stops.
+type memorySessionStore struct {
+	mutex    sync.RWMutex
+	sessions map[string]server.Session
+	tokens   map[string]server.RememberToken
+}
+
+// newMemorySessionStore
+//
+//	@Description: returns an empty memorySessionStore.
+//	@return *memorySessionStore
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{
+		sessions: make(map[string]server.Session),
+		tokens:   make(map[string]server.RememberToken),
+	}
+}
+
+func (store *memorySessionStore) Get(key string) (server.Session, bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	
+	session, ok := store.sessions[key]
+	return session, ok, nil
+}
+
+func (store *memorySessionStore) Set(key string, session server.Session) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	store.sessions[key] = session
+	return nil
+}
+
+func (store *memorySessionStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	delete(store.sessions, key)
+	return nil
+}
+
+func (store *memorySessionStore) All() (map[string]server.Session, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	
+	sessions := make(map[string]server.Session, len(store.sessions))
+	for key, session := range store.sessions {
+		sessions[key] = session
+	}
+	return sessions, nil
+}
+
+func (store *memorySessionStore) DeleteExpired(now time.Time) ([]server.Session, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	var expired []server.Session
+	for key, session := range store.sessions {
+		if session.ExpirationTime.Before(now) {
+			expired = append(expired, session)
+			delete(store.sessions, key)
+		}
+	}
+	return expired, nil
+}
+
+func (store *memorySessionStore) GetToken(selector string) (server.RememberToken, bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	
+	token, ok := store.tokens[selector]
+	return token, ok, nil
+}
+
+func (store *memorySessionStore) SetToken(token server.RememberToken) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	store.tokens[token.Selector] = token
+	return nil
+}
+
+func (store *memorySessionStore) DeleteToken(selector string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	delete(store.tokens, selector)
+	return nil
+}
+
+func (store *memorySessionStore) DeleteUserTokens(userID int, keep string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	for selector, token := range store.tokens {
+		if token.UserID == userID && selector != keep {
+			delete(store.tokens, selector)
+		}
+	}
+	return nil
+}
+
+func (store *memorySessionStore) DeleteExpiredTokens(now time.Time) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	for selector, token := range store.tokens {
+		if token.ExpirationTime.Before(now) {
+			delete(store.tokens, selector)
+		}
+	}
+	return nil
+}
+
+func (store *memorySessionStore) Close() error {
+	return nil
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// sqliteSessionStore is the SessionStore keeping the models.Session and
+// models.RememberToken in the embedded SQLite database, so that they survive
+// the server's restarts. The columns used to filter them are indexed, the
+// whole structures are stored as JSON in the data columns.
+type sqliteSessionStore struct {
+	db *sql.DB
+}
+
+// newSQLiteSessionStore
+//
+//	@Description: creates the sessions and remember_tokens tables if they don't
+//	exist and returns their SessionStore.
+//	@param db
+//	@return *sqliteSessionStore
+//	@return error
+func newSQLiteSessionStore(db *sql.DB) (*sqliteSessionStore, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		key TEXT PRIMARY KEY,
+		expiration INTEGER NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS sessions_expiration ON sessions(expiration);
+	CREATE TABLE IF NOT EXISTS remember_tokens (
+		selector TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		expiration INTEGER NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS remember_tokens_user_id ON remember_tokens(user_id);
+	CREATE INDEX IF NOT EXISTS remember_tokens_expiration ON remember_tokens(expiration);`)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteSessionStore{db: db}, nil
+}
+
+func (store *sqliteSessionStore) Get(key string) (server.Session, bool, error) {
+	var session server.Session
+	var data string
+	
+	err := store.db.QueryRow("SELECT data FROM sessions WHERE key = ?", key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session, false, nil
+	}
+	if err != nil {
+		return session, false, err
+	}
+	
+	err = json.Unmarshal([]byte(data), &session)
+	if err != nil {
+		return session, false, err
+	}
+	return session, true, nil
+}
+
+func (store *sqliteSessionStore) Set(key string, session server.Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = store.db.Exec(`INSERT INTO sessions (key, expiration, data) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET expiration = excluded.expiration, data = excluded.data`,
+		key, session.ExpirationTime.Unix(), string(data))
+	return err
+}
+
+func (store *sqliteSessionStore) Delete(key string) error {
+	_, err := store.db.Exec("DELETE FROM sessions WHERE key = ?", key)
+	return err
+}
+
+func (store *sqliteSessionStore) All() (map[string]server.Session, error) {
+	rows, err := store.db.Query("SELECT key, data FROM sessions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	sessions := make(map[string]server.Session)
+	for rows.Next() {
+		var key, data string
+		var session server.Session
+		err = rows.Scan(&key, &data)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(data), &session)
+		if err != nil {
+			return nil, err
+		}
+		sessions[key] = session
+	}
+	return sessions, rows.Err()
+}
+
+func (store *sqliteSessionStore) DeleteExpired(now time.Time) ([]server.Session, error) {
+	rows, err := store.db.Query("DELETE FROM sessions WHERE expiration < ? RETURNING data", now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var expired []server.Session
+	for rows.Next() {
+		var data string
+		var session server.Session
+		err = rows.Scan(&data)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(data), &session)
+		if err != nil {
+			return nil, err
+		}
+		expired = append(expired, session)
+	}
+	return expired, rows.Err()
+}
+
+func (store *sqliteSessionStore) GetToken(selector string) (server.RememberToken, bool, error) {
+	var token server.RememberToken
+	var data string
+	
+	err := store.db.QueryRow("SELECT data FROM remember_tokens WHERE selector = ?", selector).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return token, false, nil
+	}
+	if err != nil {
+		return token, false, err
+	}
+	
+	err = json.Unmarshal([]byte(data), &token)
+	if err != nil {
+		return token, false, err
+	}
+	return token, true, nil
+}
+
+func (store *sqliteSessionStore) SetToken(token server.RememberToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	_, err = store.db.Exec(`INSERT INTO remember_tokens (selector, user_id, expiration, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT(selector) DO UPDATE SET user_id = excluded.user_id, expiration = excluded.expiration, data = excluded.data`,
+		token.Selector, token.UserID, token.ExpirationTime.Unix(), string(data))
+	return err
+}
+
+func (store *sqliteSessionStore) DeleteToken(selector string) error {
+	_, err := store.db.Exec("DELETE FROM remember_tokens WHERE selector = ?", selector)
+	return err
+}
+
+func (store *sqliteSessionStore) DeleteUserTokens(userID int, keep string) error {
+	_, err := store.db.Exec("DELETE FROM remember_tokens WHERE user_id = ? AND selector != ?", userID, keep)
+	return err
+}
+
+func (store *sqliteSessionStore) DeleteExpiredTokens(now time.Time) error {
+	_, err := store.db.Exec("DELETE FROM remember_tokens WHERE expiration < ?", now.Unix())
+	return err
+}
+
+// Close doesn't close the database, which is shared with the other stores.
+func (store *sqliteSessionStore) Close() error {
+	return nil
+}
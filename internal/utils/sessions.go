@@ -1,47 +1,198 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
 	"log/slog"
 	"net/http"
+	"os"
 	"time"
 	
 	"mangathorg/internal/models/server"
 )
 
-// SessionsData is an in-memory models.Session data storage.
-var SessionsData = make(map[string]server.Session)
+// sessions is the SessionStore selected at startup by InitSessions.
+var sessions SessionStore
+
+// SessionStore is the storage backend of the models.Session and of the
+// models.RememberToken. The models.Session are stored under the sessionKey of
+// their session ID, so that a leak of the store doesn't give access to the
+// users' sessions.
+type SessionStore interface {
+	Get(key string) (server.Session, bool, error)
+	Set(key string, session server.Session) error
+	Delete(key string) error
+	All() (map[string]server.Session, error)
+	// DeleteExpired removes and returns the models.Session expired at `now`.
+	DeleteExpired(now time.Time) ([]server.Session, error)
+	GetToken(selector string) (server.RememberToken, bool, error)
+	SetToken(token server.RememberToken) error
+	DeleteToken(selector string) error
+	// DeleteUserTokens removes all the models.RememberToken of the user, except
+	// the `keep` one.
+	DeleteUserTokens(userID int, keep string) error
+	DeleteExpiredTokens(now time.Time) error
+	Close() error
+}
 
 // sessionTime is the constant handling the session's maximum opened time without interaction.
 const sessionTime time.Duration = time.Hour * 2
 
+// sessionGraceTime is the time during which the previous session ID stays
+// valid after a rotation.
+const sessionGraceTime time.Duration = time.Second * 30
+
+// OpenSessionStore
+//
+//	@Description: opens the SessionStore matching the `backend` param ("sqlite"
+//	or "memory").
+//	@param backend
+//	@return SessionStore
+//	@return error
+func OpenSessionStore(backend string) (SessionStore, error) {
+	switch backend {
+	case "", "sqlite":
+		db, err := Database()
+		if err != nil {
+			return nil, err
+		}
+		return newSQLiteSessionStore(db)
+	case "memory":
+		return newMemorySessionStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown session store %q", backend)
+	}
+}
+
+// InitSessions opens the SessionStore set in the SESSION_STORE environment
+// variable (sqlite by default). It must be called after InitUsers, which
+// creates the data directory.
+func InitSessions() {
+	store, err := OpenSessionStore(os.Getenv("SESSION_STORE"))
+	if err != nil {
+		log.Printf("An error occurred: %s", err.Error())
+		log.Fatalln("Error while opening the session store!")
+	}
+	sessions = store
+}
+
+// sessionKey
+//
+//	@Description: returns the key under which the models.Session of the
+//	`sessionID` is stored.
+//	@param sessionID
+//	@return string
+func sessionKey(sessionID string) string {
+	sum := sha256.Sum256([]byte(sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+// LookupSession
+//
+//	@Description: fetches the models.Session of the `sessionID`.
+//	@param sessionID
+//	@return models.Session
+//	@return bool
+func LookupSession(sessionID string) (server.Session, bool) {
+	session, ok, err := sessions.Get(sessionKey(sessionID))
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	return session, ok
+}
+
+// storeSession
+//
+//	@Description: saves the models.Session of the `sessionID`.
+//	@param sessionID
+//	@param session
+func storeSession(sessionID string, session server.Session) {
+	err := sessions.Set(sessionKey(sessionID), session)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
+// deleteSession
+//
+//	@Description: removes the models.Session of the `sessionID`.
+//	@param sessionID
+func deleteSession(sessionID string) {
+	err := sessions.Delete(sessionKey(sessionID))
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+}
+
 // retrieveSessions
 //
-//	@Description: fetches all sessions present in SessionsData.
+//	@Description: fetches all stored sessions.
 //	@return []models.Session
 func retrieveSessions() []server.Session {
-	var sessions []server.Session
-	for _, session := range SessionsData {
-		sessions = append(sessions, session)
+	all, err := sessions.All()
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	return sessions
+	var list []server.Session
+	for _, session := range all {
+		list = append(list, session)
+	}
+	return list
+}
+
+// sessionContextKey is the key of the session ID in the requests' context.
+type sessionContextKey struct{}
+
+// withSessionID
+//
+//	@Description: returns a shallow copy of the *http.Request which context
+//	holds the `sessionID`, for further access from the handlers.
+//	@param r
+//	@param sessionID
+//	@return *http.Request
+func withSessionID(r *http.Request, sessionID string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, sessionID))
 }
 
 // GetSession
 //
-//	@Description: fetches the models.Session and sessionId from the cookie present
-//	in the *http.Request.
+//	@Description: fetches the models.Session and sessionId put in the
+//	*http.Request's context by the session middlewares.
 //	@param r
 //	@return models.Session
 //	@return string
 func GetSession(r *http.Request) (server.Session, string) {
-	sessionID, err := r.Cookie("updatedCookie")
-	if err != nil {
-		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	sessionID, ok := r.Context().Value(sessionContextKey{}).(string)
+	if !ok {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("no session in the request's context")))
 		return server.Session{}, ""
 	}
-	return SessionsData[sessionID.Value], sessionID.Value
+	session, _ := LookupSession(sessionID)
+	return session, sessionID
+}
+
+// sessionCookie
+//
+//	@Description: creates the session_id cookie.
+//	@param sessionID
+//	@param expirationTime
+//	@return *http.Cookie
+func sessionCookie(sessionID string, expirationTime time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     "session_id",
+		Value:    sessionID,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/",
+		Expires:  expirationTime,
+		SameSite: http.SameSiteStrictMode,
+	}
 }
 
 // newConnectionID
@@ -49,12 +200,12 @@ func GetSession(r *http.Request) (server.Session, string) {
 //	@Description: gets the first unused ConnectionID for the new models.Session.
 //	@return int
 func newConnectionID() int {
-	sessions := retrieveSessions()
+	all := retrieveSessions()
 	var id int
 	var idFound bool
 	for id = 1; !idFound; id++ {
 		idFound = true
-		for _, session := range sessions {
+		for _, session := range all {
 			if session.ConnectionID == id {
 				idFound = false
 			}
@@ -67,48 +218,58 @@ func newConnectionID() int {
 // OpenSession
 //
 //	@Description: creates a new models.Session for the user which username matches
-//	the username param and writes the cookie that corresponds to its
-//	models.Session in the *http.ResponseWriter and *http.Request (for further
-//	access).
+//	the username param, writes its cookie in the *http.ResponseWriter and
+//	returns the *http.Request holding its session ID (for further access).
 //	@param w
 //	@param username
 //	@param r
-func OpenSession(w *http.ResponseWriter, username string, r *http.Request) {
+//	@return *http.Request
+func OpenSession(w *http.ResponseWriter, username string, r *http.Request) *http.Request {
+	user, _ := SelectUser(username)
+	return openSession(w, user, r, "")
+}
+
+// openSession
+//
+//	@Description: creates a new models.Session for the `user`, opened with the
+//	models.RememberToken which selector is `rememberSelector` (if any).
+//	@param w
+//	@param user
+//	@param r
+//	@param rememberSelector
+//	@return *http.Request
+func openSession(w *http.ResponseWriter, user server.User, r *http.Request, rememberSelector string) *http.Request {
 	
 	// Generate and set Session ID cookie
 	sessionID := generateSessionID()
 	// Generate expiration time for the cookie
-	expirationTime := time.Now().Add(time.Hour * 2)
+	now := time.Now()
+	expirationTime := now.Add(sessionTime)
 	
-	newCookie := &http.Cookie{
-		Name:     "session_id",
-		Value:    sessionID,
-		Path:     "/",
-		Expires:  expirationTime,
-		Secure:   false,
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	}
+	http.SetCookie(*w, sessionCookie(sessionID, expirationTime))
 	
-	http.SetCookie(*w, newCookie)
-	r.AddCookie(newCookie)
-	
-	user, _ := SelectUser(username)
-	
-	// Update the last connection time in `users.json`.
-	user.LastConnection = time.Now()
-	UpdateUser(user)
+	// Update the last connection time in the UserStore.
+	ModifyUser(user.Username, func(stored *server.User) bool {
+		stored.LastConnection = now
+		return true
+	})
 	
-	// Create Session data in memory
-	SessionsData[sessionID] = server.Session{
-		UserID:         user.Id,
-		ConnectionID:   newConnectionID(),
-		Username:       username,
-		IpAddress:      GetIP(r),
-		ExpirationTime: expirationTime,
+	// Create Session data in the store
+	session := server.Session{
+		UserID:           user.Id,
+		ConnectionID:     newConnectionID(),
+		Username:         user.Username,
+		IpAddress:        GetIP(r),
+		ExpirationTime:   expirationTime,
+		UserAgent:        r.UserAgent(),
+		CreatedAt:        now,
+		LastSeen:         now,
+		RememberSelector: rememberSelector,
 	}
+	storeSession(sessionID, session)
 	
-	Logger.Info("Login", slog.Any("user", SessionsData[sessionID]))
+	Logger.Info("Login", slog.Any("user", session))
+	return withSessionID(r, sessionID)
 }
 
 // CheckSession checks if there is a cookie in the request
@@ -121,7 +282,7 @@ func CheckSession(r *http.Request) bool {
 		return false
 	}
 	// Retrieve user data from session
-	session, ok := SessionsData[cookie.Value]
+	session, ok := LookupSession(cookie.Value)
 	if !ok {
 		return false
 	}
@@ -131,9 +292,9 @@ func CheckSession(r *http.Request) bool {
 	}
 	// Verify expiration time
 	if session.ExpirationTime.Before(time.Now()) {
-		Logger.Info("Logout", slog.Any("user", SessionsData[cookie.Value]))
-		// deleting previous entry in the SessionsData map
-		delete(SessionsData, cookie.Value)
+		Logger.Info("Logout", slog.Any("user", session))
+		// deleting the expired session from the store
+		deleteSession(cookie.Value)
 		return false
 	}
 	return true
@@ -141,59 +302,78 @@ func CheckSession(r *http.Request) bool {
 
 // RefreshSession
 //
-//	@Description: refreshes the cookie and the sessionId found in the
-//	*http.Request and adds an "updatedCookie" in the *http.Request for further
-//	access.
+//	@Description: extends the expiration time of the models.Session found in
+//	the *http.Request (keeping its session ID) and returns the *http.Request
+//	holding its session ID for further access. The previous session ID of a
+//	rotated models.Session is not extended.
 //	@param w
 //	@param r
+//	@return *http.Request
 //	@return error
-func RefreshSession(w *http.ResponseWriter, r *http.Request) error {
-	// generating new sessionID and new expiration time
-	newSessionID := generateSessionID()
-	newExpirationTime := time.Now().Add(sessionTime)
-	
-	var newCookie = &http.Cookie{
-		Name:     "session_id",
-		Value:    newSessionID,
-		HttpOnly: true,
-		Secure:   false, // TODO: change when switching to HTTPS in the future.
-		Path:     "/",
-		Expires:  newExpirationTime,
-		SameSite: http.SameSiteStrictMode,
+func RefreshSession(w *http.ResponseWriter, r *http.Request) (*http.Request, error) {
+	cookie, err := r.Cookie("session_id")
+	if err != nil {
+		return r, err
 	}
 	
-	// setting the new cookie
-	http.SetCookie(*w, newCookie)
-	
-	// retrieving the in-memory current session data
-	cookie, err := r.Cookie("session_id")
-	currentSessionData := SessionsData[cookie.Value]
+	session, ok := LookupSession(cookie.Value)
+	if !ok {
+		return r, errors.New("session not found")
+	}
 	
-	// updating the sessionID and expirationTime
-	currentSessionData.ExpirationTime = newExpirationTime
+	if !session.Rotated {
+		// sliding the expiration time
+		session.LastSeen = time.Now()
+		session.ExpirationTime = session.LastSeen.Add(sessionTime)
+		err = sessions.Set(sessionKey(cookie.Value), session)
+		if err != nil {
+			return r, err
+		}
+		http.SetCookie(*w, sessionCookie(cookie.Value, session.ExpirationTime))
+	}
 	
-	// deleting previous entry in the SessionsData map
-	delete(SessionsData, cookie.Value)
+	return withSessionID(r, cookie.Value), nil
+}
+
+// RotateSession
+//
+//	@Description: moves the models.Session found in the *http.Request to a new
+//	session ID (after a password or privilege change). The previous session ID
+//	stays valid for sessionGraceTime, for the requests sent in parallel.
+//	@param w
+//	@param r
+//	@return *http.Request: holding the new session ID.
+func RotateSession(w *http.ResponseWriter, r *http.Request) *http.Request {
+	session, previousID := GetSession(r)
+	if previousID == "" {
+		return r
+	}
 	
-	// setting the new entry in the SessionsData map
-	SessionsData[newSessionID] = currentSessionData
+	newSessionID := generateSessionID()
+	session.LastSeen = time.Now()
+	session.ExpirationTime = session.LastSeen.Add(sessionTime)
+	storeSession(newSessionID, session)
+	http.SetCookie(*w, sessionCookie(newSessionID, session.ExpirationTime))
 	
-	// adding the new cookie to the request to access it from the targeted handler with the Name "updatedCookie"
-	newCookie.Name = "updatedCookie"
-	r.AddCookie(newCookie)
+	// keeping the previous session ID until the end of the grace window
+	previous := session
+	previous.Rotated = true
+	previous.ExpirationTime = time.Now().Add(sessionGraceTime)
+	storeSession(previousID, previous)
 	
-	if err != nil {
-		return err
-	}
-	return nil
+	Logger.Info("Session rotated", slog.Any("user", session))
+	return withSessionID(r, newSessionID)
 }
 
 // Logout
 //
-//	@Description: sets the cookie as expired and clears the SessionsData.
+//	@Description: sets the cookies as expired and clears the models.Session
+//	(and its models.RememberToken) from the store.
 //	@param w
 //	@param r
 func Logout(w *http.ResponseWriter, r *http.Request) {
+	forgetDevice(w)
+	
 	var newCookie = &http.Cookie{
 		Name:     "session_id",
 		Value:    "",
@@ -207,13 +387,16 @@ func Logout(w *http.ResponseWriter, r *http.Request) {
 	// setting the new cookie
 	http.SetCookie(*w, newCookie)
 	
-	// retrieving the in-memory current session data
-	cookie, _ := r.Cookie("updatedCookie")
+	session, sessionID := GetSession(r)
+	if sessionID == "" {
+		return
+	}
 	
-	Logger.Info("Logout", slog.Any("user", SessionsData[cookie.Value]))
+	Logger.Info("Logout", slog.Any("user", session))
 	
-	// deleting previous entry in the SessionsData map
-	delete(SessionsData, cookie.Value)
+	// deleting the session from the store
+	deleteSession(sessionID)
+	deleteToken(session.RememberSelector)
 }
 
 // generateSessionID
@@ -235,28 +418,25 @@ func generateSessionID() string {
 //	@param sessionID
 //	@return bool
 func validateSessionID(sessionID string) bool {
-	_, ok := SessionsData[sessionID]
-	return len(sessionID) == 88 && ok
-}
-
-// isExpired
-//
-//	@Description: checks if the models.Session is expired.
-//	@param session
-//	@return bool
-func isExpired(session server.Session) bool {
-	return session.ExpirationTime.Before(time.Now())
+	return len(sessionID) == 88
 }
 
 // cleanSessions
 //
-//	@Description: clears all expired models.Session from SessionsData.
+//	@Description: clears all expired models.Session and models.RememberToken
+//	from the store.
 func cleanSessions() {
-	for sessionID, session := range SessionsData {
-		if isExpired(session) {
-			Logger.Info("Session cleared automatically", slog.Any("user", session))
-			delete(SessionsData, sessionID)
-		}
+	expired, err := sessions.DeleteExpired(time.Now())
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+	}
+	for _, session := range expired {
+		Logger.Info("Session cleared automatically", slog.Any("user", session))
+	}
+	
+	err = sessions.DeleteExpiredTokens(time.Now())
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
 }
 
@@ -0,0 +1,311 @@
+package utils
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// throttler limits the attempts made for a key (an IP address, an account...).
+// Once `limit` attempts have failed within `window`, each new failure blocks the
+// key for an exponential delay (`baseDelay`, doubled at each failure, up to
+// `maxDelay`). After `lockoutAfter` failures, the key is locked out for
+// `lockoutTime`.
+type throttler struct {
+	name         string
+	limit        int
+	window       time.Duration
+	baseDelay    time.Duration
+	maxDelay     time.Duration
+	lockoutAfter int
+	lockoutTime  time.Duration
+	
+	mutex   sync.Mutex
+	entries map[string]*throttleEntry
+}
+
+// throttleEntry is the record of the failed attempts of a key.
+type throttleEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	locked       bool
+}
+
+// newThrottler
+//
+//	@Description: creates a throttler (see its description for the params).
+//	@return *throttler
+func newThrottler(name string, limit int, window, baseDelay, maxDelay time.Duration, lockoutAfter int, lockoutTime time.Duration) *throttler {
+	return &throttler{
+		name:         name,
+		limit:        limit,
+		window:       window,
+		baseDelay:    baseDelay,
+		maxDelay:     maxDelay,
+		lockoutAfter: lockoutAfter,
+		lockoutTime:  lockoutTime,
+		entries:      make(map[string]*throttleEntry),
+	}
+}
+
+// allow
+//
+//	@Description: checks if the `key` is allowed to make an attempt.
+//	@receiver t
+//	@param key
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func (t *throttler) allow(key string) (time.Duration, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	entry, ok := t.entries[key]
+	if !ok {
+		return 0, true
+	}
+	wait := time.Until(entry.blockedUntil)
+	if wait > 0 {
+		return wait, false
+	}
+	return 0, true
+}
+
+// fail
+//
+//	@Description: records a failed attempt of the `key`.
+//	@receiver t
+//	@param key
+//	@return bool: true if the key has just been locked out.
+func (t *throttler) fail(key string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	now := time.Now()
+	t.clean(now)
+	
+	entry, ok := t.entries[key]
+	if !ok {
+		entry = &throttleEntry{}
+		t.entries[key] = entry
+	}
+	if entry.locked && now.After(entry.blockedUntil) {
+		// the lockout is over: the next failures are delayed again
+		*entry = throttleEntry{failures: t.limit - 1}
+	}
+	entry.failures++
+	entry.lastFailure = now
+	
+	if entry.failures >= t.lockoutAfter {
+		if entry.locked {
+			return false
+		}
+		entry.locked = true
+		entry.blockedUntil = now.Add(t.lockoutTime)
+		Logger.Warn("Lockout", slog.String("throttle", t.name), slog.String("key", key), slog.Int("failures", entry.failures), slog.Duration("duration", t.lockoutTime))
+		return true
+	}
+	if entry.failures >= t.limit {
+		delay := time.Duration(float64(t.baseDelay) * math.Pow(2, float64(entry.failures-t.limit)))
+		entry.blockedUntil = now.Add(min(delay, t.maxDelay))
+	}
+	return false
+}
+
+// reset
+//
+//	@Description: forgets the failed attempts of the `key`.
+//	@receiver t
+//	@param key
+func (t *throttler) reset(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	delete(t.entries, key)
+}
+
+// clean
+//
+//	@Description: forgets the entries whose failures are older than the
+//	window and which aren't blocked anymore. The mutex must be held.
+//	@receiver t
+//	@param now
+func (t *throttler) clean(now time.Time) {
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) > t.window && now.After(entry.blockedUntil) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+var (
+	// loginIPThrottle limits the failed logins of an IP address.
+	loginIPThrottle = newThrottler("login-ip", 10, time.Hour, time.Second, time.Minute*5, 50, time.Hour)
+	// loginAccountThrottle limits the failed logins on an account.
+	loginAccountThrottle = newThrottler("login-account", 5, time.Hour, time.Second*2, time.Minute*15, 20, time.Hour)
+	// registerIPThrottle limits the registrations of an IP address.
+	registerIPThrottle = newThrottler("register-ip", 5, time.Hour*24, time.Minute, time.Hour, 20, time.Hour*24)
+	// resetIPThrottle limits the password reset requests of an IP address.
+	resetIPThrottle = newThrottler("reset-ip", 5, time.Hour, time.Minute, time.Hour, 20, time.Hour*24)
+	// resetAccountThrottle limits the password reset requests for an email.
+	resetAccountThrottle = newThrottler("reset-account", 3, time.Hour, time.Minute*5, time.Hour, 10, time.Hour*24)
+	// magicIPThrottle limits the magic link requests of an IP address.
+	magicIPThrottle = newThrottler("magic-ip", 5, time.Hour, time.Minute, time.Hour, 20, time.Hour*24)
+	// magicAccountThrottle limits the magic link requests for an email.
+	magicAccountThrottle = newThrottler("magic-account", 3, time.Hour, time.Minute*5, time.Hour, 10, time.Hour*24)
+)
+
+// LoginAllowed
+//
+//	@Description: checks if the client and the account which username matches
+//	the `username` param are allowed to make a login attempt.
+//	@param r
+//	@param username
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func LoginAllowed(r *http.Request, username string) (time.Duration, bool) {
+	if wait, ok := loginIPThrottle.allow(GetIP(r)); !ok {
+		return wait, false
+	}
+	return loginAccountThrottle.allow(strings.ToLower(username))
+}
+
+// LoginFailed
+//
+//	@Description: records a failed login of the client on the account which
+//	username matches the `username` param. The user is notified by mail when
+//	its account gets locked out.
+//	@param r
+//	@param username
+func LoginFailed(r *http.Request, username string) {
+	loginIPThrottle.fail(GetIP(r))
+	if !loginAccountThrottle.fail(strings.ToLower(username)) {
+		return
+	}
+	
+	user, ok := SelectUser(username)
+	if ok {
+		SendMail(&server.TempUser{CreationTime: time.Now(), User: user}, "lockout")
+	}
+}
+
+// LoginSucceeded
+//
+//	@Description: forgets the failed logins on the account which username
+//	matches the `username` param. The failures of the client only expire with
+//	the window, so that an attacker can't clear them by logging in to their own
+//	account between guesses.
+//	@param username
+func LoginSucceeded(username string) {
+	loginAccountThrottle.reset(strings.ToLower(username))
+}
+
+// PasskeyLoginAllowed
+//
+//	@Description: checks if the client is allowed to make a passkey login
+//	attempt. The passkey logins aren't bound to a username, so only the client
+//	is throttled.
+//	@param r
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func PasskeyLoginAllowed(r *http.Request) (time.Duration, bool) {
+	return loginIPThrottle.allow(GetIP(r))
+}
+
+// PasskeyLoginFailed
+//
+//	@Description: records a failed passkey login of the client.
+//	@param r
+func PasskeyLoginFailed(r *http.Request) {
+	loginIPThrottle.fail(GetIP(r))
+}
+
+// RegisterAllowed
+//
+//	@Description: checks if the client is allowed to register, and records the
+//	attempt.
+//	@param r
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func RegisterAllowed(r *http.Request) (time.Duration, bool) {
+	ip := GetIP(r)
+	if wait, ok := registerIPThrottle.allow(ip); !ok {
+		return wait, false
+	}
+	registerIPThrottle.fail(ip)
+	return 0, true
+}
+
+// ResetAllowed
+//
+//	@Description: checks if the client is allowed to ask for a new password for
+//	the `email`, and records the attempt.
+//	@param r
+//	@param email
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func ResetAllowed(r *http.Request, email string) (time.Duration, bool) {
+	ip := GetIP(r)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if wait, ok := resetIPThrottle.allow(ip); !ok {
+		return wait, false
+	}
+	if wait, ok := resetAccountThrottle.allow(email); !ok {
+		return wait, false
+	}
+	resetIPThrottle.fail(ip)
+	resetAccountThrottle.fail(email)
+	return 0, true
+}
+
+// MagicLinkAllowed
+//
+//	@Description: checks if the client is allowed to ask for a magic link for
+//	the `email`, and records the attempt.
+//	@param r
+//	@param email
+//	@return time.Duration: the time to wait before the next attempt.
+//	@return bool
+func MagicLinkAllowed(r *http.Request, email string) (time.Duration, bool) {
+	ip := GetIP(r)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if wait, ok := magicIPThrottle.allow(ip); !ok {
+		return wait, false
+	}
+	if wait, ok := magicAccountThrottle.allow(email); !ok {
+		return wait, false
+	}
+	magicIPThrottle.fail(ip)
+	magicAccountThrottle.fail(email)
+	return 0, true
+}
+
+// RetryAfter
+//
+//	@Description: returns the `wait` in seconds (rounded up), to be sent in the
+//	`retry` query key.
+//	@param wait
+//	@return string
+func RetryAfter(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+// RetryMessage
+//
+//	@Description: returns the message displayed to a throttled client, according
+//	to the `retry` query key (in seconds).
+//	@param r
+//	@return string
+func RetryMessage(r *http.Request) string {
+	wait, err := time.ParseDuration(r.URL.Query().Get("retry") + "s")
+	if err != nil || wait <= 0 {
+		return "Too many attempts, please try again later!"
+	}
+	return "Too many attempts, please try again in " + DurationToString(wait) + "!"
+}
@@ -0,0 +1,410 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	
+	"github.com/skip2/go-qrcode"
+	
+	"mangathorg/internal/models/server"
+)
+
+const (
+	// totpIssuer is the name displayed in the users' authenticator apps.
+	totpIssuer = "MangaThorg"
+	// totpPeriod is the lifetime of a TOTP code.
+	totpPeriod = 30
+	// totpDigits is the number of digits of a TOTP code.
+	totpDigits = 6
+	// totpSkew is the number of periods accepted before and after the current
+	// one, for the clocks' drift.
+	totpSkew = 1
+	// recoveryCodesNumber is the number of recovery codes generated at once.
+	recoveryCodesNumber = 10
+)
+
+// totpEncoding is the base32 encoding of the TOTP secrets (RFC 4648, without
+// padding, as expected by the authenticator apps).
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret
+//
+//	@Description: generates a new random TOTP secret (160 bits, base32 encoded).
+//	@return string
+//	@return error
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// totpCode
+//
+//	@Description: computes the TOTP code of the `key` for the time `step`
+//	(RFC 6238 with HMAC-SHA1, as RFC 4226's HOTP with the step as counter).
+//	@param key
+//	@param step
+//	@return string
+func totpCode(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// verifyTOTP
+//
+//	@Description: checks the `code` against the TOTP `secret`, accepting only
+//	the time steps after `lastStep` (so that a code can't be used twice).
+//	@param secret
+//	@param code
+//	@param lastStep
+//	@return int64: the time step of the code.
+//	@return bool
+func verifyTOTP(secret string, code string, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPQRCode
+//
+//	@Description: returns the QR code (PNG data URI) of the otpauth:// URI of
+//	the `secret`, to scan it with an authenticator app.
+//	@param username
+//	@param secret
+//	@return string
+//	@return error
+func TOTPQRCode(username string, secret string) (string, error) {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + totpIssuer + ":" + username,
+		RawQuery: query.Encode(),
+	}
+	
+	png, err := qrcode.Encode(uri.String(), qrcode.Medium, 256)
+	if err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+}
+
+// normalizeRecoveryCode
+//
+//	@Description: removes the separators and spaces of a recovery code typed
+//	by a user.
+//	@param code
+//	@return string
+func normalizeRecoveryCode(code string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// hashRecoveryCode
+//
+//	@Description: returns the hash stored for a recovery code.
+//	@param code
+//	@return string
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRecoveryCodes
+//
+//	@Description: generates recoveryCodesNumber random recovery codes (80 bits,
+//	written as XXXX-XXXX-XXXX-XXXX).
+//	@return []string: the codes, to display once to the user.
+//	@return []string: their hashes, to store.
+//	@return error
+func newRecoveryCodes() ([]string, []string, error) {
+	var codes, hashes []string
+	for range recoveryCodesNumber {
+		b := make([]byte, 10)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		raw := totpEncoding.EncodeToString(b)
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// EnableTwoFactor
+//
+//	@Description: turns the two-factor authentication on for the `user` if the
+//	`code` matches the `secret` scanned by the user.
+//	@param user
+//	@param secret
+//	@param code
+//	@return []string: the recovery codes, to display once to the user.
+//	@return bool: false if the code doesn't match.
+func EnableTwoFactor(user server.User, secret string, code string) ([]string, bool) {
+	step, ok := verifyTOTP(secret, strings.TrimSpace(code), 0)
+	if !ok || len(secret) != 32 {
+		return nil, false
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return nil, false
+	}
+	
+	user.TwoFactor = &server.TwoFactor{
+		Secret:        secret,
+		LastStep:      step,
+		RecoveryCodes: hashes,
+	}
+	UpdateUser(user)
+	Logger.Info("Two-factor authentication enabled", slog.String("username", user.Username))
+	return codes, true
+}
+
+// RenewRecoveryCodes
+//
+//	@Description: replaces the recovery codes of the `user`.
+//	@param user
+//	@return []string: the new recovery codes, to display once to the user.
+//	@return bool: false if the two-factor authentication is off.
+func RenewRecoveryCodes(user server.User) ([]string, bool) {
+	if user.TwoFactor == nil {
+		return nil, false
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return nil, false
+	}
+	user.TwoFactor.RecoveryCodes = hashes
+	UpdateUser(user)
+	return codes, true
+}
+
+// DisableTwoFactor
+//
+//	@Description: turns the two-factor authentication off for the `user`.
+//	@param user
+func DisableTwoFactor(user server.User) {
+	user.TwoFactor = nil
+	UpdateUser(user)
+	Logger.Info("Two-factor authentication disabled", slog.String("username", user.Username))
+}
+
+// CheckSecondFactor
+//
+//	@Description: checks the TOTP `code` or the recovery code of the user
+//	which username matches the `username` param. A used recovery code is
+//	removed.
+//	@param username
+//	@param code
+//	@return bool
+func CheckSecondFactor(username string, code string) bool {
+	user, ok := SelectUser(username)
+	if !ok || user.TwoFactor == nil {
+		return false
+	}
+	code = strings.TrimSpace(code)
+	
+	step, ok := verifyTOTP(user.TwoFactor.Secret, code, user.TwoFactor.LastStep)
+	if ok {
+		user.TwoFactor.LastStep = step
+		UpdateUser(user)
+		return true
+	}
+	
+	hash := hashRecoveryCode(code)
+	for i, recoveryCode := range user.TwoFactor.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(recoveryCode)) == 1 {
+			user.TwoFactor.RecoveryCodes = append(user.TwoFactor.RecoveryCodes[:i], user.TwoFactor.RecoveryCodes[i+1:]...)
+			UpdateUser(user)
+			Logger.Info("Recovery code used", slog.String("username", username), slog.Int("remaining", len(user.TwoFactor.RecoveryCodes)))
+			return true
+		}
+	}
+	return false
+}
+
+// loginChallengeTime is the time given to a user to send its second factor.
+const loginChallengeTime time.Duration = time.Minute * 5
+
+// loginChallengeAttempts is the number of wrong codes accepted for a
+// loginChallenge.
+const loginChallengeAttempts = 5
+
+// loginChallenge is the second step of the login of a user with two-factor
+// authentication, once its password has been checked.
+type loginChallenge struct {
+	Username       string
+	Remember       bool
+	ExpirationTime time.Time
+	Attempts       int
+}
+
+// loginChallenges holds the pending loginChallenge by id (the login_challenge
+// cookie's value).
+var loginChallenges = struct {
+	mutex      sync.Mutex
+	challenges map[string]loginChallenge
+}{challenges: make(map[string]loginChallenge)}
+
+// loginChallengeCookie
+//
+//	@Description: creates the login_challenge cookie.
+//	@param id: "" to delete the cookie.
+//	@return *http.Cookie
+func loginChallengeCookie(id string) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     "login_challenge",
+		Value:    id,
+		HttpOnly: true,
+		Secure:   false, // TODO: change when switching to HTTPS in the future.
+		Path:     "/login",
+		MaxAge:   int(loginChallengeTime.Seconds()),
+		SameSite: http.SameSiteStrictMode,
+	}
+	if id == "" {
+		cookie.MaxAge = -1
+	}
+	return cookie
+}
+
+// StartLoginChallenge
+//
+//	@Description: creates a loginChallenge for the user which username matches
+//	the `username` param and writes its cookie.
+//	@param w
+//	@param username
+//	@param remember: whether the user asked to be remembered.
+func StartLoginChallenge(w *http.ResponseWriter, username string, remember bool) {
+	id, err := randomToken(32)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
+		return
+	}
+	
+	loginChallenges.mutex.Lock()
+	defer loginChallenges.mutex.Unlock()
+	
+	// clearing the expired challenges
+	for key, challenge := range loginChallenges.challenges {
+		if challenge.ExpirationTime.Before(time.Now()) {
+			delete(loginChallenges.challenges, key)
+		}
+	}
+	loginChallenges.challenges[id] = loginChallenge{
+		Username:       username,
+		Remember:       remember,
+		ExpirationTime: time.Now().Add(loginChallengeTime),
+	}
+	http.SetCookie(*w, loginChallengeCookie(id))
+}
+
+// LoginChallengeUser
+//
+//	@Description: returns the username of the pending loginChallenge carried
+//	by the *http.Request.
+//	@param r
+//	@return string
+//	@return bool: false if there is no pending loginChallenge.
+func LoginChallengeUser(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie("login_challenge")
+	if err != nil {
+		return "", false
+	}
+	
+	loginChallenges.mutex.Lock()
+	defer loginChallenges.mutex.Unlock()
+	
+	challenge, ok := loginChallenges.challenges[cookie.Value]
+	if !ok || challenge.ExpirationTime.Before(time.Now()) {
+		return "", false
+	}
+	return challenge.Username, true
+}
+
+// CompleteLoginChallenge
+//
+//	@Description: checks the second factor `code` of the loginChallenge found
+//	in the *http.Request. The loginChallenge ends when the code is right, when
+//	it expires or after loginChallengeAttempts wrong codes.
+//	@param w
+//	@param r
+//	@param code
+//	@return username: the user to log in, if the code is right.
+//	@return remember: whether the user asked to be remembered.
+//	@return pending: false if the loginChallenge has ended.
+func CompleteLoginChallenge(w *http.ResponseWriter, r *http.Request, code string) (username string, remember bool, pending bool) {
+	cookie, err := r.Cookie("login_challenge")
+	if err != nil {
+		return "", false, false
+	}
+	
+	loginChallenges.mutex.Lock()
+	defer loginChallenges.mutex.Unlock()
+	
+	challenge, ok := loginChallenges.challenges[cookie.Value]
+	if !ok || challenge.ExpirationTime.Before(time.Now()) {
+		delete(loginChallenges.challenges, cookie.Value)
+		http.SetCookie(*w, loginChallengeCookie(""))
+		return "", false, false
+	}
+	
+	if CheckSecondFactor(challenge.Username, code) {
+		delete(loginChallenges.challenges, cookie.Value)
+		http.SetCookie(*w, loginChallengeCookie(""))
+		return challenge.Username, challenge.Remember, false
+	}
+	
+	challenge.Attempts++
+	Logger.Warn("Wrong second factor", slog.String("username", challenge.Username), slog.Int("attempts", challenge.Attempts), slog.String("client_ip", GetIP(r)))
+	if challenge.Attempts >= loginChallengeAttempts {
+		delete(loginChallenges.challenges, cookie.Value)
+		http.SetCookie(*w, loginChallengeCookie(""))
+		return "", false, false
+	}
+	loginChallenges.challenges[cookie.Value] = challenge
+	return "", false, true
+}
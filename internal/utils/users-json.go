@@ -0,0 +1,218 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	
+	"mangathorg/internal/models/server"
+)
+
+// jsonUserStore is the UserStore keeping all models.User in a single JSON file.
+type jsonUserStore struct {
+	file  string
+	mutex *sync.RWMutex
+}
+
+// newJSONUserStore
+//
+//	@Description: creates the JSON `file` if it doesn't exist and returns its UserStore.
+//	@param file
+//	@return *jsonUserStore
+//	@return error
+func newJSONUserStore(file string) (*jsonUserStore, error) {
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		err = os.WriteFile(file, []byte("[]"), 0644)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &jsonUserStore{file: file, mutex: new(sync.RWMutex)}, nil
+}
+
+// read
+//
+//	@Description: retrieves all models.User present in the file (the mutex must
+//	be held by the caller).
+//	@receiver store
+//	@return []models.User
+//	@return error
+func (store *jsonUserStore) read() ([]server.User, error) {
+	var users []server.User
+	
+	data, err := os.ReadFile(store.file)
+	if err != nil {
+		return nil, err
+	}
+	
+	if len(data) == 0 {
+		return nil, nil
+	}
+	
+	err = json.Unmarshal(data, &users)
+	if err != nil {
+		return nil, err
+	}
+	
+	return users, nil
+}
+
+// write
+//
+//	@Description: overwrites the file with `users` in JSON format (the mutex must
+//	be held by the caller).
+//	@receiver store
+//	@param users
+//	@return error
+func (store *jsonUserStore) write(users []server.User) error {
+	data, err := json.MarshalIndent(users, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(store.file, data, 0666)
+}
+
+// selectUser
+//
+//	@Description: returns the first models.User matching the `match` function.
+//	@receiver store
+//	@param match
+//	@return models.User
+//	@return bool
+//	@return error
+func (store *jsonUserStore) selectUser(match func(user server.User) bool) (server.User, bool, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return server.User{}, false, err
+	}
+	for _, user := range users {
+		if match(user) {
+			return user, true, nil
+		}
+	}
+	return server.User{}, false, nil
+}
+
+func (store *jsonUserStore) All() ([]server.User, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	return store.read()
+}
+
+func (store *jsonUserStore) SelectById(id int) (server.User, bool, error) {
+	return store.selectUser(func(user server.User) bool { return user.Id == id })
+}
+
+func (store *jsonUserStore) SelectByUsername(username string) (server.User, bool, error) {
+	return store.selectUser(func(user server.User) bool { return user.Username == username })
+}
+
+func (store *jsonUserStore) SelectByEmail(email string) (server.User, bool, error) {
+	// the email addresses are compared case-insensitively
+	return store.selectUser(func(user server.User) bool { return strings.EqualFold(user.Email, email) })
+}
+
+func (store *jsonUserStore) SelectByIdentity(identity server.Identity) (server.User, bool, error) {
+	return store.selectUser(func(user server.User) bool { return slices.Contains(user.Identities, identity) })
+}
+
+func (store *jsonUserStore) Create(newUser server.User) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		if user.Id == newUser.Id || user.Username == newUser.Username || strings.EqualFold(user.Email, newUser.Email) {
+			return ErrUserExists
+		}
+	}
+	return store.write(append(users, newUser))
+}
+
+func (store *jsonUserStore) Update(updatedUser server.User) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return err
+	}
+	for i, user := range users {
+		if user.Id == updatedUser.Id {
+			users[i] = updatedUser
+			return store.write(users)
+		}
+	}
+	return ErrUserNotFound
+}
+
+func (store *jsonUserStore) Modify(username string, change func(user *server.User) bool) (server.User, bool, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return server.User{}, false, err
+	}
+	for i := range users {
+		if users[i].Username == username {
+			if change(&users[i]) {
+				err = store.write(users)
+			}
+			return users[i], err == nil, err
+		}
+	}
+	return server.User{}, false, nil
+}
+
+func (store *jsonUserStore) Delete(id int) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return err
+	}
+	for i, user := range users {
+		if user.Id == id {
+			return store.write(append(users[:i], users[i+1:]...))
+		}
+	}
+	return ErrUserNotFound
+}
+
+// NewId returns the first unused id in the file.
+func (store *jsonUserStore) NewId() (int, error) {
+	store.mutex.RLock()
+	defer store.mutex.RUnlock()
+	
+	users, err := store.read()
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	var idFound bool
+	for id = 1; !idFound; id++ {
+		idFound = true
+		for _, user := range users {
+			if user.Id == id {
+				idFound = false
+			}
+		}
+	}
+	id--
+	return id, nil
+}
+
+func (store *jsonUserStore) Close() error {
+	return nil
+}
@@ -0,0 +1,223 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	
+	"mangathorg/internal/models/server"
+)
+
+// sqliteUserStore is the UserStore keeping the models.User in the embedded
+// SQLite database. The username and email columns are indexed for the lookups,
+// the whole models.User is stored as JSON in the data column.
+type sqliteUserStore struct {
+	db *sql.DB
+}
+
+// newSQLiteUserStore
+//
+//	@Description: creates the users table if it doesn't exist and returns its UserStore.
+//	@param db
+//	@return *sqliteUserStore
+//	@return error
+func newSQLiteUserStore(db *sql.DB) (*sqliteUserStore, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY,
+		username TEXT NOT NULL,
+		email TEXT NOT NULL,
+		data TEXT NOT NULL
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS users_username ON users(username);
+	CREATE UNIQUE INDEX IF NOT EXISTS users_email ON users(email);
+	CREATE INDEX IF NOT EXISTS users_email_nocase ON users(email COLLATE NOCASE);`)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteUserStore{db: db}, nil
+}
+
+// selectUser
+//
+//	@Description: returns the models.User matching the `where` condition.
+//	@receiver store
+//	@param where
+//	@param args: the values of the condition's placeholders.
+//	@return models.User
+//	@return bool
+//	@return error
+func (store *sqliteUserStore) selectUser(where string, args ...any) (server.User, bool, error) {
+	var user server.User
+	var data string
+	
+	err := store.db.QueryRow("SELECT data FROM users WHERE "+where, args...).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, err
+	}
+	
+	err = json.Unmarshal([]byte(data), &user)
+	if err != nil {
+		return user, false, err
+	}
+	return user, true, nil
+}
+
+func (store *sqliteUserStore) All() ([]server.User, error) {
+	rows, err := store.db.Query("SELECT data FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	
+	var users []server.User
+	for rows.Next() {
+		var data string
+		var user server.User
+		if err = rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal([]byte(data), &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (store *sqliteUserStore) SelectById(id int) (server.User, bool, error) {
+	return store.selectUser("id = ?", id)
+}
+
+func (store *sqliteUserStore) SelectByUsername(username string) (server.User, bool, error) {
+	return store.selectUser("username = ?", username)
+}
+
+func (store *sqliteUserStore) SelectByEmail(email string) (server.User, bool, error) {
+	// the email addresses are compared case-insensitively
+	return store.selectUser("email = ? COLLATE NOCASE", email)
+}
+
+func (store *sqliteUserStore) SelectByIdentity(identity server.Identity) (server.User, bool, error) {
+	return store.selectUser(`EXISTS (SELECT 1 FROM json_each(users.data, '$.identities')
+		WHERE json_extract(value, '$.issuer') = ? AND json_extract(value, '$.subject') = ?)`,
+		identity.Issuer, identity.Subject)
+}
+
+func (store *sqliteUserStore) Create(newUser server.User) error {
+	data, err := json.Marshal(newUser)
+	if err != nil {
+		return err
+	}
+	_, err = store.db.Exec("INSERT INTO users (id, username, email, data) VALUES (?, ?, ?, ?)",
+		newUser.Id, newUser.Username, newUser.Email, string(data))
+	if err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed") {
+		return ErrUserExists
+	}
+	return err
+}
+
+func (store *sqliteUserStore) Update(updatedUser server.User) error {
+	data, err := json.Marshal(updatedUser)
+	if err != nil {
+		return err
+	}
+	res, err := store.db.Exec("UPDATE users SET username = ?, email = ?, data = ? WHERE id = ?",
+		updatedUser.Username, updatedUser.Email, string(data), updatedUser.Id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return ErrUserExists
+		}
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (store *sqliteUserStore) Modify(username string, change func(user *server.User) bool) (server.User, bool, error) {
+	ctx := context.Background()
+	conn, err := store.db.Conn(ctx)
+	if err != nil {
+		return server.User{}, false, err
+	}
+	defer conn.Close()
+	
+	// the write lock is taken before reading, so that no other write can
+	// happen before the models.User is saved
+	_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+	if err != nil {
+		return server.User{}, false, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+	
+	var user server.User
+	var data string
+	err = conn.QueryRowContext(ctx, "SELECT data FROM users WHERE username = ?", username).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, false, nil
+	}
+	if err != nil {
+		return user, false, err
+	}
+	err = json.Unmarshal([]byte(data), &user)
+	if err != nil {
+		return user, false, err
+	}
+	if !change(&user) {
+		return user, true, nil
+	}
+	
+	updated, err := json.Marshal(user)
+	if err != nil {
+		return user, false, err
+	}
+	_, err = conn.ExecContext(ctx, "UPDATE users SET username = ?, email = ?, data = ? WHERE id = ?",
+		user.Username, user.Email, string(updated), user.Id)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			return user, false, ErrUserExists
+		}
+		return user, false, err
+	}
+	_, err = conn.ExecContext(ctx, "COMMIT")
+	if err != nil {
+		return user, false, err
+	}
+	committed = true
+	return user, true, nil
+}
+
+func (store *sqliteUserStore) Delete(id int) error {
+	res, err := store.db.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// NewId returns the first unused id in the users table.
+func (store *sqliteUserStore) NewId() (int, error) {
+	var id int
+	err := store.db.QueryRow(`SELECT COALESCE(MIN(u.id + 1), 1) FROM users u
+		WHERE NOT EXISTS (SELECT 1 FROM users v WHERE v.id = u.id + 1)
+		AND EXISTS (SELECT 1 FROM users w WHERE w.id = 1)`).Scan(&id)
+	return id, err
+}
+
+func (store *sqliteUserStore) Close() error {
+	return store.db.Close()
+}
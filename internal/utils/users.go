@@ -1,41 +1,77 @@
 package utils
 
 import (
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
-	"reflect"
 	"regexp"
-	"sync"
-	"time"
 	
 	"mangathorg/internal/models/server"
 )
 
-// directory is the directory where the json file is stored.
+// directory is the directory where the users' data is stored.
 var directory = Path + "data"
 
 // jsonFile is the models.User's JSON file full path.
 var jsonFile = directory + "/users.json"
 
-// mutex is the mutex for jsonFile.
-var mutex = new(sync.RWMutex)
+// users is the UserStore selected at startup by InitUsers.
+var users UserStore
 
-// TempUsers is the models.TempUser's array for newly registered models.User
-// before they confirm their email address.
-var TempUsers []server.TempUser
+var (
+	ErrUserExists   = errors.New("username or email already used")
+	ErrUserNotFound = errors.New("user not found")
+)
 
-// LostUsers is the models.TempUser's array for models.User
-// that forgot their password, until they access the link sent to their
-// email address to change their password.
-var LostUsers []server.TempUser
+// UserStore is the storage backend of the models.User.
+type UserStore interface {
+	All() ([]server.User, error)
+	SelectById(id int) (server.User, bool, error)
+	SelectByUsername(username string) (server.User, bool, error)
+	// SelectByEmail compares the email addresses case-insensitively.
+	SelectByEmail(email string) (server.User, bool, error)
+	// SelectByIdentity returns the models.User linked to the OpenID Connect
+	// `identity`.
+	SelectByIdentity(identity server.Identity) (server.User, bool, error)
+	Create(newUser server.User) error
+	Update(updatedUser server.User) error
+	// Modify applies the `change` to the models.User which username matches
+	// the `username` param and saves it (unless `change` returns false),
+	// without any other write in between.
+	Modify(username string, change func(user *server.User) bool) (server.User, bool, error)
+	Delete(id int) error
+	// NewId returns the first unused models.User.Id.
+	NewId() (int, error)
+	Close() error
+}
+
+// OpenUserStore
+//
+//	@Description: opens the UserStore matching the `backend` param ("json" or "sqlite").
+//	@param backend
+//	@return UserStore
+//	@return error
+func OpenUserStore(backend string) (UserStore, error) {
+	switch backend {
+	case "", "json":
+		return newJSONUserStore(jsonFile)
+	case "sqlite":
+		db, err := Database()
+		if err != nil {
+			return nil, err
+		}
+		return newSQLiteUserStore(db)
+	default:
+		return nil, fmt.Errorf("unknown user store %q", backend)
+	}
+}
 
+// InitUsers creates the data directory and opens the UserStore set in the
+// USER_STORE environment variable (json by default). In production, it stops
+// the server if PASSWORD_PEPPER isn't set.
 func InitUsers() {
-	mutex.Lock()
-	defer mutex.Unlock()
-	
 	if _, err := os.Stat(directory); errors.Is(err, os.ErrNotExist) {
 		err = os.Mkdir(directory, 0755)
 		if err != nil {
@@ -44,56 +80,75 @@ func InitUsers() {
 		}
 	}
 	
-	if _, err := os.Stat(jsonFile); errors.Is(err, os.ErrNotExist) {
-		err = os.WriteFile(jsonFile, []byte("[]"), 0644)
-		if err != nil {
-			log.Printf("An error occurred: %s", err.Error())
-			log.Fatalln("Error while creating users.json file!")
+	store, err := OpenUserStore(os.Getenv("USER_STORE"))
+	if err != nil {
+		log.Printf("An error occurred: %s", err.Error())
+		log.Fatalln("Error while opening the user store!")
+	}
+	users = store
+	
+	if currentPepper == nil {
+		// the production server never hashes the passwords without pepper
+		if os.Getenv("ENV") == "production" {
+			log.Fatalln("PASSWORD_PEPPER must be set in production")
 		}
+		log.Println("PASSWORD_PEPPER is not set: the passwords are hashed without pepper")
 	}
 }
 
-// retrieveUsers
-// retrieves all models.User present in jsonFile and stores them in a slice of models.User.
-// It returns the slice of models.User and an error.
-func retrieveUsers() ([]server.User, error) {
-	mutex.RLock()
-	defer mutex.RUnlock()
-	
-	var users []server.User
-	
-	data, err := os.ReadFile(jsonFile)
-	
-	if len(data) == 0 {
-		return nil, nil
-	}
-	
-	err = json.Unmarshal(data, &users)
+// MigrateUsers
+//
+//	@Description: copies all models.User from the `from` UserStore to the `to`
+//	UserStore. The models.User whose id already exists in `to` are skipped.
+//	@param from
+//	@param to
+//	@return int: the number of copied models.User.
+//	@return error
+func MigrateUsers(from UserStore, to UserStore) (int, error) {
+	all, err := from.All()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	
-	return users, nil
+	var copied int
+	for _, user := range all {
+		_, exists, err := to.SelectById(user.Id)
+		if err != nil {
+			return copied, err
+		}
+		if exists {
+			continue
+		}
+		err = to.Create(user)
+		if err != nil {
+			return copied, fmt.Errorf("user %d (%s): %w", user.Id, user.Username, err)
+		}
+		copied++
+	}
+	return copied, nil
 }
 
 // CheckUser
-// checks if the models.User 's username and email are still available in jsonFile and TempUsers.
+// checks if the models.User 's username and email are still available in the UserStore and the pending models.TempUser.
 func CheckUser(user server.User) bool {
-	users, err := retrieveUsers()
+	_, usernameUsed, err := users.SelectByUsername(user.Username)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	for _, singleUser := range users {
-		if user.Username == singleUser.Username || user.Email == singleUser.Email {
-			return false
-		}
+	if usernameUsed || pendingUsername(user.Username) {
+		return false
 	}
-	for _, tempUser := range TempUsers {
-		if user.Username == tempUser.User.Username || user.Email == tempUser.User.Email {
-			return false
-		}
+	return EmailAvailable(user.Email)
+}
+
+// EmailAvailable
+// checks if the `email` isn't used by a models.User of the UserStore, a pending
+// registration or a pending email address change.
+func EmailAvailable(email string) bool {
+	_, emailUsed, err := users.SelectByEmail(email)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	return true
+	return !emailUsed && !pendingEmail(email)
 }
 
 // CheckEmail checks the mail's format.
@@ -104,16 +159,11 @@ func CheckEmail(email string) bool {
 
 // EmailExists return whether the mail address exists in the user's list.
 func EmailExists(email string) (bool, server.User) {
-	users, err := retrieveUsers()
+	user, ok, err := users.SelectByEmail(email)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	for _, singleUser := range users {
-		if email == singleUser.Email {
-			return true, singleUser
-		}
-	}
-	return false, server.User{}
+	return ok, user
 }
 
 // CheckPasswd
@@ -131,173 +181,73 @@ func CheckPasswd(passwd string) bool {
 	return digit.MatchString(passwd) && lower.MatchString(passwd) && upper.MatchString(passwd) && symbol.MatchString(passwd) && minLen.MatchString(passwd)
 }
 
-// changeUsers
-// overwrites jsonFile with `users` in json format.
-func changeUsers(users []server.User) {
-	mutex.Lock()
-	defer mutex.Unlock()
-	
-	data, errJSON := json.MarshalIndent(users, "", "\t")
-	if errJSON != nil {
-		Logger.Error(GetCurrentFuncName()+" JSON MarshalIndent error!", slog.Any("output", errJSON))
-		return
-	}
-	errWrite := os.WriteFile(jsonFile, data, 0666)
-	if errWrite != nil {
-		Logger.Error(GetCurrentFuncName()+" WriteFile error!", slog.Any("output", errWrite))
-	}
-}
-
 // GetIdNewUser
-// returns first unused id in jsonFile.
+// returns first unused id in the UserStore.
 func GetIdNewUser() int {
-	users, err := retrieveUsers()
+	id, err := users.NewId()
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	var id int
-	var idFound bool
-	for id = 1; !idFound; id++ {
-		idFound = true
-		for _, user := range users {
-			if user.Id == id {
-				idFound = false
-			}
-		}
-	}
-	id--
 	return id
 }
 
 // CreateUser
-// adds the models.User `newUser` to jsonFile.
+// adds the models.User `newUser` to the UserStore.
 func CreateUser(newUser server.User) {
-	users, err := retrieveUsers()
+	err := users.Create(newUser)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	users = append(users, newUser)
-	changeUsers(users)
 }
 
 // removeUser
-// remove the models.User which models.User.Id is sent in argument from jsonFile.
+// remove the models.User which models.User.Id is sent in argument from the UserStore.
 func removeUser(id int) {
-	users, err := retrieveUsers()
+	err := users.Delete(id)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	for i, user := range users {
-		if user.Id == id {
-			users = append(users[:i], users[i+1:]...)
-		}
-	}
-	changeUsers(users)
 }
 
 // SelectUser
 // returns the models.User which models.User.Username matches the `username` argument.
 func SelectUser(username string) (server.User, bool) {
-	var user server.User
-	users, err := retrieveUsers()
+	user, ok, err := users.SelectByUsername(username)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	var ok bool
-	for _, singleUser := range users {
-		if singleUser.Username == username {
-			ok = true
-			user = singleUser
-		}
+	return user, ok
+}
+
+// SelectUserById
+// returns the models.User which models.User.Id matches the `id` argument.
+func SelectUserById(id int) (server.User, bool) {
+	user, ok, err := users.SelectById(id)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
 	return user, ok
 }
 
 // UpdateUser
-// modifies the models.User in jsonFile that matches
+// modifies the models.User in the UserStore that matches
 // `updatedUser`'s Id with `updatedUser`'s content.
 func UpdateUser(updatedUser server.User) {
-	users, err := retrieveUsers()
+	err := users.Update(updatedUser)
 	if err != nil {
 		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
-	for i, user := range users {
-		if user.Id == updatedUser.Id {
-			users[i] = updatedUser
-		}
-	}
-	changeUsers(users)
-}
-
-// deleteTempUser
-// removes a specific models.TempUser from TempUsers.
-func deleteTempUser(temp server.TempUser) {
-	for i, user := range TempUsers {
-		if reflect.DeepEqual(user, temp) {
-			TempUsers = append(TempUsers[:i], TempUsers[i+1:]...)
-		}
-	}
-}
-
-// deleteLostUser
-// removes a specific models.TempUser from LostUsers.
-func deleteLostUser(temp server.TempUser) {
-	for i, user := range LostUsers {
-		if reflect.DeepEqual(user, temp) {
-			LostUsers = append(LostUsers[:i], LostUsers[i+1:]...)
-		}
-	}
-}
-
-// PushTempUser
-// creates a new user from a models.TempUser
-// which Id matches the `id` param.
-func PushTempUser(id string) {
-	for _, temp := range TempUsers {
-		if temp.ConfirmID == id {
-			temp.User.Id = GetIdNewUser()
-			temp.User.CreationTime = time.Now()
-			temp.User.Avatar = "profile-avatar-059.jpg"
-			CreateUser(temp.User)
-			deleteTempUser(temp)
-		}
-	}
 }
 
-// UpdateLostUser
-// updates the models.User's Hash and Salt with the `lost`
-// models.TempUser's Hash and Salt sent in the param.
-func UpdateLostUser(lost server.TempUser) {
-	user, ok := SelectUser(lost.User.Username)
-	if !ok {
-		Logger.Error(GetCurrentFuncName(), slog.Any("output", errors.New("user not found")))
-	}
-	user.Salt = lost.User.Salt
-	user.HashedPwd = lost.User.HashedPwd
-	UpdateUser(user)
-	deleteLostUser(lost)
-}
-
-// ManageTempUsers
-// is a goroutine that periodically removes old models.TempUser from TempUsers and LostUsers.
-func ManageTempUsers() {
-	time.Sleep(time.Second * 10)
-	duration := SetDailyTimer(0)
-	for {
-		Logger.Info(GetCurrentFuncName(), slog.String("goroutine", "ManageTempUsers"))
-		for _, user := range TempUsers {
-			if time.Since(user.CreationTime) > time.Hour*12 {
-				Logger.Info("TempUser cleared automatically", slog.Any("user", user))
-				deleteTempUser(user)
-			}
-		}
-		for _, user := range LostUsers {
-			if time.Since(user.CreationTime) > time.Hour*12 {
-				Logger.Info("LostUser cleared automatically", slog.Any("user", user))
-				deleteTempUser(user)
-			}
-		}
-		time.Sleep(duration)
-		duration = time.Hour * 12
+// ModifyUser
+// applies the `change` to the models.User which models.User.Username matches the
+// `username` argument, reading and saving it atomically so that the concurrent
+// requests of the user don't overwrite each other. Nothing is saved if `change`
+// returns false. It returns the models.User once changed.
+func ModifyUser(username string, change func(user *server.User) bool) (server.User, bool) {
+	user, ok, err := users.Modify(username, change)
+	if err != nil {
+		Logger.Error(GetCurrentFuncName(), slog.Any("output", err))
 	}
+	return user, ok
 }
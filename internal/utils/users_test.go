@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	
+	"mangathorg/internal/models/server"
+)
+
+// useUserStore
+//
+//	@Description: replaces the UserStore by the one of the `backend` for the
+//	test.
+//	@param t
+//	@param backend
+func useUserStore(t *testing.T, backend string) {
+	store, err := OpenUserStore(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := users
+	users = store
+	t.Cleanup(func() {
+		users = previous
+	})
+}
+
+func TestModifyUserConcurrently(t *testing.T) {
+	for _, backend := range []string{"json", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			useUserStore(t, backend)
+			username := "reader-" + backend
+			CreateUser(server.User{Id: GetIdNewUser(), Username: username, Email: username + "@example.com", CreationTime: time.Now()})
+			
+			// the requests of a reader scrolling several chapters and
+			// toggling favorites at the same time
+			const chapters = 20
+			var wg sync.WaitGroup
+			for i := 0; i < chapters; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := AddHistoryEntry(username, server.HistoryEntry{MangaId: "manga", ChapterId: "chapter-" + strconv.Itoa(i)})
+					for page := 1; page <= 3; page++ {
+						UpdateHistoryPage(username, id, page)
+					}
+					ModifyUser(username, func(user *server.User) bool {
+						user.Favorites = append(user.Favorites, server.MangaUser{Id: "manga-" + strconv.Itoa(i)})
+						return true
+					})
+				}(i)
+			}
+			wg.Wait()
+			
+			user, ok := SelectUser(username)
+			if !ok {
+				t.Fatal("user not found")
+			}
+			if len(user.History) != chapters || len(user.Favorites) != chapters {
+				t.Fatalf("%d history entries and %d favorites kept instead of %d", len(user.History), len(user.Favorites), chapters)
+			}
+			for _, entry := range user.History {
+				if entry.Page != 3 {
+					t.Fatalf("page %d stored for %s instead of 3", entry.Page, entry.ChapterId)
+				}
+			}
+		})
+	}
+}
+
+func TestModifyUserWithoutChange(t *testing.T) {
+	for _, backend := range []string{"json", "sqlite"} {
+		t.Run(backend, func(t *testing.T) {
+			useUserStore(t, backend)
+			username := "unchanged-" + backend
+			CreateUser(server.User{Id: GetIdNewUser(), Username: username, Email: username + "@example.com", CreationTime: time.Now()})
+			
+			_, ok := ModifyUser(username, func(user *server.User) bool {
+				user.Avatar = "discarded.jpg"
+				return false
+			})
+			if stored, _ := SelectUser(username); !ok || stored.Avatar != "" {
+				t.Fatalf("discarded change saved: %+v", stored)
+			}
+			if _, ok = ModifyUser("nobody-"+backend, func(*server.User) bool { return true }); ok {
+				t.Fatal("unknown user modified")
+			}
+		})
+	}
+}
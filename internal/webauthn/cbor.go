@@ -0,0 +1,178 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// cborMaxDepth bounds the nesting of the decoded CBOR items.
+const cborMaxDepth = 16
+
+var ErrCBOR = errors.New("malformed CBOR")
+
+// decodeCBOR
+//
+//	@Description: decodes the first CBOR data item of `data` (RFC 8949), as
+//	encoded by the authenticators: the unsigned and negative integers are
+//	decoded as int64, the byte strings as []byte, the text strings as string,
+//	the arrays as []any and the maps as map[any]any. The indefinite lengths
+//	aren't supported, since CTAP2 forbids them.
+//	@param data
+//	@return any: the decoded item.
+//	@return []byte: the bytes following the item.
+//	@return error: ErrCBOR if the item is malformed.
+func decodeCBOR(data []byte) (any, []byte, error) {
+	return decodeCBORItem(data, 0)
+}
+
+// decodeCBORItem
+//
+//	@Description: decodes the first CBOR data item of `data`, nested in
+//	`depth` items.
+//	@param data
+//	@param depth
+//	@return any
+//	@return []byte
+//	@return error
+func decodeCBORItem(data []byte, depth int) (any, []byte, error) {
+	if depth > cborMaxDepth || len(data) == 0 {
+		return nil, nil, ErrCBOR
+	}
+	major, info := data[0]>>5, data[0]&0x1f
+	data = data[1:]
+	
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		case 22, 23:
+			return nil, data, nil
+		case 25:
+			if len(data) < 2 {
+				return nil, nil, ErrCBOR
+			}
+			return halfFloat(binary.BigEndian.Uint16(data)), data[2:], nil
+		case 26:
+			if len(data) < 4 {
+				return nil, nil, ErrCBOR
+			}
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), data[4:], nil
+		case 27:
+			if len(data) < 8 {
+				return nil, nil, ErrCBOR
+			}
+			return math.Float64frombits(binary.BigEndian.Uint64(data)), data[8:], nil
+		}
+		return nil, nil, ErrCBOR
+	}
+	
+	// the argument of the item: its value, its length or its number of elements
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24 && len(data) >= 1:
+		arg, data = uint64(data[0]), data[1:]
+	case info == 25 && len(data) >= 2:
+		arg, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+	case info == 26 && len(data) >= 4:
+		arg, data = uint64(binary.BigEndian.Uint32(data)), data[4:]
+	case info == 27 && len(data) >= 8:
+		arg, data = binary.BigEndian.Uint64(data), data[8:]
+	default:
+		return nil, nil, ErrCBOR
+	}
+	
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBOR
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, ErrCBOR
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBOR
+		}
+		if major == 3 {
+			return string(data[:arg]), data[arg:], nil
+		}
+		return data[:arg:arg], data[arg:], nil
+	case 4:
+		// each element takes at least a byte
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBOR
+		}
+		array := make([]any, 0, arg)
+		for range arg {
+			var item any
+			var err error
+			item, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			array = append(array, item)
+		}
+		return array, data, nil
+	case 5:
+		if arg > uint64(len(data)) {
+			return nil, nil, ErrCBOR
+		}
+		object := make(map[any]any, arg)
+		for range arg {
+			var key, value any
+			var err error
+			key, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, ErrCBOR
+			}
+			value, data, err = decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			object[key] = value
+		}
+		return object, data, nil
+	case 6:
+		// the tags are ignored
+		return decodeCBORItem(data, depth+1)
+	}
+	return nil, nil, ErrCBOR
+}
+
+// halfFloat
+//
+//	@Description: converts an IEEE 754 half-precision float.
+//	@param bits
+//	@return float64
+func halfFloat(bits uint16) float64 {
+	exponent, mantissa := int(bits>>10&0x1f), float64(bits&0x3ff)
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		value = math.Inf(1)
+		if mantissa != 0 {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+	if bits&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
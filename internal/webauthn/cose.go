@@ -0,0 +1,116 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// The COSE algorithms supported for the credentials' public keys, in the
+// order of preference sent to the authenticators.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// The COSE_Key parameters (RFC 9052) read by parsePublicKey.
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1 // or the modulus of the RSA keys
+	coseX   = -2 // or the exponent of the RSA keys
+	coseY   = -3
+)
+
+var ErrPublicKey = errors.New("invalid or unsupported public key")
+
+// publicKey is a credential's public key, parsed from its COSE_Key.
+type publicKey struct {
+	Algorithm int64
+	Key       crypto.PublicKey
+}
+
+// parsePublicKey
+//
+//	@Description: parses the COSE_Key of a credential, which must be an ES256
+//	(P-256), EdDSA (Ed25519) or RS256 key.
+//	@param cose: the CBOR encoded COSE_Key.
+//	@return publicKey
+//	@return error: ErrPublicKey.
+func parsePublicKey(cose []byte) (publicKey, error) {
+	item, _, err := decodeCBOR(cose)
+	if err != nil {
+		return publicKey{}, fmt.Errorf("%w: %w", ErrPublicKey, err)
+	}
+	params, ok := item.(map[any]any)
+	if !ok {
+		return publicKey{}, ErrPublicKey
+	}
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+	crv, _ := params[int64(coseCrv)].(int64)
+	x, _ := params[int64(coseX)].([]byte)
+	
+	switch {
+	case kty == 2 && alg == AlgES256 && crv == 1:
+		y, _ := params[int64(coseY)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return publicKey{}, ErrPublicKey
+		}
+		// crypto/ecdh checks that the point is on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			return publicKey{}, fmt.Errorf("%w: %w", ErrPublicKey, err)
+		}
+		return publicKey{Algorithm: alg, Key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+	case kty == 1 && alg == AlgEdDSA && crv == 6:
+		if len(x) != ed25519.PublicKeySize {
+			return publicKey{}, ErrPublicKey
+		}
+		return publicKey{Algorithm: alg, Key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := params[int64(coseCrv)].([]byte)
+		e := new(big.Int).SetBytes(x)
+		if len(n) < 256 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return publicKey{}, ErrPublicKey
+		}
+		return publicKey{Algorithm: alg, Key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(e.Int64()),
+		}}, nil
+	}
+	return publicKey{}, fmt.Errorf("%w: kty %d, alg %d", ErrPublicKey, kty, alg)
+}
+
+// verify
+//
+//	@Description: checks the `signature` of the `data` with the publicKey.
+//	@receiver key
+//	@param data
+//	@param signature
+//	@return bool
+func (key publicKey) verify(data, signature []byte) bool {
+	switch key.Algorithm {
+	case AlgES256:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key.Key.(*ecdsa.PublicKey), digest[:], signature)
+	case AlgEdDSA:
+		return ed25519.Verify(key.Key.(ed25519.PublicKey), data, signature)
+	case AlgRS256:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key.Key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil
+	}
+	return false
+}
@@ -0,0 +1,280 @@
+// Package soft is a software WebAuthn authenticator, holding ES256 passkeys
+// in memory, to run the registration and authentication ceremonies without
+// a browser nor a security key.
+package soft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	
+	"mangathorg/internal/webauthn"
+)
+
+var (
+	ErrExcluded      = errors.New("a credential of the authenticator is excluded")
+	ErrNoCredentials = errors.New("no credential for this relying party")
+)
+
+// credential is a passkey stored by the Authenticator.
+type credential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+	signCount  uint32
+}
+
+// Authenticator is a software authenticator, used from the Origin. It always
+// reports the user as present and verified.
+type Authenticator struct {
+	Origin string
+	// CountSignatures makes the Authenticator increment the signature
+	// counters, like the security keys do. The synced passkeys always send 0.
+	CountSignatures bool
+	
+	mutex       sync.Mutex
+	credentials []*credential
+}
+
+// New
+//
+//	@Description: returns an Authenticator without credentials.
+//	@param origin: the origin of the website using the Authenticator.
+//	@return *Authenticator
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin, CountSignatures: true}
+}
+
+// Register
+//
+//	@Description: creates a passkey for the `options` of a registration
+//	ceremony, as done by navigator.credentials.create.
+//	@receiver a
+//	@param options
+//	@return webauthn.RegistrationResponse
+//	@return error: ErrExcluded if the Authenticator holds an excluded credential.
+func (a *Authenticator) Register(options webauthn.CreationOptions) (webauthn.RegistrationResponse, error) {
+	var res webauthn.RegistrationResponse
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	
+	for _, excluded := range options.ExcludeCredentials {
+		for _, c := range a.credentials {
+			if bytes.Equal(c.id, excluded.ID) {
+				return res, ErrExcluded
+			}
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return res, err
+	}
+	c := &credential{
+		id:         make([]byte, 16),
+		rpID:       options.RP.ID,
+		userHandle: bytes.Clone(options.User.ID),
+		key:        key,
+	}
+	_, err = rand.Read(c.id)
+	if err != nil {
+		return res, err
+	}
+	// a discoverable credential replaces the one of the same user
+	for i, old := range a.credentials {
+		if old.rpID == c.rpID && bytes.Equal(old.userHandle, c.userHandle) {
+			a.credentials = append(a.credentials[:i], a.credentials[i+1:]...)
+			break
+		}
+	}
+	a.credentials = append(a.credentials, c)
+	
+	// attested credential data: AAGUID (zeroes), credential ID length, credential ID, COSE_Key
+	attested := make([]byte, 18, 18+len(c.id))
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(c.id)))
+	attested = append(attested, c.id...)
+	attested = append(attested, encodeCBOR(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: c.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		-3: c.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})...)
+	
+	res.ID = c.id
+	res.Response.ClientDataJSON = a.clientData("webauthn.create", options.Challenge)
+	res.Response.AttestationObject = encodeCBOR(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(c, 0x40, attested),
+	})
+	res.Response.Transports = []string{"internal"}
+	return res, nil
+}
+
+// Login
+//
+//	@Description: signs the `options` of an authentication ceremony with the
+//	last passkey created for their RP ID, as done by
+//	navigator.credentials.get.
+//	@receiver a
+//	@param options
+//	@return webauthn.AssertionResponse
+//	@return error: ErrNoCredentials.
+func (a *Authenticator) Login(options webauthn.RequestOptions) (webauthn.AssertionResponse, error) {
+	var res webauthn.AssertionResponse
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	
+	var c *credential
+	for _, stored := range a.credentials {
+		if stored.rpID == options.RPID {
+			c = stored
+		}
+	}
+	if c == nil {
+		return res, ErrNoCredentials
+	}
+	clientData := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authenticatorData(c, 0, nil)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(bytes.Clone(authData), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return res, err
+	}
+	
+	res.ID = c.id
+	res.Response.ClientDataJSON = clientData
+	res.Response.AuthenticatorData = authData
+	res.Response.Signature = signature
+	res.Response.UserHandle = c.userHandle
+	return res, nil
+}
+
+// clientData
+//
+//	@Description: returns the JSON encoded client data collected by the
+//	browser for a ceremony.
+//	@receiver a
+//	@param ceremony
+//	@param challenge
+//	@return []byte
+func (a *Authenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]any{
+		"type":        ceremony,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return data
+}
+
+// authenticatorData
+//
+//	@Description: returns the authenticator data signed for the credential,
+//	with the user present and verified flags.
+//	@receiver a
+//	@param c
+//	@param flags: the other flags.
+//	@param attested: the attested credential data, if any.
+//	@return []byte
+func (a *Authenticator) authenticatorData(c *credential, flags byte, attested []byte) []byte {
+	if a.CountSignatures {
+		c.signCount++
+	}
+	hash := sha256.Sum256([]byte(c.rpID))
+	data := append(hash[:], flags|0x01|0x04, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], c.signCount)
+	return append(data, attested...)
+}
+
+// encodeCBOR
+//
+//	@Description: encodes the `value` in CBOR with the lengths in their
+//	shortest form and the map keys sorted as the CTAP2 canonical form
+//	requires. Only the types used by the Authenticator are supported.
+//	@param value: an int, []byte, string, map[int]any or map[string]any.
+//	@return []byte
+func encodeCBOR(value any) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case map[int]any:
+		keys := make([][]byte, 0, len(v))
+		encoded := make(map[string][]byte, len(v))
+		for key, item := range v {
+			k := encodeCBOR(key)
+			keys = append(keys, k)
+			encoded[string(k)] = encodeCBOR(item)
+		}
+		return cborMap(keys, encoded)
+	case map[string]any:
+		keys := make([][]byte, 0, len(v))
+		encoded := make(map[string][]byte, len(v))
+		for key, item := range v {
+			k := encodeCBOR(key)
+			keys = append(keys, k)
+			encoded[string(k)] = encodeCBOR(item)
+		}
+		return cborMap(keys, encoded)
+	}
+	panic("soft: unsupported CBOR type")
+}
+
+// cborMap
+//
+//	@Description: encodes a map from its encoded keys and values, the keys
+//	being sorted by length then bytewise.
+//	@param keys
+//	@param values: the encoded values by encoded key.
+//	@return []byte
+func cborMap(keys [][]byte, values map[string][]byte) []byte {
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) < len(keys[j])
+		}
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	data := cborHead(5, uint64(len(keys)))
+	for _, key := range keys {
+		data = append(append(data, key...), values[string(key)]...)
+	}
+	return data
+}
+
+// cborHead
+//
+//	@Description: encodes the head of a CBOR data item.
+//	@param major: the major type.
+//	@param arg: the value, length or number of elements.
+//	@return []byte
+func cborHead(major byte, arg uint64) []byte {
+	major <<= 5
+	switch {
+	case arg < 24:
+		return []byte{major | byte(arg)}
+	case arg <= 0xff:
+		return []byte{major | 24, byte(arg)}
+	case arg <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major | 25}, uint16(arg))
+	case arg <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major | 26}, uint32(arg))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major | 27}, arg)
+}
@@ -0,0 +1,437 @@
+// Package webauthn is a minimal WebAuthn relying party (Web Authentication
+// Level 2): it builds the options of the registration and authentication
+// ceremonies and verifies the authenticators' responses, for the
+// discoverable credentials (passkeys) requiring user verification. The
+// attestation statements aren't verified, since "none" is requested.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The flags of the authenticator data.
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+// maxCredentialIDLength is the maximum length of the credential IDs.
+const maxCredentialIDLength = 1023
+
+var (
+	ErrClientData        = errors.New("invalid client data")
+	ErrAuthenticatorData = errors.New("invalid authenticator data")
+	ErrAttestation       = errors.New("invalid attestation object")
+	ErrSignature         = errors.New("invalid signature")
+	ErrSignCount         = errors.New("signature counter went backwards, the authenticator may be cloned")
+)
+
+// Credential is a public key credential registered on the RelyingParty.
+type Credential struct {
+	ID         []byte
+	PublicKey  []byte // the CBOR encoded COSE_Key
+	SignCount  uint32
+	Transports []string
+}
+
+// RelyingParty is the website, identified by its domain (the RP ID) and
+// accepting the ceremonies performed on its Origin only.
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origin  string
+	Timeout time.Duration
+}
+
+// NewRelyingParty
+//
+//	@Description: returns the RelyingParty which origin is the one of the
+//	`baseURL` and which RP ID is its host name.
+//	@param name: the name displayed by the authenticators.
+//	@param baseURL
+//	@return *RelyingParty
+//	@return error
+func NewRelyingParty(name, baseURL string) (*RelyingParty, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid base URL %q", baseURL)
+	}
+	return &RelyingParty{
+		ID:      u.Hostname(),
+		Name:    name,
+		Origin:  u.Scheme + "://" + u.Host,
+		Timeout: time.Minute * 5,
+	}, nil
+}
+
+// Challenge
+//
+//	@Description: generates the random challenge of a ceremony.
+//	@return []byte
+//	@return error
+func Challenge() ([]byte, error) {
+	challenge := make([]byte, 32)
+	_, err := rand.Read(challenge)
+	return challenge, err
+}
+
+// Bytes is a []byte encoded in base64url (without padding) in JSON, as done
+// by the PublicKeyCredential's toJSON method.
+type Bytes []byte
+
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	*b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	return err
+}
+
+// CredentialDescriptor identifies a Credential in the options.
+type CredentialDescriptor struct {
+	Type       string   `json:"type"`
+	ID         Bytes    `json:"id"`
+	Transports []string `json:"transports,omitempty"`
+}
+
+// CreationOptions are the PublicKeyCredentialCreationOptions of a
+// registration ceremony.
+type CreationOptions struct {
+	Challenge Bytes `json:"challenge"`
+	RP        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"rp"`
+	User struct {
+		ID          Bytes  `json:"id"`
+		Name        string `json:"name"`
+		DisplayName string `json:"displayName"`
+	} `json:"user"`
+	PubKeyCredParams []struct {
+		Type string `json:"type"`
+		Alg  int    `json:"alg"`
+	} `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection struct {
+		ResidentKey        string `json:"residentKey"`
+		RequireResidentKey bool   `json:"requireResidentKey"`
+		UserVerification   string `json:"userVerification"`
+	} `json:"authenticatorSelection"`
+	Attestation string `json:"attestation"`
+}
+
+// RequestOptions are the PublicKeyCredentialRequestOptions of an
+// authentication ceremony. No credential is allowed explicitly, so that the
+// authenticators offer the user's passkeys.
+type RequestOptions struct {
+	Challenge        Bytes  `json:"challenge"`
+	RPID             string `json:"rpId"`
+	Timeout          int64  `json:"timeout"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions
+//
+//	@Description: returns the options of a registration ceremony for a
+//	discoverable credential.
+//	@receiver rp
+//	@param challenge
+//	@param userHandle: the opaque id of the user, sent back when logging in.
+//	@param username
+//	@param exclude: the user's Credential, which can't be registered again.
+//	@return CreationOptions
+func (rp *RelyingParty) CreationOptions(challenge, userHandle []byte, username string, exclude []Credential) CreationOptions {
+	var options = CreationOptions{
+		Challenge:          challenge,
+		Timeout:            rp.Timeout.Milliseconds(),
+		ExcludeCredentials: []CredentialDescriptor{},
+		Attestation:        "none",
+	}
+	options.RP.ID, options.RP.Name = rp.ID, rp.Name
+	options.User.ID, options.User.Name, options.User.DisplayName = userHandle, username, username
+	for _, alg := range []int{AlgES256, AlgEdDSA, AlgRS256} {
+		options.PubKeyCredParams = append(options.PubKeyCredParams, struct {
+			Type string `json:"type"`
+			Alg  int    `json:"alg"`
+		}{"public-key", alg})
+	}
+	for _, credential := range exclude {
+		options.ExcludeCredentials = append(options.ExcludeCredentials, CredentialDescriptor{
+			Type:       "public-key",
+			ID:         credential.ID,
+			Transports: credential.Transports,
+		})
+	}
+	options.AuthenticatorSelection.ResidentKey = "required"
+	options.AuthenticatorSelection.RequireResidentKey = true
+	options.AuthenticatorSelection.UserVerification = "required"
+	return options
+}
+
+// RequestOptions
+//
+//	@Description: returns the options of an authentication ceremony.
+//	@receiver rp
+//	@param challenge
+//	@return RequestOptions
+func (rp *RelyingParty) RequestOptions(challenge []byte) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          rp.Timeout.Milliseconds(),
+		UserVerification: "required",
+	}
+}
+
+// RegistrationResponse is the PublicKeyCredential created by the
+// authenticator, as encoded by its toJSON method.
+type RegistrationResponse struct {
+	ID       Bytes `json:"rawId"`
+	Response struct {
+		ClientDataJSON    Bytes    `json:"clientDataJSON"`
+		AttestationObject Bytes    `json:"attestationObject"`
+		Transports        []string `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the PublicKeyCredential asserted by the
+// authenticator, as encoded by its toJSON method.
+type AssertionResponse struct {
+	ID       Bytes `json:"rawId"`
+	Response struct {
+		ClientDataJSON    Bytes `json:"clientDataJSON"`
+		AuthenticatorData Bytes `json:"authenticatorData"`
+		Signature         Bytes `json:"signature"`
+		UserHandle        Bytes `json:"userHandle"`
+	} `json:"response"`
+}
+
+// clientData is the CollectedClientData signed by the authenticator.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// checkClientData
+//
+//	@Description: checks that the `raw` client data were collected for the
+//	ceremony of the `challenge`, on the RelyingParty's origin.
+//	@receiver rp
+//	@param raw
+//	@param ceremony: "webauthn.create" or "webauthn.get".
+//	@param challenge
+//	@return error: ErrClientData.
+func (rp *RelyingParty) checkClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrClientData, err)
+	}
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	switch {
+	case data.Type != ceremony:
+		return fmt.Errorf("%w: type %q", ErrClientData, data.Type)
+	case err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(received, challenge) != 1:
+		return fmt.Errorf("%w: challenge mismatch", ErrClientData)
+	case data.Origin != rp.Origin || data.CrossOrigin:
+		return fmt.Errorf("%w: origin %q", ErrClientData, data.Origin)
+	}
+	return nil
+}
+
+// authenticatorData is the parsed authenticator data.
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// parseAuthenticatorData
+//
+//	@Description: parses the authenticator data, including the attested
+//	credential data when its flag is set.
+//	@param data
+//	@return authenticatorData
+//	@return error: ErrAuthenticatorData.
+func parseAuthenticatorData(data []byte) (authenticatorData, error) {
+	var auth authenticatorData
+	if len(data) < 37 {
+		return auth, fmt.Errorf("%w: too short", ErrAuthenticatorData)
+	}
+	auth.RPIDHash, auth.Flags, auth.SignCount = data[:32], data[32], binary.BigEndian.Uint32(data[33:37])
+	rest := data[37:]
+	
+	if auth.Flags&flagAttested != 0 {
+		// AAGUID (16 bytes), credential ID length (2 bytes), credential ID and COSE_Key
+		if len(rest) < 18 {
+			return auth, fmt.Errorf("%w: truncated attested credential data", ErrAuthenticatorData)
+		}
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if length == 0 || length > maxCredentialIDLength || len(rest) < length {
+			return auth, fmt.Errorf("%w: invalid credential ID", ErrAuthenticatorData)
+		}
+		auth.CredentialID, rest = rest[:length], rest[length:]
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return auth, fmt.Errorf("%w: %w", ErrAuthenticatorData, err)
+		}
+		auth.PublicKey, rest = rest[:len(rest)-len(after)], after
+	}
+	if auth.Flags&flagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return auth, fmt.Errorf("%w: %w", ErrAuthenticatorData, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return auth, fmt.Errorf("%w: trailing bytes", ErrAuthenticatorData)
+	}
+	return auth, nil
+}
+
+// checkAuthenticatorData
+//
+//	@Description: checks that the authenticator data are scoped to the
+//	RelyingParty's ID and that the user was present and verified.
+//	@receiver rp
+//	@param auth
+//	@return error: ErrAuthenticatorData.
+func (rp *RelyingParty) checkAuthenticatorData(auth authenticatorData) error {
+	hash := sha256.Sum256([]byte(rp.ID))
+	switch {
+	case !bytes.Equal(auth.RPIDHash, hash[:]):
+		return fmt.Errorf("%w: RP ID mismatch", ErrAuthenticatorData)
+	case auth.Flags&flagUserPresent == 0:
+		return fmt.Errorf("%w: user not present", ErrAuthenticatorData)
+	case auth.Flags&flagUserVerified == 0:
+		return fmt.Errorf("%w: user not verified", ErrAuthenticatorData)
+	}
+	return nil
+}
+
+// VerifyRegistration
+//
+//	@Description: verifies the response of the registration ceremony of the
+//	`challenge` and returns the new Credential.
+//	@receiver rp
+//	@param challenge
+//	@param res
+//	@return Credential
+//	@return error
+func (rp *RelyingParty) VerifyRegistration(challenge []byte, res RegistrationResponse) (Credential, error) {
+	err := rp.checkClientData(res.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+	
+	item, rest, err := decodeCBOR(res.Response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return Credential{}, ErrAttestation
+	}
+	attestation, ok := item.(map[any]any)
+	if !ok {
+		return Credential{}, ErrAttestation
+	}
+	format, _ := attestation["fmt"].(string)
+	rawAuth, _ := attestation["authData"].([]byte)
+	if format == "" || rawAuth == nil {
+		return Credential{}, ErrAttestation
+	}
+	auth, err := parseAuthenticatorData(rawAuth)
+	if err != nil {
+		return Credential{}, err
+	}
+	err = rp.checkAuthenticatorData(auth)
+	if err != nil {
+		return Credential{}, err
+	}
+	if auth.Flags&flagAttested == 0 {
+		return Credential{}, fmt.Errorf("%w: no attested credential data", ErrAuthenticatorData)
+	}
+	if !bytes.Equal(auth.CredentialID, res.ID) {
+		return Credential{}, fmt.Errorf("%w: credential ID mismatch", ErrAttestation)
+	}
+	_, err = parsePublicKey(auth.PublicKey)
+	if err != nil {
+		return Credential{}, err
+	}
+	return Credential{
+		ID:         bytes.Clone(auth.CredentialID),
+		PublicKey:  bytes.Clone(auth.PublicKey),
+		SignCount:  auth.SignCount,
+		Transports: res.Response.Transports,
+	}, nil
+}
+
+// VerifyAssertion
+//
+//	@Description: verifies the response of the authentication ceremony of the
+//	`challenge`, signed with the `credential`.
+//	@receiver rp
+//	@param challenge
+//	@param res
+//	@param credential: the registered Credential which ID matches the response's.
+//	@return uint32: the new signature counter of the Credential.
+//	@return error
+func (rp *RelyingParty) VerifyAssertion(challenge []byte, res AssertionResponse, credential Credential) (uint32, error) {
+	if !bytes.Equal(res.ID, credential.ID) {
+		return 0, fmt.Errorf("%w: credential ID mismatch", ErrSignature)
+	}
+	err := rp.checkClientData(res.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+	auth, err := parseAuthenticatorData(res.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.checkAuthenticatorData(auth)
+	if err != nil {
+		return 0, err
+	}
+	
+	key, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	hash := sha256.Sum256(res.Response.ClientDataJSON)
+	signed := append(bytes.Clone(res.Response.AuthenticatorData), hash[:]...)
+	if !key.verify(signed, res.Response.Signature) {
+		return 0, ErrSignature
+	}
+	
+	// the synced passkeys don't count the signatures and always send 0
+	if (auth.SignCount != 0 || credential.SignCount != 0) && auth.SignCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+	return auth.SignCount, nil
+}
@@ -0,0 +1,302 @@
+package webauthn_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"testing"
+	
+	"mangathorg/internal/webauthn"
+	"mangathorg/internal/webauthn/soft"
+)
+
+const testOrigin = "https://mangathorg.example.com"
+
+// newTestRelyingParty returns the RelyingParty of the testOrigin.
+func newTestRelyingParty(t *testing.T) *webauthn.RelyingParty {
+	rp, err := webauthn.NewRelyingParty("MangaThorg", testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+// newChallenge returns a new random challenge.
+func newChallenge(t *testing.T) []byte {
+	challenge, err := webauthn.Challenge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge
+}
+
+// register
+//
+//	@Description: creates a passkey on the authenticator `a` for a new
+//	registration ceremony of the `rp`.
+//	@param t
+//	@param rp
+//	@param a
+//	@return []byte: the challenge of the ceremony.
+//	@return webauthn.RegistrationResponse
+func register(t *testing.T, rp *webauthn.RelyingParty, a *soft.Authenticator) ([]byte, webauthn.RegistrationResponse) {
+	challenge := newChallenge(t)
+	res, err := a.Register(rp.CreationOptions(challenge, []byte("1"), "alice", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge, res
+}
+
+// login
+//
+//	@Description: signs a new authentication ceremony of the `rp` with the
+//	authenticator `a`.
+//	@param t
+//	@param rp
+//	@param a
+//	@return []byte: the challenge of the ceremony.
+//	@return webauthn.AssertionResponse
+func login(t *testing.T, rp *webauthn.RelyingParty, a *soft.Authenticator) ([]byte, webauthn.AssertionResponse) {
+	challenge := newChallenge(t)
+	res, err := a.Login(rp.RequestOptions(challenge))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return challenge, res
+}
+
+// setClientData
+//
+//	@Description: returns the JSON encoded client data `raw` with its `key`
+//	set to the `value`.
+//	@param t
+//	@param raw
+//	@param key
+//	@param value
+//	@return []byte
+func setClientData(t *testing.T, raw []byte, key string, value any) []byte {
+	var data map[string]any
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[key] = value
+	raw, err = json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+// authDataOffset returns the offset of the authenticator data in the
+// attestation object, which starts with the RP ID hash.
+func authDataOffset(t *testing.T, rp *webauthn.RelyingParty, attestationObject []byte) int {
+	hash := sha256.Sum256([]byte(rp.ID))
+	i := bytes.Index(attestationObject, hash[:])
+	if i < 0 {
+		t.Fatal("no authenticator data in the attestation object")
+	}
+	return i
+}
+
+func TestCeremonies(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := soft.New(testOrigin)
+	
+	challenge, created := register(t, rp, a)
+	credential, err := rp.VerifyRegistration(challenge, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(credential.ID, created.ID) || credential.SignCount != 1 {
+		t.Fatalf("wrong credential %+v", credential)
+	}
+	
+	for want := uint32(2); want <= 3; want++ {
+		challenge, res := login(t, rp, a)
+		signCount, err := rp.VerifyAssertion(challenge, res, credential)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signCount != want {
+			t.Fatalf("signature counter %d instead of %d", signCount, want)
+		}
+		credential.SignCount = signCount
+	}
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	
+	tests := []struct {
+		name   string
+		tamper func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse)
+		err    error
+	}{
+		{"wrong type", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "type", "webauthn.get")
+		}, webauthn.ErrClientData},
+		{"wrong origin", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "origin", "https://evil.example.com")
+		}, webauthn.ErrClientData},
+		{"cross origin", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "crossOrigin", true)
+		}, webauthn.ErrClientData},
+		{"wrong challenge", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "challenge", "c3RhbGUtY2hhbGxlbmdl")
+		}, webauthn.ErrClientData},
+		{"wrong RP ID hash", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.AttestationObject[authDataOffset(t, rp, res.Response.AttestationObject)] ^= 0xff
+		}, webauthn.ErrAuthenticatorData},
+		{"user not present", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.AttestationObject[authDataOffset(t, rp, res.Response.AttestationObject)+32] &^= 0x01
+		}, webauthn.ErrAuthenticatorData},
+		{"user not verified", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.AttestationObject[authDataOffset(t, rp, res.Response.AttestationObject)+32] &^= 0x04
+		}, webauthn.ErrAuthenticatorData},
+		{"another credential ID", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.ID = []byte("another-credential")
+		}, webauthn.ErrAttestation},
+		{"malformed attestation object", func(rp *webauthn.RelyingParty, res *webauthn.RegistrationResponse) {
+			res.Response.AttestationObject = res.Response.AttestationObject[:10]
+		}, webauthn.ErrAttestation},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			challenge, res := register(t, rp, soft.New(testOrigin))
+			test.tamper(rp, &res)
+			_, err := rp.VerifyRegistration(challenge, res)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error %v instead of %v", err, test.err)
+			}
+		})
+	}
+	
+	// the response of another ceremony
+	_, res := register(t, rp, soft.New(testOrigin))
+	_, err := rp.VerifyRegistration(newChallenge(t), res)
+	if !errors.Is(err, webauthn.ErrClientData) {
+		t.Fatalf("registration of another challenge accepted: %v", err)
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	
+	tests := []struct {
+		name   string
+		tamper func(res *webauthn.AssertionResponse)
+		err    error
+	}{
+		{"wrong type", func(res *webauthn.AssertionResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "type", "webauthn.create")
+		}, webauthn.ErrClientData},
+		{"wrong origin", func(res *webauthn.AssertionResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "origin", "https://evil.example.com")
+		}, webauthn.ErrClientData},
+		{"wrong challenge", func(res *webauthn.AssertionResponse) {
+			res.Response.ClientDataJSON = setClientData(t, res.Response.ClientDataJSON, "challenge", "c3RhbGUtY2hhbGxlbmdl")
+		}, webauthn.ErrClientData},
+		{"wrong RP ID hash", func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData[0] ^= 0xff
+		}, webauthn.ErrAuthenticatorData},
+		{"user not present", func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData[32] &^= 0x01
+		}, webauthn.ErrAuthenticatorData},
+		{"user not verified", func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData[32] &^= 0x04
+		}, webauthn.ErrAuthenticatorData},
+		{"truncated authenticator data", func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData = res.Response.AuthenticatorData[:36]
+		}, webauthn.ErrAuthenticatorData},
+		{"bad signature", func(res *webauthn.AssertionResponse) {
+			res.Response.Signature[len(res.Response.Signature)-1] ^= 0xff
+		}, webauthn.ErrSignature},
+		{"signed counter changed", func(res *webauthn.AssertionResponse) {
+			res.Response.AuthenticatorData[36] ^= 0xff
+		}, webauthn.ErrSignature},
+		{"another credential ID", func(res *webauthn.AssertionResponse) {
+			res.ID = []byte("another-credential")
+		}, webauthn.ErrSignature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := soft.New(testOrigin)
+			challenge, created := register(t, rp, a)
+			credential, err := rp.VerifyRegistration(challenge, created)
+			if err != nil {
+				t.Fatal(err)
+			}
+			challenge, res := login(t, rp, a)
+			test.tamper(&res)
+			_, err = rp.VerifyAssertion(challenge, res, credential)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("error %v instead of %v", err, test.err)
+			}
+		})
+	}
+	
+	// signed by another authenticator, for the same credential ID
+	a, other := soft.New(testOrigin), soft.New(testOrigin)
+	challenge, created := register(t, rp, a)
+	credential, err := rp.VerifyRegistration(challenge, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	register(t, rp, other)
+	challenge, res := login(t, rp, other)
+	res.ID = credential.ID
+	_, err = rp.VerifyAssertion(challenge, res, credential)
+	if !errors.Is(err, webauthn.ErrSignature) {
+		t.Fatalf("assertion of another key accepted: %v", err)
+	}
+}
+
+func TestVerifyAssertionSignCount(t *testing.T) {
+	rp := newTestRelyingParty(t)
+	a := soft.New(testOrigin)
+	challenge, created := register(t, rp, a)
+	credential, err := rp.VerifyRegistration(challenge, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	
+	// a clone of the authenticator, which counter is behind
+	challenge, res := login(t, rp, a)
+	credential.SignCount = 5
+	_, err = rp.VerifyAssertion(challenge, res, credential)
+	if !errors.Is(err, webauthn.ErrSignCount) {
+		t.Fatalf("counter regression accepted: %v", err)
+	}
+	
+	// the same counter twice
+	challenge, res = login(t, rp, a)
+	credential.SignCount = 0
+	signCount, err := rp.VerifyAssertion(challenge, res, credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credential.SignCount = signCount
+	_, err = rp.VerifyAssertion(challenge, res, credential)
+	if !errors.Is(err, webauthn.ErrSignCount) {
+		t.Fatalf("same counter accepted twice: %v", err)
+	}
+	
+	// the synced passkeys always send 0
+	synced := soft.New(testOrigin)
+	synced.CountSignatures = false
+	challenge, created = register(t, rp, synced)
+	credential, err = rp.VerifyRegistration(challenge, created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		challenge, res = login(t, rp, synced)
+		signCount, err = rp.VerifyAssertion(challenge, res, credential)
+		if err != nil || signCount != 0 {
+			t.Fatalf("synced passkey refused: %d, %v", signCount, err)
+		}
+	}
+}
@@ -15,6 +15,16 @@ func Init() {
 	Mux.HandleFunc("GET /about", controllers.AboutHandlerGetBundle)
 	Mux.HandleFunc("GET /login", controllers.LoginHandlerGetBundle)
 	Mux.HandleFunc("POST /login", controllers.LoginHandlerPostBundle)
+	Mux.HandleFunc("GET /login/2fa", controllers.LoginTwoFactorHandlerGetBundle)
+	Mux.HandleFunc("POST /login/2fa", controllers.LoginTwoFactorHandlerPostBundle)
+	Mux.HandleFunc("GET /login/email", controllers.LoginEmailHandlerGetBundle)
+	Mux.HandleFunc("POST /login/email", controllers.LoginEmailHandlerPostBundle)
+	Mux.HandleFunc("GET /login/email/confirm", controllers.LoginEmailConfirmHandlerGetBundle)
+	Mux.HandleFunc("POST /login/email/confirm", controllers.LoginEmailConfirmHandlerPostBundle)
+	Mux.HandleFunc("POST /login/passkey/options", controllers.LoginPasskeyOptionsHandlerPostBundle)
+	Mux.HandleFunc("POST /login/passkey", controllers.LoginPasskeyHandlerPostBundle)
+	Mux.HandleFunc("GET /login/oidc", controllers.LoginOIDCHandlerGetBundle)
+	Mux.HandleFunc("GET /login/oidc/callback", controllers.LoginOIDCCallbackHandlerGetBundle)
 	Mux.HandleFunc("GET /register", controllers.RegisterHandlerGetBundle)
 	Mux.HandleFunc("POST /register", controllers.RegisterHandlerPostBundle)
 	Mux.HandleFunc("GET /forgot-password", controllers.ForgotPasswordHandlerGetBundle)
@@ -23,8 +33,29 @@ func Init() {
 	Mux.HandleFunc("POST /update-credentials/{id}", controllers.UpdateCredentialsHandlerPostBundle)
 	Mux.HandleFunc("GET /profile", controllers.ProfileHandlerGetBundle)
 	Mux.HandleFunc("POST /profile", controllers.ProfileHandlerPostBundle)
+	Mux.HandleFunc("GET /profile/2fa", controllers.TwoFactorHandlerGetBundle)
+	Mux.HandleFunc("POST /profile/2fa", controllers.TwoFactorHandlerPostBundle)
+	Mux.HandleFunc("GET /profile/sessions", controllers.SessionsHandlerGetBundle)
+	Mux.HandleFunc("POST /profile/sessions", controllers.SessionsHandlerPostBundle)
+	Mux.HandleFunc("GET /profile/passkeys", controllers.PasskeysHandlerGetBundle)
+	Mux.HandleFunc("POST /profile/passkeys", controllers.PasskeysHandlerPostBundle)
+	Mux.HandleFunc("POST /profile/passkeys/options", controllers.PasskeyOptionsHandlerPostBundle)
+	Mux.HandleFunc("POST /profile/passkeys/register", controllers.PasskeyRegisterHandlerPostBundle)
+	Mux.HandleFunc("GET /profile/account", controllers.AccountHandlerGetBundle)
+	Mux.HandleFunc("POST /profile/account", controllers.AccountHandlerPostBundle)
+	Mux.HandleFunc("GET /admin", controllers.AdminHandlerGetBundle)
+	Mux.HandleFunc("GET /admin/users/{id}", controllers.AdminUserHandlerGetBundle)
+	Mux.HandleFunc("POST /admin/users/{id}", controllers.AdminUserHandlerPostBundle)
+	Mux.HandleFunc("GET /admin/health", controllers.AdminHealthHandlerGetBundle)
+	Mux.HandleFunc("GET /admin/logs", controllers.AdminLogsHandlerGetBundle)
+	Mux.HandleFunc("GET /admin/logs/stream", controllers.AdminLogsStreamHandlerGetBundle)
 	Mux.HandleFunc("GET /home", controllers.HomeHandlerGetBundle)
+	Mux.HandleFunc("GET /history", controllers.HistoryHandlerGetBundle)
 	Mux.HandleFunc("GET /confirm", controllers.ConfirmHandlerGetBundle)
+	Mux.HandleFunc("GET /confirm-email", controllers.ConfirmEmailHandlerGetBundle)
+	Mux.HandleFunc("POST /confirm-email", controllers.ConfirmEmailHandlerPostBundle)
+	Mux.HandleFunc("GET /cancel-email", controllers.CancelEmailHandlerGetBundle)
+	Mux.HandleFunc("POST /cancel-email", controllers.CancelEmailHandlerPostBundle)
 	Mux.HandleFunc("GET /logout", controllers.LogoutHandlerGetBundle)
 	Mux.HandleFunc("GET /principal", controllers.PrincipalHandlerGetBundle)
 	Mux.HandleFunc("GET /manga/{id}", controllers.MangaRequestHandlerGet)
@@ -38,9 +69,19 @@ func Init() {
 	Mux.HandleFunc("POST /favorite/{mangaId}", controllers.FavoriteHandlerPostBundle)
 	Mux.HandleFunc("DELETE /favorite/{mangaId}", controllers.FavoriteHandlerDeleteBundle)
 	Mux.HandleFunc("PUT /favorite/{mangaId}", controllers.BannerHandlerPutBundle)
+	Mux.HandleFunc("PUT /history/{entryId}", controllers.HistoryHandlerPutBundle)
+	Mux.HandleFunc("DELETE /history/{entryId}", controllers.HistoryHandlerDeleteBundle)
+	Mux.HandleFunc("DELETE /history", controllers.HistoryClearHandlerDeleteBundle)
 	
-	// !! TESTING: this route is only for testing purposes for now. You need to disable it if you want to deploy the server.
-	// Mux.HandleFunc("GET /logs", controllers.LogHandlerGetBundle)
+	// JSON API
+	Mux.HandleFunc("GET /api/v1/search", controllers.ApiSearchHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/manga/{id}", controllers.ApiMangaHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/chapter/{chapterId}", controllers.ApiChapterHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/tags", controllers.ApiTagsHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/category/{tagId}", controllers.ApiCategoryHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/category/{group}/{name}", controllers.ApiCategoryNameHandlerGetBundle)
+	Mux.HandleFunc("GET /api/v1/favorites", controllers.ApiFavoritesHandlerGetBundle)
+	Mux.HandleFunc("/api/", controllers.ApiNotFoundHandlerBundle)
 	
 	// Handling StatusNotFound error everywhere else
 	Mux.HandleFunc("/", controllers.ErrorHandlerBundle)
@@ -1,11 +1,10 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -14,25 +13,13 @@ import (
 	"strings"
 	"time"
 	
+	"mangathorg/internal/mangadex"
 	"mangathorg/internal/models/api"
 	"mangathorg/internal/utils"
 )
 
-const (
-	baseURL = "https://api.mangadex.org"
-	version = "v0.1.0"
-)
+const version = "v0.1.0"
 
-var (
-	// Client is the http.Client used for all API requests.
-	Client = http.Client{
-		Timeout: time.Second * 5,
-	}
-	
-	NbRateLimitExceeded = 0
-	
-	ErrTooManyRequests = errors.New("too many requests")
-)
 
 type chapter struct {
 	Title   string
@@ -43,11 +30,11 @@ type chapter struct {
 }
 
 func mangaFeedURL(id string) string {
-	return fmt.Sprintf("%s/manga/%s/feed", baseURL, id)
+	return fmt.Sprintf("%s/manga/%s/feed", mangadex.APIURL, id)
 }
 
 func chapterURL(id string) string {
-	return fmt.Sprintf("%s/at-home/server/%s", baseURL, id)
+	return fmt.Sprintf("%s/at-home/server/%s", mangadex.APIURL, id)
 }
 
 func scanImageURL(baseURL, hash, quality, filename string) string {
@@ -118,23 +105,13 @@ func main() {
 		fmt.Printf(":: [INFO] Getting scan hashes for Volume %s - Chapter %s - %s\n", ch.Volume, ch.Chapter, ch.Title)
 		scan, err := getChapterScans(ch.ID)
 		if err != nil {
-			if errors.Is(err, ErrTooManyRequests) {
-				fmt.Println()
-				fmt.Println(":: [INFO] Rate limit exceeded")
-				fmt.Println(":: [INFO] Waiting 10 seconds")
-				for j := 0; j < 10; j++ {
-					fmt.Print(".")
-					time.Sleep(time.Second)
-				}
-				fmt.Println()
-				i--
+			if errors.Is(err, mangadex.ErrTooManyRequests) {
+				ErrExit(err)
 			}
 			fmt.Printf(":: [ERROR] %s\n", err.Error())
 			continue
 		}
 		chapters[i].Scans = *scan
-		
-		time.Sleep(time.Millisecond * 2000)
 	}
 	
 	for i := 0; i < len(chapters); i++ {
@@ -155,22 +132,12 @@ func main() {
 		err = downloadChapter(output, ch)
 		if err != nil {
 			fmt.Println()
-			if errors.Is(err, ErrTooManyRequests) {
-				fmt.Println(":: [INFO] Rate limit exceeded")
-				fmt.Println(":: [INFO] Waiting 10 seconds")
-				for j := 0; j < 10; j++ {
-					fmt.Print(".")
-					time.Sleep(time.Second)
-				}
-				fmt.Println()
-				i--
-				continue
+			if errors.Is(err, mangadex.ErrTooManyRequests) {
+				ErrExit(err)
 			}
 			fmt.Printf(":: [ERROR] %s\n", err.Error())
 			continue
 		}
-		
-		time.Sleep(time.Millisecond * 2000)
 	}
 	
 	fmt.Printf(":: [INFO] Download completed in %s\n", utils.DurationToString(time.Since(now)))
@@ -265,38 +232,10 @@ func downloadScanImage(baseURL, hash, quality, filename, output string) error {
 	return nil
 }
 
+// request sends a request through the shared MangaDex client, which waits
+// for the rate limits and retries the failed requests.
 func request(url string, query url.Values) ([]byte, error) {
-	req, errReq := http.NewRequest(http.MethodGet, url, nil)
-	if errReq != nil {
-		return nil, errReq
-	}
-	
-	if query != nil {
-		req.URL.RawQuery = query.Encode()
-	}
-	
-	res, errRes := Client.Do(req)
-	if res.Body != nil {
-		defer res.Body.Close()
-	} else {
-		return nil, errRes
-	}
-	
-	body, errBody := io.ReadAll(res.Body)
-	if errBody != nil {
-		return nil, errBody
-	}
-	
-	var err error
-	if res.StatusCode != http.StatusOK {
-		if res.StatusCode == http.StatusTooManyRequests {
-			err = ErrRateLimiter()
-		} else {
-			err = fmt.Errorf("unexpected status code: %d", res.StatusCode)
-		}
-	}
-	
-	return body, err
+	return mangadex.Default.Get(context.Background(), url, query)
 }
 
 func createDirs(dirname string) error {
@@ -319,14 +258,6 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
-func ErrRateLimiter() error {
-	NbRateLimitExceeded++
-	if NbRateLimitExceeded > 5 {
-		ErrExit(fmt.Errorf("rate limit exceeded %d times", NbRateLimitExceeded))
-	}
-	return ErrTooManyRequests
-}
-
 func ErrExit(err error) {
 	fmt.Printf(":: [ERROR] %s\n", err.Error())
 	fmt.Println(":: [WARN] Aborting operation...")
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"log"
+	"os"
+	
+	"mangathorg/internal/utils"
+)
+
+// MakeAdmin gives the admin role to the user named `username` in the user
+// store set in the USER_STORE environment variable.
+func MakeAdmin(username string) {
+	utils.InitUsers()
+	
+	err := utils.PromoteAdmin(username)
+	if err != nil {
+		log.Fatalln("Error while promoting the admin:", err)
+	}
+	log.Printf("%s is now an admin", username)
+}
+
+// bootstrapAdmin gives the admin role to the user named in the ADMIN_USERNAME
+// environment variable, if any, so that the first admin can be set from the
+// configuration. It only applies while there is no admin and once this user
+// has registered: the other admins are set with -make-admin or from the
+// admin console, so that the variable can't promote anyone later on.
+func bootstrapAdmin() {
+	username := os.Getenv("ADMIN_USERNAME")
+	if username == "" {
+		return
+	}
+	hasAdmin, err := utils.HasAdmin()
+	if err != nil {
+		log.Printf("ADMIN_USERNAME: %s", err.Error())
+		return
+	}
+	if hasAdmin {
+		log.Printf("ADMIN_USERNAME: an admin already exists, %s isn't promoted", username)
+		return
+	}
+	if _, ok := utils.SelectUser(username); !ok {
+		log.Printf("ADMIN_USERNAME: %s hasn't registered yet, no admin is set", username)
+		return
+	}
+	err = utils.PromoteAdmin(username)
+	if err != nil {
+		log.Printf("ADMIN_USERNAME: %s", err.Error())
+		return
+	}
+	log.Printf("ADMIN_USERNAME: %s is now the first admin", username)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"log"
+	
+	"mangathorg/internal/utils"
+)
+
+// MigrateUsers copies all users from data/users.json into the user store
+// named by the `to` param. Users already present in the target are skipped,
+// so the command can safely be run again.
+func MigrateUsers(to string) {
+	utils.InitUsers()
+	
+	from, err := utils.OpenUserStore("json")
+	if err != nil {
+		log.Fatalln("Error while opening users.json:", err)
+	}
+	defer from.Close()
+	
+	target, err := utils.OpenUserStore(to)
+	if err != nil {
+		log.Fatalln("Error while opening the user store:", err)
+	}
+	defer target.Close()
+	
+	copied, err := utils.MigrateUsers(from, target)
+	if err != nil {
+		log.Fatalf("Migration stopped after %d users: %s", copied, err)
+	}
+	log.Printf("%d users copied to the %s store", copied, to)
+}
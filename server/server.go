@@ -12,11 +12,18 @@ import (
 	"mangathorg/router"
 )
 
+// Options are the command-line options of the server.
+type Options struct {
+	// WipeCache empties the whole cache at startup (also enabled by setting
+	// the WIPE_CACHE environment variable to true).
+	WipeCache bool
+}
+
 // Run is the main function of the whole HTTP server:
 // it initializes the routes, make the asset folder
 // available to the clients, runs all needed goroutines
 // and the ListenAndServe() function.
-func Run() {
+func Run(options Options) {
 	
 	// Initializing the port and the BaseURL
 	port := os.Getenv("PORT")
@@ -35,6 +42,11 @@ func Run() {
 	router.Mux.Handle("/static/", http.StripPrefix("/static/", fs))
 	
 	utils.InitUsers()
+	bootstrapAdmin()
+	utils.InitSessions()
+	utils.InitPendingUsers()
+	utils.InitOIDC()
+	utils.InitPasskeys()
 	
 	// Running the goroutine to change log file every given time
 	go utils.LogInit()
@@ -42,11 +54,14 @@ func Run() {
 	// Running the goroutine to automatically remove expired sessions every given time
 	go utils.MonitorSessions()
 	
-	// Running the goroutine to automatically remove old TempUsers and LostUsers
+	// Running the goroutine to automatically remove the expired pending registrations and password resets
 	go utils.ManageTempUsers()
 	
-	// Delete all cache data
-	api.EmptyCache()
+	// Opening the cache kept from the previous runs
+	api.InitCache()
+	if options.WipeCache || os.Getenv("WIPE_CACHE") == "true" {
+		api.EmptyCache()
+	}
 	
 	// Running the goroutine to automatically remove old CacheData
 	go api.CacheMonitor()